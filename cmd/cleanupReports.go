@@ -10,8 +10,10 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/integr8ly/delorean/pkg/s3processor"
 	"github.com/integr8ly/delorean/pkg/utils"
 	"github.com/spf13/cobra"
+	"sync"
 )
 
 const (
@@ -112,75 +114,52 @@ func (c *cleanupReportsCmd) run(ctx context.Context) error {
 
 func (c *cleanupReportsCmd) cleanupObjectsForBucket(ctx context.Context, config cleanupConfig) (*cleanupResult, error) {
 	bucket := config.Bucket
-	fmt.Println(fmt.Sprintf("[%s] List objects in bucket", bucket))
-	objects, err := c.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: &bucket, Delimiter: aws.String("/")})
+	var requiredTags []s3processor.Tag
+	for _, t := range config.Tags {
+		requiredTags = append(requiredTags, s3processor.Tag{Key: t.Key, Value: t.Value})
+	}
+	p := s3processor.NewProcessor(c.s3, nil, s3processor.Options{
+		Bucket:       bucket,
+		RequiredTags: requiredTags,
+	})
+
+	// the objects are copied in parallel, and deleted together once they are all copied
+	var lock sync.Mutex
+	copied := []*s3.Object{}
+	results, err := p.Run(ctx, s3processor.SinkFunc(func(ctx context.Context, object *s3processor.Object) error {
+		if err := c.copyObject(ctx, bucket, archiveFolderName, object.Key); err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		copied = append(copied, &s3.Object{Key: aws.String(object.Key)})
+		return nil
+	}))
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(fmt.Sprintf("[%s] Found %d objects", bucket, len(objects.Contents)))
-	toCopy := []*s3.Object{}
-	for _, o := range objects.Contents {
-		ok, err := c.shouldCleanup(ctx, bucket, o, config.Tags)
-		if err != nil {
-			fmt.Println(fmt.Sprintf("[%s] Skip object %s due to error: %v", bucket, *o.Key, err))
-			continue
-		}
-		if ok {
-			fmt.Println(fmt.Sprintf("[%s] Object %s has matched tags and will be moved", bucket, *o.Key))
-			toCopy = append(toCopy, o)
-		} else {
-			fmt.Println(fmt.Sprintf("[%s] Skip object %s as it doesn't have the required tags", bucket, *o.Key))
-		}
+	// the objects which could not be copied are left in place and moved by the next clean up
+	if err := results.Err(); err != nil {
+		fmt.Println(fmt.Sprintf("[%s] %v", bucket, err))
 	}
-	copied := c.copyObjects(ctx, bucket, archiveFolderName, toCopy)
+	fmt.Println(fmt.Sprintf("[%s] Copied %d objects to %s", bucket, len(copied), archiveFolderName))
 	if err = c.deleteObjects(ctx, bucket, copied); err != nil {
 		return nil, err
 	}
 	return &cleanupResult{movedObjects: copied}, nil
 }
 
-func (c *cleanupReportsCmd) shouldCleanup(ctx context.Context, bucket string, object *s3.Object, tags []objectTag) (bool, error) {
-	fmt.Println(fmt.Sprintf("[%s] Listing tags for object %s", bucket, *object.Key))
-	t, err := c.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
-		Bucket: &bucket,
-		Key:    object.Key,
-	})
-	if err != nil {
-		return false, err
+func (c *cleanupReportsCmd) copyObject(ctx context.Context, bucket string, toFolder string, key string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(fmt.Sprintf("%s/%s", toFolder, key)),
+		CopySource: aws.String(fmt.Sprintf("%s/%s", bucket, key)),
 	}
-	cleanup := true
-	for _, tag := range tags {
-		if !hasTag(t.TagSet, tag.Key, tag.Value) {
-			cleanup = false
-			break
-		}
-	}
-	return cleanup, nil
-}
-
-func (c *cleanupReportsCmd) copyObjects(ctx context.Context, bucket string, toFolder string, objects []*s3.Object) []*s3.Object {
-	copiedObjects := []*s3.Object{}
-	if len(objects) == 0 {
-		fmt.Println(fmt.Sprintf("[%s] No objects to copy", bucket))
-		return copiedObjects
+	if _, err := c.s3.CopyObjectWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to copy object to %s/: %w", toFolder, err)
 	}
-	fmt.Println(fmt.Sprintf("[%s] Copying %d objects to %s/", bucket, len(objects), toFolder))
-	for _, o := range objects {
-		input := &s3.CopyObjectInput{
-			Bucket:     aws.String(bucket),
-			Key:        aws.String(fmt.Sprintf("%s/%s", toFolder, *o.Key)),
-			CopySource: aws.String(fmt.Sprintf("%s/%s", bucket, *o.Key)),
-		}
-		_, err := c.s3.CopyObjectWithContext(ctx, input)
-		if err != nil {
-			fmt.Println(fmt.Sprintf("[%s] Failed to copy object %s due to error: %v", bucket, *o.Key, err))
-		} else {
-			fmt.Println(fmt.Sprintf("[%s] Object %s copied to %s/%s", bucket, *o.Key, toFolder, *o.Key))
-			copiedObjects = append(copiedObjects, o)
-		}
-	}
-	fmt.Println(fmt.Sprintf("[%s] Copied %d objects to %s", bucket, len(copiedObjects), toFolder))
-	return copiedObjects
+	fmt.Println(fmt.Sprintf("[%s] Object %s copied to %s/%s", bucket, key, toFolder, key))
+	return nil
 }
 
 func (c *cleanupReportsCmd) deleteObjects(ctx context.Context, bucket string, toDelete []*s3.Object) error {
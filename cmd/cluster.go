@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	clusterConfigurationFile    = "cluster.json"
+	clusterDetailsFile          = "cluster-details.json"
+	clusterKubeconfigFile       = "cluster.kubeconfig"
+	clusterCredentialsFile      = "cluster-credentials.json"
+	clusterInstallationLogsFile = "cluster-installation.log"
+	clusterSubscriptionFile     = "cluster-subscription.json"
+
+	// the cluster commands have their own key, since ocm_token is bound to the flag of osd-addon-release. Both are
+	// set with the OCM_TOKEN env var
+	clusterOCMTokenKey = "cluster_ocm_token"
+)
+
+type clusterCmdFlags struct {
+	ocmURL    string
+	outputDir string
+}
+
+var clusterFlags = &clusterCmdFlags{}
+
+// clusterCredentials is the content of the cluster-credentials.json file
+type clusterCredentials struct {
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	APIURL     string `json:"api_url"`
+	ConsoleURL string `json:"console_url"`
+}
+
+func init() {
+	clusterCmd.PersistentFlags().String("ocm-token", "", fmt.Sprintf("OCM offline token. Can be set via the %s env var", strings.ToUpper(ocmTokenKey)))
+	viper.BindPFlag(clusterOCMTokenKey, clusterCmd.PersistentFlags().Lookup("ocm-token"))
+	viper.BindEnv(clusterOCMTokenKey, strings.ToUpper(ocmTokenKey))
+	clusterCmd.PersistentFlags().StringVar(&clusterFlags.ocmURL, "ocm-url", ocm.BaseURL, fmt.Sprintf("URL of the OCM API, like %s for the staging environment", ocm.StageBaseURL))
+	clusterCmd.PersistentFlags().StringVar(&clusterFlags.outputDir, "output-dir", "ocm", "Directory where the cluster details, credentials and logs are saved")
+}
+
+func newOCMClient(ocmURL string) (*ocm.Client, error) {
+	token, err := requireValue(clusterOCMTokenKey)
+	if err != nil {
+		return nil, err
+	}
+	client := ocm.NewClient(ocm.NewTokenClient(context.Background(), token))
+	if !strings.HasSuffix(ocmURL, "/") {
+		ocmURL = ocmURL + "/"
+	}
+	if client.BaseURL, err = client.BaseURL.Parse(ocmURL); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// resolveClusterID returns the given cluster id, or the id saved in the cluster details
+// file of the output directory by the create command
+func resolveClusterID(clusterID string, outputDir string) (string, error) {
+	if clusterID != "" {
+		return clusterID, nil
+	}
+	cluster := &ocm.Cluster{}
+	if err := readJSONFile(path.Join(outputDir, clusterDetailsFile), cluster); err != nil {
+		return "", fmt.Errorf("no cluster id specified and failed to read it from the cluster details: %w", err)
+	}
+	if cluster.ID == "" {
+		return "", fmt.Errorf("no cluster id found in %s", path.Join(outputDir, clusterDetailsFile))
+	}
+	return cluster.ID, nil
+}
+
+// waitFor polls the condition every interval until it's done or the timeout is reached
+func waitFor(ctx context.Context, description string, interval time.Duration, timeout time.Duration, condition wait.ConditionFunc) error {
+	fmt.Printf("Waiting for %s for %s...\n", description, timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		done, err := condition()
+		if err == nil && !done {
+			fmt.Printf("Waiting for %s... Trying again in %s\n", description, interval)
+		}
+		return done, err
+	}, timeoutCtx.Done())
+	if err == wait.ErrWaitTimeout && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for %s after %s", description, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s finished!\n", description)
+	return nil
+}
+
+func readJSONFile(file string, v interface{}) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeJSONFile(file string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(b, '\n'), 0600)
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// OSD cluster names can not be longer than 15 characters
+	clusterNameMaxLength      = 15
+	clusterCredentialsTimeout = 10 * time.Minute
+	productOSD                = "osd"
+	productROSA               = "rosa"
+)
+
+type clusterCreateFlags struct {
+	template           string
+	name               string
+	lifespan           int
+	product            string
+	region             string
+	openshiftVersion   string
+	multiAZ            bool
+	private            bool
+	computeNodes       int
+	computeMachineType string
+	ccs                bool
+	awsAccountID       string
+	subnetIDs          []string
+	privateLink        bool
+	interval           time.Duration
+	timeout            time.Duration
+	healthTimeout      time.Duration
+}
+
+type clusterCreateCmd struct {
+	flags         *clusterCreateFlags
+	outputDir     string
+	configuration *ocm.Cluster
+	clusters      ocm.ClustersManager
+	subscriptions ocm.SubscriptionsManager
+}
+
+func init() {
+	f := &clusterCreateFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an OSD cluster and wait for it to be ready",
+		Long: `Create an OSD cluster using the OCM API and wait for it to be ready.
+The cluster configuration, details, kubeconfig and kubeadmin credentials are saved in the output directory.
+If a cluster with the same name already exists, the existing cluster is used.
+For CCS clusters the AWS credentials are read from the DELOREAN_AWS_ACCESS_KEY_ID and DELOREAN_AWS_SECRET_ACCESS_KEY env vars.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newOCMClient(clusterFlags.ocmURL)
+			if err != nil {
+				handleError(err)
+			}
+			awsCredentials := &ocm.ClusterAWS{}
+			if f.ccs || f.product == productROSA {
+				if awsCredentials.AccessKeyID, err = requireValue(AWSAccessKeyIDEnv); err != nil {
+					handleError(err)
+				}
+				if awsCredentials.SecretAccessKey, err = requireValue(AWSSecretAccessKeyEnv); err != nil {
+					handleError(err)
+				}
+			}
+			c, err := newClusterCreateCmd(f, clusterFlags.outputDir, client.Clusters, client.Subscriptions, awsCredentials)
+			if err != nil {
+				handleError(err)
+			}
+			if err = c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+
+	clusterCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&f.template, "template", "templates/ocm/cluster-template.json", "Path to the cluster template")
+	cmd.Flags().StringVar(&f.name, "name", "", "Name of the cluster. Defaults to rhoam-<timestamp>. Names longer than 15 characters are shortened, the full name is used as display name")
+	cmd.Flags().IntVar(&f.lifespan, "lifespan", 4, "Hours after which the cluster is deleted automatically. Set to 0 to never expire")
+	cmd.Flags().StringVar(&f.product, "product", productOSD, fmt.Sprintf("The product of the cluster. Valid inputs are %q or %q. ROSA clusters are always CCS clusters", productOSD, productROSA))
+	cmd.Flags().StringVar(&f.region, "region", "", "Region of the cluster. Defaults to the region of the template")
+	cmd.Flags().StringVar(&f.openshiftVersion, "openshift-version", "", "OpenShift version of the cluster (ex. 4.10.3). Defaults to the latest version")
+	cmd.Flags().BoolVar(&f.multiAZ, "multi-az", false, "Create a multi AZ cluster")
+	cmd.Flags().BoolVar(&f.private, "private", false, "Make the cluster API private")
+	cmd.Flags().IntVar(&f.computeNodes, "compute-nodes", 0, "Number of compute nodes. Defaults to the number of the template")
+	cmd.Flags().StringVar(&f.computeMachineType, "compute-machine-type", "", "Machine type of the compute nodes. Defaults to the machine type of the template")
+	cmd.Flags().BoolVar(&f.ccs, "ccs", false, "Create a CCS (BYOC) cluster in the AWS account")
+	cmd.Flags().StringVar(&f.awsAccountID, "aws-account-id", "", "The AWS account id for CCS clusters")
+	cmd.Flags().StringSliceVar(&f.subnetIDs, "subnet-ids", []string{}, "Comma separated list of subnet ids to install a CCS cluster in an existing VPC")
+	cmd.Flags().BoolVar(&f.privateLink, "private-link", false, "Use AWS PrivateLink for a CCS cluster installed in an existing VPC")
+	cmd.Flags().DurationVar(&f.interval, "interval", time.Minute, "Interval between the checks while waiting for the cluster")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 120*time.Minute, "Max time to wait for the cluster to be ready")
+	cmd.Flags().DurationVar(&f.healthTimeout, "health-timeout", 15*time.Minute, "Max time to wait for the cluster to report healthy. Only a warning is printed if it's not healthy")
+}
+
+func newClusterCreateCmd(flags *clusterCreateFlags, outputDir string, clusters ocm.ClustersManager, subscriptions ocm.SubscriptionsManager, awsCredentials *ocm.ClusterAWS) (*clusterCreateCmd, error) {
+	configuration, err := newClusterConfiguration(flags, awsCredentials, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &clusterCreateCmd{
+		flags:         flags,
+		outputDir:     outputDir,
+		configuration: configuration,
+		clusters:      clusters,
+		subscriptions: subscriptions,
+	}, nil
+}
+
+// shortClusterName shortens the names longer than clusterNameMaxLength by replacing the end of the name
+// with a short hash of the full name, so that a rerun with the same name finds the existing cluster
+func shortClusterName(name string) string {
+	if len(name) <= clusterNameMaxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return name[:clusterNameMaxLength-4] + hex.EncodeToString(sum[:])[:4]
+}
+
+// newClusterConfiguration reads the cluster template and applies the flags to it
+func newClusterConfiguration(flags *clusterCreateFlags, awsCredentials *ocm.ClusterAWS, now time.Time) (*ocm.Cluster, error) {
+	cluster := &ocm.Cluster{}
+	if err := readJSONFile(flags.template, cluster); err != nil {
+		return nil, fmt.Errorf("failed to read the cluster template %s: %w", flags.template, err)
+	}
+
+	name := flags.name
+	if name == "" {
+		name = fmt.Sprintf("rhoam-%s", now.Format("0601021504"))
+	}
+	cluster.DisplayName = name
+	cluster.Name = shortClusterName(name)
+
+	if flags.lifespan > 0 {
+		cluster.ExpirationTimestamp = now.Add(time.Duration(flags.lifespan) * time.Hour).UTC().Format(time.RFC3339)
+	}
+	if flags.region != "" {
+		cluster.Region = &ocm.Link{ID: flags.region}
+	}
+	if flags.openshiftVersion != "" {
+		cluster.Version = &ocm.ClusterVersion{
+			Kind: "VersionLink",
+			ID:   "openshift-v" + flags.openshiftVersion,
+			Href: "/api/clusters_mgmt/v1/versions/openshift-v" + flags.openshiftVersion,
+		}
+	}
+	cluster.MultiAZ = cluster.MultiAZ || flags.multiAZ
+	if flags.private {
+		cluster.API = &ocm.ClusterAPI{Listening: "internal"}
+	}
+	if cluster.Nodes == nil {
+		cluster.Nodes = &ocm.ClusterNodes{}
+	}
+	if flags.computeNodes > 0 {
+		cluster.Nodes.Compute = flags.computeNodes
+	}
+	if flags.computeMachineType != "" {
+		cluster.Nodes.ComputeMachineType = &ocm.Link{ID: flags.computeMachineType}
+	}
+	if cluster.MultiAZ && cluster.Nodes.Compute%3 != 0 {
+		return nil, fmt.Errorf("the number of compute nodes of a multi AZ cluster must be a multiple of 3 but it is %d", cluster.Nodes.Compute)
+	}
+
+	switch flags.product {
+	case productOSD:
+	case productROSA:
+		cluster.Product = &ocm.Link{ID: productROSA}
+	default:
+		return nil, fmt.Errorf("unknown product %s", flags.product)
+	}
+
+	if flags.ccs || flags.product == productROSA {
+		if flags.awsAccountID == "" {
+			return nil, fmt.Errorf("the AWS account id is required for CCS clusters")
+		}
+		cluster.CCS = &ocm.ClusterCCS{Enabled: true}
+		cluster.AWS = &ocm.ClusterAWS{
+			AccessKeyID:     awsCredentials.AccessKeyID,
+			SecretAccessKey: awsCredentials.SecretAccessKey,
+			AccountID:       flags.awsAccountID,
+			SubnetIDs:       flags.subnetIDs,
+			PrivateLink:     flags.privateLink,
+		}
+	} else if len(flags.subnetIDs) > 0 || flags.privateLink {
+		return nil, fmt.Errorf("subnet ids and private link are only supported by CCS clusters")
+	}
+	return cluster, nil
+}
+
+func (c *clusterCreateCmd) run(ctx context.Context) error {
+	// The configuration contains the AWS credentials of CCS clusters, so print only the file location
+	configurationFile := path.Join(c.outputDir, clusterConfigurationFile)
+	if err := writeJSONFile(configurationFile, c.configuration); err != nil {
+		return err
+	}
+	fmt.Println("Cluster configuration saved to", configurationFile)
+
+	cluster, err := c.createOrGetCluster(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Cluster ID:", cluster.ID)
+	if err = writeJSONFile(path.Join(c.outputDir, clusterDetailsFile), cluster); err != nil {
+		return err
+	}
+
+	if err = waitFor(ctx, "cluster creation", c.flags.interval, c.flags.timeout, func() (bool, error) {
+		status, err := c.clusters.GetStatus(ctx, cluster.ID)
+		if err != nil {
+			return false, err
+		}
+		if status.State == ocm.ClusterStateError {
+			return false, fmt.Errorf("the installation of the cluster %s failed: %s", cluster.ID, status.ProvisionErrorMessage)
+		}
+		return status.State == ocm.ClusterStateReady, nil
+	}); err != nil {
+		return err
+	}
+
+	if cluster.Subscription != nil {
+		if err = waitFor(ctx, "cluster to be healthy", c.flags.interval, c.flags.healthTimeout, func() (bool, error) {
+			sub, err := c.subscriptions.Get(ctx, cluster.Subscription.ID)
+			if err != nil {
+				return false, err
+			}
+			return sub.HealthState() == ocm.SubscriptionHealthStateHealthy, nil
+		}); err != nil {
+			fmt.Println("Warning: the cluster is not reporting healthy, continuing anyway:", err)
+		}
+	}
+
+	var creds *ocm.ClusterCredentials
+	if err = waitFor(ctx, "fetching cluster credentials", c.flags.interval, clusterCredentialsTimeout, func() (bool, error) {
+		if creds, err = c.clusters.GetCredentials(ctx, cluster.ID); err != nil {
+			return false, err
+		}
+		return creds.Admin != nil && creds.Admin.User != "", nil
+	}); err != nil {
+		return err
+	}
+
+	credentials, err := c.saveClusterCredentials(ctx, cluster.ID, creds)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Cluster credentials saved to", path.Join(c.outputDir, clusterCredentialsFile))
+	fmt.Println("Console URL:", credentials.ConsoleURL)
+	fmt.Printf("Log in to the OSD cluster using oc:\noc login --server=%s --username=%s --password=<see %s>\n", credentials.APIURL, credentials.User, clusterCredentialsFile)
+	return nil
+}
+
+// createOrGetCluster reuses the cluster with the same name to avoid DuplicateClusterName errors
+func (c *clusterCreateCmd) createOrGetCluster(ctx context.Context) (*ocm.Cluster, error) {
+	existing, err := c.clusters.List(ctx, fmt.Sprintf("name = '%s'", c.configuration.Name))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing.Items) > 0 {
+		fmt.Printf("Cluster with the name %s already exists, continue with the existing cluster\n", c.configuration.Name)
+		return existing.Items[0], nil
+	}
+	fmt.Println("Sending a request to OCM to create the cluster", c.configuration.Name)
+	return c.clusters.Create(ctx, c.configuration)
+}
+
+func (c *clusterCreateCmd) saveClusterCredentials(ctx context.Context, clusterID string, creds *ocm.ClusterCredentials) (*clusterCredentials, error) {
+	// Get the details again as the API and console URLs are only set once the cluster is ready
+	cluster, err := c.clusters.Get(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeJSONFile(path.Join(c.outputDir, clusterDetailsFile), cluster); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(path.Join(c.outputDir, clusterKubeconfigFile), []byte(creds.Kubeconfig), 0600); err != nil {
+		return nil, err
+	}
+	credentials := &clusterCredentials{User: creds.Admin.User, Password: creds.Admin.Password}
+	if cluster.API != nil {
+		credentials.APIURL = cluster.API.URL
+	}
+	if cluster.Console != nil {
+		credentials.ConsoleURL = cluster.Console.URL
+	}
+	if err = writeJSONFile(path.Join(c.outputDir, clusterCredentialsFile), credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+)
+
+const clusterTemplate = "../templates/ocm/cluster-template.json"
+
+func TestNewClusterConfiguration(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 0, 0, time.UTC)
+	cases := []struct {
+		description string
+		flags       *clusterCreateFlags
+		expectError bool
+		verify      func(t *testing.T, c *ocm.Cluster)
+	}{
+		{
+			description: "should use the template and the default name",
+			flags:       &clusterCreateFlags{template: clusterTemplate, product: productOSD, lifespan: 4},
+			verify: func(t *testing.T, c *ocm.Cluster) {
+				// the same name is always shortened to the same value
+				if c.Name != "rhoam-220303230" {
+					t.Errorf("unexpected name %s", c.Name)
+				}
+				if c.DisplayName != "rhoam-2203040506" {
+					t.Errorf("unexpected display name %s", c.DisplayName)
+				}
+				if len(c.Name) != clusterNameMaxLength {
+					t.Errorf("expected the name to be shortened to %d characters but got %s", clusterNameMaxLength, c.Name)
+				}
+				if c.ExpirationTimestamp != "2022-03-04T09:06:00Z" {
+					t.Errorf("unexpected expiration timestamp %s", c.ExpirationTimestamp)
+				}
+				if c.Region.ID != "eu-west-1" || c.Nodes.Compute != 5 || c.Nodes.ComputeMachineType.ID != "m5.xlarge" || c.Network.MachineCIDR != "10.11.128.0/23" {
+					t.Errorf("expected the template values but got %+v", c)
+				}
+				if c.CCS.Enabled || c.AWS != nil || c.Product != nil {
+					t.Errorf("expected a non CCS OSD cluster but got %+v", c)
+				}
+			},
+		},
+		{
+			description: "should apply the flags",
+			flags: &clusterCreateFlags{
+				template:           clusterTemplate,
+				name:               "test",
+				product:            productOSD,
+				region:             "us-east-1",
+				openshiftVersion:   "4.10.3",
+				multiAZ:            true,
+				private:            true,
+				computeNodes:       6,
+				computeMachineType: "m5.2xlarge",
+			},
+			verify: func(t *testing.T, c *ocm.Cluster) {
+				if c.Name != "test" || c.DisplayName != "test" || c.ExpirationTimestamp != "" {
+					t.Errorf("unexpected name or expiration: %+v", c)
+				}
+				if c.Region.ID != "us-east-1" || !c.MultiAZ || c.API.Listening != "internal" {
+					t.Errorf("unexpected region, multi AZ or API: %+v", c)
+				}
+				if c.Version.ID != "openshift-v4.10.3" || c.Nodes.Compute != 6 || c.Nodes.ComputeMachineType.ID != "m5.2xlarge" {
+					t.Errorf("unexpected version or nodes: %+v", c)
+				}
+			},
+		},
+		{
+			description: "should configure a ROSA cluster as a CCS cluster",
+			flags:       &clusterCreateFlags{template: clusterTemplate, name: "test", product: productROSA, awsAccountID: "123", subnetIDs: []string{"a", "b"}},
+			verify: func(t *testing.T, c *ocm.Cluster) {
+				if c.Product.ID != productROSA || !c.CCS.Enabled {
+					t.Errorf("expected a CCS ROSA cluster but got %+v", c)
+				}
+				if c.AWS.AccountID != "123" || c.AWS.AccessKeyID != "key" || c.AWS.SecretAccessKey != "secret" || len(c.AWS.SubnetIDs) != 2 {
+					t.Errorf("unexpected AWS configuration %+v", c.AWS)
+				}
+			},
+		},
+		{
+			description: "should fail for CCS clusters without the AWS account id",
+			flags:       &clusterCreateFlags{template: clusterTemplate, product: productOSD, ccs: true},
+			expectError: true,
+		},
+		{
+			description: "should fail for subnet ids without CCS",
+			flags:       &clusterCreateFlags{template: clusterTemplate, product: productOSD, subnetIDs: []string{"a"}},
+			expectError: true,
+		},
+		{
+			description: "should fail for multi AZ clusters when the nodes are not a multiple of 3",
+			flags:       &clusterCreateFlags{template: clusterTemplate, product: productOSD, multiAZ: true, computeNodes: 4},
+			expectError: true,
+		},
+		{
+			description: "should fail for an unknown product",
+			flags:       &clusterCreateFlags{template: clusterTemplate, product: "unknown"},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			cluster, err := newClusterConfiguration(c.flags, &ocm.ClusterAWS{AccessKeyID: "key", SecretAccessKey: "secret"}, now)
+			if c.expectError && err == nil {
+				t.Fatal("error expected but it is nil")
+			} else if !c.expectError && err != nil {
+				t.Fatalf("error should be nil but got %v", err)
+			}
+			if c.verify != nil {
+				c.verify(t, cluster)
+			}
+		})
+	}
+}
+
+func TestClusterCreate(t *testing.T) {
+	server, client := newFakeOCMClient(t)
+	outputDir := t.TempDir()
+	flags := &clusterCreateFlags{
+		template:      clusterTemplate,
+		name:          "test",
+		product:       productOSD,
+		interval:      clusterTestInterval,
+		timeout:       time.Second,
+		healthTimeout: time.Second,
+	}
+
+	// Run twice to verify that the existing cluster is reused
+	for i := 0; i < 2; i++ {
+		c, err := newClusterCreateCmd(flags, outputDir, client.Clusters, client.Subscriptions, &ocm.ClusterAWS{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.run(context.TODO()); err != nil {
+			t.Fatalf("cluster create failed: %v", err)
+		}
+	}
+
+	clusters := server.Clusters()
+	if len(clusters) != 1 || clusters[0].State != ocm.ClusterStateReady {
+		t.Fatalf("expected 1 ready cluster but got %+v", clusters)
+	}
+
+	details := &ocm.Cluster{}
+	if err := readJSONFile(path.Join(outputDir, clusterDetailsFile), details); err != nil {
+		t.Fatal(err)
+	}
+	if details.ID != clusters[0].ID || details.API.URL == "" {
+		t.Errorf("unexpected cluster details %+v", details)
+	}
+	credentials := &clusterCredentials{}
+	if err := readJSONFile(path.Join(outputDir, clusterCredentialsFile), credentials); err != nil {
+		t.Fatal(err)
+	}
+	if credentials.User != "kubeadmin" || credentials.Password == "" || credentials.APIURL != details.API.URL || credentials.ConsoleURL != details.Console.URL {
+		t.Errorf("unexpected cluster credentials %+v", credentials)
+	}
+	kubeconfig, err := ioutil.ReadFile(path.Join(outputDir, clusterKubeconfigFile))
+	if err != nil || string(kubeconfig) != server.Kubeconfig {
+		t.Errorf("unexpected kubeconfig %s, %v", string(kubeconfig), err)
+	}
+	configuration := &ocm.Cluster{}
+	if err := readJSONFile(path.Join(outputDir, clusterConfigurationFile), configuration); err != nil || configuration.Name != "test" {
+		t.Errorf("unexpected cluster configuration %+v, %v", configuration, err)
+	}
+}
+
+func TestClusterCreate_timeout(t *testing.T) {
+	server, client := newFakeOCMClient(t)
+	server.PollsUntilDone = 1000
+	c, err := newClusterCreateCmd(&clusterCreateFlags{
+		template: clusterTemplate,
+		name:     "test",
+		product:  productOSD,
+		interval: clusterTestInterval,
+		timeout:  20 * time.Millisecond,
+	}, t.TempDir(), client.Clusters, client.Subscriptions, &ocm.ClusterAWS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.run(context.TODO()); err == nil || !strings.Contains(err.Error(), "timed out waiting for cluster creation") {
+		t.Fatalf("expected a timeout error but got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/cobra"
+)
+
+type clusterDeleteFlags struct {
+	clusterID string
+	wait      bool
+	interval  time.Duration
+	timeout   time.Duration
+}
+
+type clusterDeleteCmd struct {
+	flags     *clusterDeleteFlags
+	clusterID string
+	clusters  ocm.ClustersManager
+}
+
+func init() {
+	f := &clusterDeleteFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete an OSD cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newOCMClient(clusterFlags.ocmURL)
+			if err != nil {
+				handleError(err)
+			}
+			clusterID, err := resolveClusterID(f.clusterID, clusterFlags.outputDir)
+			if err != nil {
+				handleError(err)
+			}
+			c := &clusterDeleteCmd{flags: f, clusterID: clusterID, clusters: client.Clusters}
+			if err = c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+
+	clusterCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&f.clusterID, "cluster-id", "", "ID of the cluster. Defaults to the cluster saved in the output directory")
+	cmd.Flags().BoolVar(&f.wait, "wait", false, "Wait for the cluster to be uninstalled")
+	cmd.Flags().DurationVar(&f.interval, "interval", time.Minute, "Interval between the checks while waiting for the cluster to be uninstalled")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 60*time.Minute, "Max time to wait for the cluster to be uninstalled")
+}
+
+func (c *clusterDeleteCmd) run(ctx context.Context) error {
+	fmt.Println("Deleting the cluster with ID:", c.clusterID)
+	if err := c.clusters.Delete(ctx, c.clusterID); err != nil {
+		return err
+	}
+	if !c.flags.wait {
+		return nil
+	}
+	return waitFor(ctx, "cluster uninstallation", c.flags.interval, c.flags.timeout, func() (bool, error) {
+		_, err := c.clusters.GetStatus(ctx, c.clusterID)
+		if ocm.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
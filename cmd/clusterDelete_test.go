@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClusterDelete(t *testing.T) {
+	server, client := newFakeOCMClient(t)
+	cluster := createReadyCluster(t, client, "test")
+
+	c := &clusterDeleteCmd{
+		flags:     &clusterDeleteFlags{wait: true, interval: clusterTestInterval, timeout: time.Second},
+		clusterID: cluster.ID,
+		clusters:  client.Clusters,
+	}
+	if err := c.run(context.TODO()); err != nil {
+		t.Fatalf("cluster delete failed: %v", err)
+	}
+	if server.Cluster(cluster.ID) != nil {
+		t.Fatalf("expected the cluster %s to be deleted", cluster.ID)
+	}
+
+	if err := c.run(context.TODO()); err == nil {
+		t.Fatal("expected an error when deleting an unknown cluster")
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/cobra"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var clusterResourceQuotaResource = schema.GroupVersionResource{Group: "quota.openshift.io", Version: "v1", Resource: "clusterresourcequotas"}
+
+// Mandatory parameters of the addons with their default values
+var defaultAddonParameters = map[string]map[string]string{
+	"managed-api-service": {
+		"cidr-range":                "10.1.0.0/26",
+		"addon-managed-api-service": "20",
+	},
+	"managed-odh": {
+		"notification-email": "email@example.com",
+	},
+}
+
+// Cluster resource quotas applied to the cluster with --apply-quotas
+var clusterQuotaTemplates = []string{"load-balancer-cluster-quota.json", "cluster-storage-quota.json"}
+
+type clusterInstallAddonFlags struct {
+	clusterID    string
+	addonID      string
+	parameters   []string
+	wait         bool
+	interval     time.Duration
+	timeout      time.Duration
+	applyQuotas  bool
+	templatesDir string
+}
+
+type clusterInstallAddonCmd struct {
+	flags         *clusterInstallAddonFlags
+	clusterID     string
+	addon         *ocm.AddonInstallation
+	clusters      ocm.ClustersManager
+	dynamicClient dynamic.Interface
+}
+
+func init() {
+	f := &clusterInstallAddonFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "install-addon",
+		Short: "Install an addon on an OSD cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newOCMClient(clusterFlags.ocmURL)
+			if err != nil {
+				handleError(err)
+			}
+			clusterID, err := resolveClusterID(f.clusterID, clusterFlags.outputDir)
+			if err != nil {
+				handleError(err)
+			}
+			var dynamicClient dynamic.Interface
+			if f.applyQuotas {
+				config, err := clientcmd.BuildConfigFromFlags("", path.Join(clusterFlags.outputDir, clusterKubeconfigFile))
+				if err != nil {
+					handleError(err)
+				}
+				if dynamicClient, err = dynamic.NewForConfig(config); err != nil {
+					handleError(err)
+				}
+			}
+			c, err := newClusterInstallAddonCmd(f, clusterID, client.Clusters, dynamicClient)
+			if err != nil {
+				handleError(err)
+			}
+			if err = c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+
+	clusterCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&f.clusterID, "cluster-id", "", "ID of the cluster. Defaults to the cluster saved in the output directory")
+	cmd.Flags().StringVar(&f.addonID, "addon", "", "ID of the addon to install (ex. managed-api-service)")
+	cmd.MarkFlagRequired("addon")
+	cmd.Flags().StringArrayVar(&f.parameters, "param", []string{}, "Addon parameter in the format id=value. Can be repeated")
+	cmd.Flags().BoolVar(&f.wait, "wait", true, "Wait for the addon to be ready")
+	cmd.Flags().DurationVar(&f.interval, "interval", time.Minute, "Interval between the checks while waiting for the addon")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 90*time.Minute, "Max time to wait for the addon to be ready")
+	cmd.Flags().BoolVar(&f.applyQuotas, "apply-quotas", false, "Apply the load balancer and storage cluster resource quotas to the cluster")
+	cmd.Flags().StringVar(&f.templatesDir, "templates-dir", "templates/ocm", "Directory containing the cluster resource quota templates")
+}
+
+func newClusterInstallAddonCmd(flags *clusterInstallAddonFlags, clusterID string, clusters ocm.ClustersManager, dynamicClient dynamic.Interface) (*clusterInstallAddonCmd, error) {
+	params, err := addonParameters(flags.addonID, flags.parameters)
+	if err != nil {
+		return nil, err
+	}
+	addon := &ocm.AddonInstallation{Addon: &ocm.Link{ID: flags.addonID}}
+	if len(params) > 0 {
+		addon.Parameters = &ocm.AddonParameters{Items: params}
+	}
+	return &clusterInstallAddonCmd{
+		flags:         flags,
+		clusterID:     clusterID,
+		addon:         addon,
+		clusters:      clusters,
+		dynamicClient: dynamicClient,
+	}, nil
+}
+
+// addonParameters merges the given id=value parameters with the defaults of the addon
+func addonParameters(addonID string, parameters []string) ([]*ocm.AddonParameter, error) {
+	values := map[string]string{}
+	for id, value := range defaultAddonParameters[addonID] {
+		values[id] = value
+	}
+	for _, p := range parameters {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid addon parameter %q, expected the format id=value", p)
+		}
+		values[kv[0]] = kv[1]
+	}
+
+	var ids []string
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var params []*ocm.AddonParameter
+	for _, id := range ids {
+		params = append(params, &ocm.AddonParameter{ID: id, Value: values[id]})
+	}
+	return params, nil
+}
+
+func (c *clusterInstallAddonCmd) run(ctx context.Context) error {
+	fmt.Printf("Applying the %s addon on the cluster %s\n", c.addon.Addon.ID, c.clusterID)
+	if _, err := c.clusters.InstallAddon(ctx, c.clusterID, c.addon); err != nil {
+		return err
+	}
+
+	if c.dynamicClient != nil {
+		if err := c.applyClusterQuotas(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !c.flags.wait {
+		return nil
+	}
+	return waitFor(ctx, fmt.Sprintf("%s addon to be installed", c.addon.Addon.ID), c.flags.interval, c.flags.timeout, func() (bool, error) {
+		addon, err := c.clusters.GetAddon(ctx, c.clusterID, c.addon.Addon.ID)
+		if err != nil {
+			return false, err
+		}
+		if addon.State == ocm.AddonStateFailed {
+			return false, fmt.Errorf("the installation of the %s addon failed", c.addon.Addon.ID)
+		}
+		return addon.State == ocm.AddonStateReady, nil
+	})
+}
+
+func (c *clusterInstallAddonCmd) applyClusterQuotas(ctx context.Context) error {
+	for _, t := range clusterQuotaTemplates {
+		quota := &unstructured.Unstructured{}
+		if err := readJSONFile(path.Join(c.flags.templatesDir, t), &quota.Object); err != nil {
+			return fmt.Errorf("failed to read the quota template %s: %w", t, err)
+		}
+		fmt.Println("Create the cluster resource quota", quota.GetName())
+		_, err := c.dynamicClient.Resource(clusterResourceQuotaResource).Create(ctx, quota, metav1.CreateOptions{})
+		if err != nil && !k8serr.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestAddonParameters(t *testing.T) {
+	cases := []struct {
+		description string
+		addonID     string
+		parameters  []string
+		expected    []*ocm.AddonParameter
+		expectError bool
+	}{
+		{
+			description: "should add the default parameters of the addon",
+			addonID:     "managed-api-service",
+			parameters:  []string{"addon-managed-api-service=1", "notification-email=test@example.com"},
+			expected: []*ocm.AddonParameter{
+				{ID: "addon-managed-api-service", Value: "1"},
+				{ID: "cidr-range", Value: "10.1.0.0/26"},
+				{ID: "notification-email", Value: "test@example.com"},
+			},
+		},
+		{
+			description: "should return no parameters for an addon without defaults",
+			addonID:     "unknown",
+		},
+		{
+			description: "should keep = in the value",
+			addonID:     "unknown",
+			parameters:  []string{"a=b=c"},
+			expected:    []*ocm.AddonParameter{{ID: "a", Value: "b=c"}},
+		},
+		{
+			description: "should fail for invalid parameters",
+			addonID:     "unknown",
+			parameters:  []string{"invalid"},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			params, err := addonParameters(c.addonID, c.parameters)
+			if c.expectError && err == nil {
+				t.Fatal("error expected but it is nil")
+			} else if !c.expectError && err != nil {
+				t.Fatalf("error should be nil but got %v", err)
+			}
+			if !reflect.DeepEqual(params, c.expected) {
+				t.Fatalf("expected %v but got %v", c.expected, params)
+			}
+		})
+	}
+}
+
+func TestClusterInstallAddon(t *testing.T) {
+	server, client := newFakeOCMClient(t)
+	cluster := createReadyCluster(t, client, "test")
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	c, err := newClusterInstallAddonCmd(&clusterInstallAddonFlags{
+		addonID:      "managed-api-service",
+		parameters:   []string{"addon-managed-api-service=1"},
+		wait:         true,
+		interval:     clusterTestInterval,
+		timeout:      time.Second,
+		applyQuotas:  true,
+		templatesDir: "../templates/ocm",
+	}, cluster.ID, client.Clusters, dynamicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.run(context.TODO()); err != nil {
+		t.Fatalf("install addon failed: %v", err)
+	}
+
+	addon := server.Addon(cluster.ID, "managed-api-service")
+	if addon == nil || addon.State != ocm.AddonStateReady || len(addon.Parameters.Items) != 2 {
+		t.Fatalf("expected the addon to be ready but got %+v", addon)
+	}
+	for _, name := range []string{"rhmi-loadbalancer-quota", "rhmi-persistent-volume-quota"} {
+		if _, err := dynamicClient.Resource(clusterResourceQuotaResource).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected the cluster resource quota %s to be created: %v", name, err)
+		}
+	}
+}
+
+func TestClusterInstallAddon_clusterNotReady(t *testing.T) {
+	_, client := newFakeOCMClient(t)
+	cluster, err := client.Clusters.Create(context.TODO(), &ocm.Cluster{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newClusterInstallAddonCmd(&clusterInstallAddonFlags{addonID: "managed-odh"}, cluster.ID, client.Clusters, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.run(context.TODO()); err == nil {
+		t.Fatal("expected the addon installation to fail on a cluster that is not ready")
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/cobra"
+)
+
+type clusterLogsCmd struct {
+	clusterID     string
+	outputDir     string
+	clusters      ocm.ClustersManager
+	subscriptions ocm.SubscriptionsManager
+}
+
+func init() {
+	var clusterID string
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Save the installation logs and the subscription details of an OSD cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newOCMClient(clusterFlags.ocmURL)
+			if err != nil {
+				handleError(err)
+			}
+			id, err := resolveClusterID(clusterID, clusterFlags.outputDir)
+			if err != nil {
+				handleError(err)
+			}
+			c := &clusterLogsCmd{
+				clusterID:     id,
+				outputDir:     clusterFlags.outputDir,
+				clusters:      client.Clusters,
+				subscriptions: client.Subscriptions,
+			}
+			if err = c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+
+	clusterCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&clusterID, "cluster-id", "", "ID of the cluster. Defaults to the cluster saved in the output directory")
+}
+
+func (c *clusterLogsCmd) run(ctx context.Context) error {
+	if err := os.MkdirAll(c.outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	log, err := c.clusters.GetInstallLog(ctx, c.clusterID)
+	if err != nil {
+		return err
+	}
+	logsFile := path.Join(c.outputDir, clusterInstallationLogsFile)
+	if err = ioutil.WriteFile(logsFile, []byte(log.Content), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Cluster installation logs saved to", logsFile)
+
+	cluster, err := c.clusters.Get(ctx, c.clusterID)
+	if err != nil {
+		return err
+	}
+	if cluster.Subscription == nil {
+		fmt.Println("The cluster has no subscription")
+		return nil
+	}
+	sub, err := c.subscriptions.Get(ctx, cluster.Subscription.ID)
+	if err != nil {
+		return err
+	}
+	subFile := path.Join(c.outputDir, clusterSubscriptionFile)
+	if err = writeJSONFile(subFile, sub); err != nil {
+		return err
+	}
+	fmt.Println("Cluster subscription details saved to", subFile)
+	return nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+)
+
+func TestClusterLogs(t *testing.T) {
+	_, client := newFakeOCMClient(t)
+	cluster := createReadyCluster(t, client, "test")
+	outputDir := path.Join(t.TempDir(), "ocm")
+
+	c := &clusterLogsCmd{
+		clusterID:     cluster.ID,
+		outputDir:     outputDir,
+		clusters:      client.Clusters,
+		subscriptions: client.Subscriptions,
+	}
+	if err := c.run(context.TODO()); err != nil {
+		t.Fatalf("cluster logs failed: %v", err)
+	}
+
+	logs, err := ioutil.ReadFile(path.Join(outputDir, clusterInstallationLogsFile))
+	if err != nil || !strings.Contains(string(logs), "installation log of test") {
+		t.Errorf("unexpected installation logs %s, %v", string(logs), err)
+	}
+	sub := &ocm.Subscription{}
+	if err = readJSONFile(path.Join(outputDir, clusterSubscriptionFile), sub); err != nil || sub.ClusterID != cluster.ID {
+		t.Errorf("unexpected subscription %+v, %v", sub, err)
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/spf13/cobra"
+)
+
+const (
+	latestOpenshiftVersion = "latest"
+	// OCM requires the next run of a manual upgrade policy to be a few minutes in the future
+	upgradeScheduleDelay = 6 * time.Minute
+
+	upgradePolicyStateCompleted = "completed"
+	upgradePolicyStateFailed    = "failed"
+	upgradePolicyStateCancelled = "cancelled"
+)
+
+type clusterUpgradeFlags struct {
+	clusterID string
+	toVersion string
+	wait      bool
+	interval  time.Duration
+	timeout   time.Duration
+}
+
+type clusterUpgradeCmd struct {
+	flags     *clusterUpgradeFlags
+	clusterID string
+	clusters  ocm.ClustersManager
+}
+
+func init() {
+	f := &clusterUpgradeFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the OpenShift version of an OSD cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newOCMClient(clusterFlags.ocmURL)
+			if err != nil {
+				handleError(err)
+			}
+			clusterID, err := resolveClusterID(f.clusterID, clusterFlags.outputDir)
+			if err != nil {
+				handleError(err)
+			}
+			c := &clusterUpgradeCmd{flags: f, clusterID: clusterID, clusters: client.Clusters}
+			if err = c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+
+	clusterCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&f.clusterID, "cluster-id", "", "ID of the cluster. Defaults to the cluster saved in the output directory")
+	cmd.Flags().StringVar(&f.toVersion, "to-version", latestOpenshiftVersion, "OpenShift version to upgrade to (ex. 4.10.4), or latest for the latest available upgrade")
+	cmd.Flags().BoolVar(&f.wait, "wait", true, "Wait for the upgrade to complete")
+	cmd.Flags().DurationVar(&f.interval, "interval", 5*time.Minute, "Interval between the checks while waiting for the upgrade")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 120*time.Minute, "Max time to wait for the upgrade to complete")
+}
+
+func (c *clusterUpgradeCmd) run(ctx context.Context) error {
+	cluster, err := c.clusters.Get(ctx, c.clusterID)
+	if err != nil {
+		return err
+	}
+	var available []string
+	if cluster.Version != nil {
+		available = cluster.Version.AvailableUpgrades
+	}
+	if len(available) == 0 {
+		fmt.Println("No upgrade available for the cluster", c.clusterID)
+		return nil
+	}
+
+	version, err := selectUpgradeVersion(available, c.flags.toVersion)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schedule the upgrade of the cluster %s from %s to %s\n", c.clusterID, cluster.OpenshiftVersion, version)
+	policy, err := c.clusters.CreateUpgradePolicy(ctx, c.clusterID, &ocm.UpgradePolicy{
+		ScheduleType: ocm.UpgradePolicyScheduleManual,
+		UpgradeType:  ocm.UpgradePolicyTypeOSD,
+		Version:      version,
+		NextRun:      time.Now().Add(upgradeScheduleDelay).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.flags.wait {
+		return nil
+	}
+	return waitFor(ctx, "OpenShift upgrade", c.flags.interval, c.flags.timeout, func() (bool, error) {
+		state, err := c.clusters.GetUpgradePolicyState(ctx, c.clusterID, policy.ID)
+		if err != nil {
+			return false, err
+		}
+		switch state.Value {
+		case upgradePolicyStateCompleted:
+			return true, nil
+		case upgradePolicyStateFailed, upgradePolicyStateCancelled:
+			return false, fmt.Errorf("the upgrade of the cluster %s to %s is %s: %s", c.clusterID, version, state.Value, state.Description)
+		}
+		return false, nil
+	})
+}
+
+// selectUpgradeVersion returns the requested version if it is available, or the latest available version
+func selectUpgradeVersion(available []string, toVersion string) (string, error) {
+	if toVersion != latestOpenshiftVersion {
+		for _, v := range available {
+			if v == toVersion {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("the version %s is not an available upgrade, available upgrades: %v", toVersion, available)
+	}
+
+	var latest semver.Version
+	var latestRaw string
+	for _, v := range available {
+		sv, err := semver.ParseTolerant(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid upgrade version %s: %w", v, err)
+		}
+		if latestRaw == "" || sv.GT(latest) {
+			latest, latestRaw = sv, v
+		}
+	}
+	return latestRaw, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSelectUpgradeVersion(t *testing.T) {
+	cases := []struct {
+		description string
+		available   []string
+		toVersion   string
+		expected    string
+		expectError bool
+	}{
+		{
+			description: "should select the latest version",
+			available:   []string{"4.9.10", "4.10.3", "4.9.21"},
+			toVersion:   latestOpenshiftVersion,
+			expected:    "4.10.3",
+		},
+		{
+			description: "should select the requested version",
+			available:   []string{"4.9.10", "4.10.3"},
+			toVersion:   "4.9.10",
+			expected:    "4.9.10",
+		},
+		{
+			description: "should fail if the requested version is not available",
+			available:   []string{"4.9.10"},
+			toVersion:   "4.10.3",
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			version, err := selectUpgradeVersion(c.available, c.toVersion)
+			if c.expectError && err == nil {
+				t.Fatal("error expected but it is nil")
+			} else if !c.expectError && err != nil {
+				t.Fatalf("error should be nil but got %v", err)
+			}
+			if version != c.expected {
+				t.Fatalf("expected %s but got %s", c.expected, version)
+			}
+		})
+	}
+}
+
+func TestClusterUpgrade(t *testing.T) {
+	server, client := newFakeOCMClient(t)
+	cluster := createReadyCluster(t, client, "test")
+	toVersion := cluster.Version.AvailableUpgrades[0]
+
+	c := &clusterUpgradeCmd{
+		flags:     &clusterUpgradeFlags{toVersion: latestOpenshiftVersion, wait: true, interval: clusterTestInterval, timeout: time.Second},
+		clusterID: cluster.ID,
+		clusters:  client.Clusters,
+	}
+	if err := c.run(context.TODO()); err != nil {
+		t.Fatalf("cluster upgrade failed: %v", err)
+	}
+	if v := server.Cluster(cluster.ID).OpenshiftVersion; v != toVersion {
+		t.Fatalf("expected the cluster to be upgraded to %s but got %s", toVersion, v)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+	"github.com/integr8ly/delorean/pkg/ocm/fake"
+)
+
+const clusterTestInterval = time.Millisecond
+
+// newFakeOCMClient starts a fake OCM server and returns a client configured to use it
+func newFakeOCMClient(t *testing.T) (*fake.Server, *ocm.Client) {
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+	client := ocm.NewClient(nil)
+	client.BaseURL, _ = client.BaseURL.Parse(server.URL + "/")
+	return server, client
+}
+
+// createReadyCluster creates a cluster in the fake OCM server and polls it until it's ready
+func createReadyCluster(t *testing.T, client *ocm.Client, name string) *ocm.Cluster {
+	cluster, err := client.Clusters.Create(context.TODO(), &ocm.Cluster{Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		status, err := client.Clusters.GetStatus(context.TODO(), cluster.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State == ocm.ClusterStateReady {
+			return cluster
+		}
+	}
+	t.Fatalf("cluster %s is not ready", cluster.ID)
+	return nil
+}
+
+func TestResolveClusterID(t *testing.T) {
+	outputDir := t.TempDir()
+	if _, err := resolveClusterID("", outputDir); err == nil {
+		t.Fatal("expected an error without cluster id and cluster details")
+	}
+	if id, err := resolveClusterID("abc", outputDir); err != nil || id != "abc" {
+		t.Fatalf("expected the given cluster id but got %s, %v", id, err)
+	}
+	if err := writeJSONFile(path.Join(outputDir, clusterDetailsFile), &ocm.Cluster{ID: "def"}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := resolveClusterID("", outputDir); err != nil || id != "def" {
+		t.Fatalf("expected the cluster id from the details but got %s, %v", id, err)
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	calls := 0
+	err := waitFor(context.TODO(), "test", clusterTestInterval, time.Second, func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected waitFor to succeed after 3 calls but got %d calls, %v", calls, err)
+	}
+
+	err = waitFor(context.TODO(), "test", clusterTestInterval, 10*time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting for test") {
+		t.Fatalf("expected a timeout error but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err = waitFor(ctx, "test", time.Hour, time.Hour, func() (bool, error) {
+		return false, nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected the context error but got %v", err)
+	}
+}
+
+func TestClusterOCMToken(t *testing.T) {
+	t.Setenv("OCM_TOKEN", "env-token")
+	if token, err := requireValue(clusterOCMTokenKey); err != nil || token != "env-token" {
+		t.Fatalf("expected the token of the env var but got %q: %v", token, err)
+	}
+
+	create, _, err := rootCmd.Find([]string{"cluster", "create"})
+	if err != nil {
+		t.Fatalf("cluster create not found: %v", err)
+	}
+	addon, _, err := rootCmd.Find([]string{"release", "osd-addon"})
+	if err != nil {
+		t.Fatalf("osd-addon not found: %v", err)
+	}
+	defer func() {
+		clusterFlag, addonFlag := clusterCmd.PersistentFlags().Lookup("ocm-token"), addon.Flags().Lookup("ocm-token")
+		clusterFlag.Value.Set("")
+		clusterFlag.Changed = false
+		addonFlag.Value.Set("")
+		addonFlag.Changed = false
+	}()
+	if err := create.ParseFlags([]string{"--ocm-token", "cluster-token"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addon.ParseFlags([]string{"--ocm-token", "addon-token"}); err != nil {
+		t.Fatal(err)
+	}
+	// each command reads the value of its own flag
+	if token, _ := requireValue(clusterOCMTokenKey); token != "cluster-token" {
+		t.Errorf("expected the token of the cluster flag but got %q", token)
+	}
+	if token, _ := requireValue(ocmTokenKey); token != "addon-token" {
+		t.Errorf("expected the token of the osd-addon flag but got %q", token)
+	}
+}
@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/integr8ly/delorean/pkg/utils"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type compareDowntimeCmdFlags struct {
+	bucket           string
+	threshold        float64
+	failOnRegression bool
+}
+
+type compareDowntimeCmd struct {
+	reports          []string
+	bucket           string
+	downloader       s3manageriface.DownloaderAPI
+	threshold        float64
+	failOnRegression bool
+	out              io.Writer
+}
+
+// downtimeComparison has the values of the queries of each report, grouped by product. A value is nil
+// when the query is not in the report
+type downtimeComparison struct {
+	Labels   []string
+	Products []*productComparison
+	// Skipped are the queries which are not compared, like the range queries
+	Skipped []string
+}
+
+type productComparison struct {
+	Name    string
+	Queries []*queryComparison
+}
+
+type queryComparison struct {
+	Name   string
+	Values []*float64
+}
+
+func init() {
+	f := &compareDowntimeCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "compare-downtime <report> <report>...",
+		Short: "Compare the downtime reports of two or more upgrades",
+		Long: `Compare the downtime reports generated by query-report or measure-downtime, like the upgrades to two versions.
+The reports are joined on the query names and the values of each report are compared with the previous report.
+The value of a query is the largest of its values and the products are the prefixes of the query names.
+Only the results of instant queries are compared, since the range queries, like the availability of the endpoints,
+are not measured in seconds of downtime.
+There is no total per product, since the queries of a product measure the same outages in different ways, like
+the k8s endpoints, the blackbox probes and the workload app. A query missing from some reports is flagged as missing.
+The reports are local files, or the keys of the reports in the S3 bucket if --s3-bucket is set`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var ses *session.Session = nil
+			if f.bucket != "" {
+				awsKeyId, err := requireValue(AWSAccessKeyIDEnv)
+				if err != nil {
+					handleError(err)
+				}
+				awsSecretKey, err := requireValue(AWSSecretAccessKeyEnv)
+				if err != nil {
+					handleError(err)
+				}
+				ses = session.Must(session.NewSession(&aws.Config{
+					Region:      aws.String(AWSDefaultRegion),
+					Credentials: credentials.NewStaticCredentials(awsKeyId, awsSecretKey, ""),
+				}))
+			}
+
+			c := newCompareDowntimeCmd(args, f, ses)
+			if err := c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+	reportCmd.AddCommand(cmd)
+	cmd.Flags().StringVarP(&f.bucket, "s3-bucket", "b", "", "The S3 bucket to download the reports from")
+	cmd.Flags().Float64Var(&f.threshold, "threshold", 0, "Increase of the downtime in seconds over which a query is a regression")
+	cmd.Flags().BoolVar(&f.failOnRegression, "fail-on-regression", false, "Fail if there is any regression")
+
+	cmd.Flags().String("aws-key-id", "", fmt.Sprintf("The AWS key id to use. Can be set via the %s env var", strings.ToUpper(AWSAccessKeyIDEnv)))
+	viper.BindPFlag(AWSAccessKeyIDEnv, cmd.Flags().Lookup("aws-key-id"))
+	cmd.Flags().String("aws-secret-key", "", fmt.Sprintf("The AWS secret key to use. Can be set via the %s env var", strings.ToUpper(AWSSecretAccessKeyEnv)))
+	viper.BindPFlag(AWSSecretAccessKeyEnv, cmd.Flags().Lookup("aws-secret-key"))
+}
+
+func newCompareDowntimeCmd(reports []string, f *compareDowntimeCmdFlags, session *session.Session) *compareDowntimeCmd {
+	var downloader s3manageriface.DownloaderAPI = nil
+	if session != nil {
+		downloader = s3manager.NewDownloader(session)
+	}
+	return &compareDowntimeCmd{
+		reports:          reports,
+		bucket:           f.bucket,
+		downloader:       downloader,
+		threshold:        f.threshold,
+		failOnRegression: f.failOnRegression,
+		out:              os.Stdout,
+	}
+}
+
+func (c *compareDowntimeCmd) run(ctx context.Context) error {
+	var reports []*queryResults
+	var labels []string
+	for _, report := range c.reports {
+		r, err := c.loadReport(ctx, report)
+		if err != nil {
+			return fmt.Errorf("failed to load the report %s: %w", report, err)
+		}
+		reports = append(reports, r)
+		labels = append(labels, reportLabel(r, report, labels))
+	}
+
+	comparison := compareDowntimeReports(reports, labels)
+	regressions := comparison.regressions(c.threshold)
+	if err := comparison.write(c.out, c.threshold); err != nil {
+		return err
+	}
+	if len(comparison.Skipped) > 0 {
+		fmt.Fprintf(c.out, "\n%d queries skipped, which are not instant queries: %s\n", len(comparison.Skipped), strings.Join(comparison.Skipped, ", "))
+	}
+	if missing := comparison.missing(); len(missing) > 0 {
+		fmt.Fprintf(c.out, "\n%d queries missing from some reports: %s\n", len(missing), strings.Join(missing, ", "))
+	}
+	if len(regressions) == 0 {
+		fmt.Fprintf(c.out, "\nNo regression over %s seconds\n", formatValue(c.threshold))
+		return nil
+	}
+	fmt.Fprintf(c.out, "\n%d regressions over %s seconds: %s\n", len(regressions), formatValue(c.threshold), strings.Join(regressions, ", "))
+	if c.failOnRegression {
+		return fmt.Errorf("the downtime regressed for %s", strings.Join(regressions, ", "))
+	}
+	return nil
+}
+
+// loadReport reads the report from the file, or from the S3 bucket if set
+func (c *compareDowntimeCmd) loadReport(ctx context.Context, report string) (*queryResults, error) {
+	file := report
+	if c.downloader != nil {
+		downloaded, err := utils.DownloadS3ObjectToTempDir(ctx, c.downloader, c.bucket, report)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(downloaded)
+		file = downloaded
+	}
+	r := &queryResults{}
+	if err := utils.PopulateObjectFromYAML(file, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reportLabel is the version of the report, or the name of the file if the version is missing or was already used
+func reportLabel(r *queryResults, report string, labels []string) string {
+	if r.Version == "" {
+		return path.Base(report)
+	}
+	for _, l := range labels {
+		if l == r.Version {
+			return fmt.Sprintf("%s (%s)", r.Version, path.Base(report))
+		}
+	}
+	return r.Version
+}
+
+// compareDowntimeReports joins the reports on the query names of the scalar and vector results. The products and
+// the queries are in the order they first appear in the reports
+func compareDowntimeReports(reports []*queryResults, labels []string) *downtimeComparison {
+	comparison := &downtimeComparison{Labels: labels}
+	products := map[string]*productComparison{}
+	queries := map[string]*queryComparison{}
+	skipped := map[string]bool{}
+	for i, r := range reports {
+		for _, qr := range r.Results {
+			switch qr.v.(type) {
+			case *model.Scalar, model.Vector:
+			default:
+				if !skipped[qr.Name] {
+					skipped[qr.Name] = true
+					comparison.Skipped = append(comparison.Skipped, qr.Name)
+				}
+				continue
+			}
+			values, err := resultValues(qr.v)
+			if err != nil || len(values) == 0 {
+				continue
+			}
+			value := values[0].value
+			for _, v := range values[1:] {
+				if v.value > value {
+					value = v.value
+				}
+			}
+
+			name := strings.Split(qr.Name, "_")[0]
+			p, ok := products[name]
+			if !ok {
+				p = &productComparison{Name: name}
+				products[name] = p
+				comparison.Products = append(comparison.Products, p)
+			}
+			q, ok := queries[qr.Name]
+			if !ok {
+				q = &queryComparison{Name: qr.Name, Values: make([]*float64, len(reports))}
+				queries[qr.Name] = q
+				p.Queries = append(p.Queries, q)
+			}
+			// a query can be repeated in a report, like with different endpoints of the same service
+			if q.Values[i] == nil || value > *q.Values[i] {
+				q.Values[i] = &value
+			}
+		}
+	}
+	return comparison
+}
+
+// downtimeDeltas returns the difference of each value with the previous one, nil if any of the two is missing
+func downtimeDeltas(values []*float64) []*float64 {
+	deltas := make([]*float64, len(values))
+	for i := 1; i < len(values); i++ {
+		if values[i] != nil && values[i-1] != nil {
+			d := *values[i] - *values[i-1]
+			deltas[i] = &d
+		}
+	}
+	return deltas
+}
+
+func isDowntimeRegression(values []*float64, threshold float64) bool {
+	for _, d := range downtimeDeltas(values) {
+		if d != nil && *d > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// isMissing is true if the query is not in all the reports, like a query added in a newer version
+func (q *queryComparison) isMissing() bool {
+	for _, v := range q.Values {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the queries that are not in all the reports
+func (c *downtimeComparison) missing() []string {
+	var missing []string
+	for _, p := range c.Products {
+		for _, q := range p.Queries {
+			if q.isMissing() {
+				missing = append(missing, q.Name)
+			}
+		}
+	}
+	return missing
+}
+
+// regressions returns the queries whose downtime increased more than the threshold
+func (c *downtimeComparison) regressions(threshold float64) []string {
+	var regressions []string
+	for _, p := range c.Products {
+		for _, q := range p.Queries {
+			if isDowntimeRegression(q.Values, threshold) {
+				regressions = append(regressions, q.Name)
+			}
+		}
+	}
+	return regressions
+}
+
+// write prints a table for each product with the downtime of each query
+func (c *downtimeComparison) write(out io.Writer, threshold float64) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := []string{"QUERY", c.Labels[0]}
+	for _, l := range c.Labels[1:] {
+		header = append(header, l, "DELTA")
+	}
+	row := func(q *queryComparison) {
+		columns := []string{q.Name, formatDowntime(q.Values[0], false)}
+		deltas := downtimeDeltas(q.Values)
+		for i := 1; i < len(q.Values); i++ {
+			columns = append(columns, formatDowntime(q.Values[i], false), formatDowntime(deltas[i], true))
+		}
+		if isDowntimeRegression(q.Values, threshold) {
+			columns = append(columns, "REGRESSION")
+		}
+		if q.isMissing() {
+			columns = append(columns, "MISSING")
+		}
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+
+	for i, p := range c.Products {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Product %s\n", p.Name)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, q := range p.Queries {
+			row(q)
+		}
+	}
+	return w.Flush()
+}
+
+func formatDowntime(v *float64, sign bool) string {
+	if v == nil {
+		return "-"
+	}
+	if sign && *v > 0 {
+		return "+" + formatValue(*v)
+	}
+	return formatValue(*v)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+const (
+	compareDowntimeReport1 = "./testdata/compareDowntime/downtime-report-1.39.0.yaml"
+	compareDowntimeReport2 = "./testdata/compareDowntime/downtime-report-1.40.0.yaml"
+)
+
+func TestCompareDowntimeCmd(t *testing.T) {
+	cases := []struct {
+		description      string
+		threshold        float64
+		failOnRegression bool
+		expectError      bool
+		expectedOutput   string
+	}{
+		{
+			description: "print the regressions",
+			threshold:   10,
+			// zync is missing from the newer report and ups from the older one, which are not regressions, and the
+			// availability of apicast is a range query
+			expectedOutput: `Product 3scale
+QUERY                                                    1.39.0  1.40.0  DELTA
+3scale_apicast_production_k8s_endpoint_downtime_seconds  10      70      +60  REGRESSION
+3scale_zync_k8s_endpoint_downtime_seconds                30      -       -    MISSING
+
+Product rhsso
+QUERY                               1.39.0  1.40.0  DELTA
+rhsso_ui_blackbox_downtime_seconds  8       2       -6
+
+Product ups
+QUERY                             1.39.0  1.40.0  DELTA
+ups_ui_blackbox_downtime_seconds  -       0       -  MISSING
+
+1 queries skipped, which are not instant queries: 3scale_apicast_production_k8s_endpoint_available
+
+2 queries missing from some reports: 3scale_zync_k8s_endpoint_downtime_seconds, ups_ui_blackbox_downtime_seconds
+
+1 regressions over 10 seconds: 3scale_apicast_production_k8s_endpoint_downtime_seconds
+`,
+		},
+		{
+			description:      "fail on regression",
+			threshold:        10,
+			failOnRegression: true,
+			expectError:      true,
+		},
+		{
+			description:      "no regression over the threshold",
+			threshold:        60,
+			failOnRegression: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := &compareDowntimeCmd{
+				reports:          []string{compareDowntimeReport1, compareDowntimeReport2},
+				threshold:        c.threshold,
+				failOnRegression: c.failOnRegression,
+				out:              &out,
+			}
+			err := cmd.run(context.TODO())
+			if c.expectError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.expectedOutput != "" && out.String() != c.expectedOutput {
+				t.Fatalf("unexpected output:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestCompareDowntimeCmd_s3(t *testing.T) {
+	var keys []string
+	var out bytes.Buffer
+	cmd := &compareDowntimeCmd{
+		reports: []string{"downtime-report-a.yaml", "downtime-report-b.yaml", "downtime-report-c.yaml"},
+		bucket:  "reports",
+		downloader: &utils.MockS3Downloader{
+			DownloadFunc: func(o io.WriterAt, input *s3.GetObjectInput) (int64, error) {
+				keys = append(keys, *input.Key)
+				report := compareDowntimeReport1
+				if *input.Key != "downtime-report-a.yaml" {
+					report = compareDowntimeReport2
+				}
+				content, err := ioutil.ReadFile(report)
+				if err != nil {
+					return 0, err
+				}
+				b, err := o.WriteAt(content, 0)
+				return int64(b), err
+			},
+		},
+		out: &out,
+	}
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(keys, ",") != "downtime-report-a.yaml,downtime-report-b.yaml,downtime-report-c.yaml" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	header := "QUERY                                                    1.39.0  1.40.0  DELTA  1.40.0 (downtime-report-c.yaml)  DELTA"
+	if !strings.Contains(out.String(), header) {
+		t.Fatalf("expected the header %q in the output:\n%s", header, out.String())
+	}
+	if !strings.Contains(out.String(), "3scale_zync_k8s_endpoint_downtime_seconds                30      -       -      -                                -  MISSING") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
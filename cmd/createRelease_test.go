@@ -6,6 +6,8 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-github/v30/github"
+	"github.com/integr8ly/delorean/pkg/services"
+	"github.com/integr8ly/delorean/pkg/services/fake"
 	"github.com/integr8ly/delorean/pkg/types"
 	"github.com/integr8ly/delorean/pkg/utils"
 	"io/ioutil"
@@ -243,3 +245,76 @@ func TestCreateRelease(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateReleaseWithFakeSCM(t *testing.T) {
+	server, err := fake.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	basedir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CreateRepo("test/test", "master", path.Join(basedir, "testdata/createReleaseTest")); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultGithubURL := githubURL
+	githubURL = server.URL
+	defer func() { githubURL = defaultGithubURL }()
+
+	client, err := github.NewEnterpriseClient(server.GitHubAPIURL(), server.GitHubAPIURL(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ := utils.NewVersion("2.0.0-rc1", types.OlmTypeRhmi)
+	cmd := &createReleaseCmd{
+		version:          version,
+		repoInfo:         &githubRepoInfo{owner: "test", repo: "test"},
+		baseBranch:       plumbing.NewBranchReferenceName("master"),
+		releaseScript:    "release.sh",
+		gitUser:          "testuser",
+		gitPass:          "testpass",
+		gitCloneService:  &services.DefaultGitCloneService{},
+		gitPushService:   &services.DefaultGitPushService{},
+		githubPRService:  client.PullRequests,
+		serviceAffecting: true,
+	}
+	repoDir, err := cmd.run(context.TODO())
+	if repoDir != "" {
+		defer os.RemoveAll(repoDir)
+	}
+	if err != nil {
+		t.Fatalf("create-release failed: %v", err)
+	}
+
+	prs := server.PullRequests("test/test")
+	if len(prs) != 1 {
+		t.Fatalf("expected 1 PR but found %d", len(prs))
+	}
+	if prs[0].GetHead().GetRef() != version.PrepareReleaseBranchName() || prs[0].GetTitle() != version.PrepareReleasePRTitle() {
+		t.Fatalf("unexpected PR: %+v", prs[0])
+	}
+
+	repo, err := server.Repo("test/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(version.PrepareReleaseBranchName()), true)
+	if err != nil {
+		t.Fatalf("release branch not pushed: %v", err)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := commit.File("VERSION.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := content.Contents(); !strings.Contains(c, "2.0.0-rc1") {
+		t.Fatalf("expected 2.0.0-rc1 in VERSION.txt but got %s", c)
+	}
+}
@@ -10,13 +10,14 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/integr8ly/delorean/pkg/remotewrite"
+	"github.com/integr8ly/delorean/pkg/s3processor"
 	"github.com/integr8ly/delorean/pkg/utils"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/config"
 	"github.com/spf13/cobra"
-	"os"
-	"strconv"
-	"strings"
+	"net/http"
+	"time"
 )
 
 const (
@@ -24,6 +25,7 @@ const (
 	datahubTagVal = "true"
 
 	downtimeReportFilename = "downtime-report.yaml"
+	downtimeReportPrefix   = "downtime-report"
 	pushgateway            = "http://pushgateway-dh-prod-monitoring.cloud.datahub.psi.redhat.com:9091"
 	jobName                = "rhmi-product-downtime"
 )
@@ -33,6 +35,12 @@ type datahubImportCmdFlags struct {
 	reportName  string
 	pushgateway string
 	jobName     string
+	aggregation string
+	// remote write is used instead of the pushgateway if the url is set
+	remoteWriteURL             string
+	remoteWriteBearerTokenFile string
+	metricsConfig              string
+	limits                     s3ImportFlags
 }
 
 type datahubImportCmd struct {
@@ -42,13 +50,13 @@ type datahubImportCmd struct {
 	reportName   string
 	pushgateway  string
 	jobName      string
+	aggregation  aggregation
+	remoteWrite  *remotewrite.Client
+	metrics      *datahubMetricsConfig
+	maxObjects   int
+	since        time.Time
 }
 
-var downtimeCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-	Name: "rhmi_product_downtime",
-	Help: "Downtime count in seconds",
-}, []string{"product", "query", "version"})
-
 func init() {
 	f := &datahubImportCmdFlags{}
 	cmd := &cobra.Command{
@@ -84,9 +92,41 @@ func init() {
 	cmd.Flags().StringVarP(&f.reportName, "reportname", "r", "", "The filename of the report to process")
 	cmd.Flags().StringVarP(&f.pushgateway, "pushgateway", "p", "", "The url of the prometheus pushgateway")
 	cmd.Flags().StringVarP(&f.jobName, "jobname", "j", "", "The jobname for the prometheus metrics")
+	cmd.Flags().StringVar(&f.aggregation, "aggregation", string(aggregationLast), "The function to aggregate the values of the range query results: last, sum, avg, min or max")
+	cmd.Flags().StringVar(&f.remoteWriteURL, "remote-write-url", "", "The url of a Prometheus remote write endpoint to send the results to with their timestamps, instead of the pushgateway")
+	cmd.Flags().StringVar(&f.remoteWriteBearerTokenFile, "remote-write-bearer-token-file", "", "File with the bearer token to authenticate to the remote write endpoint")
+	f.limits.addFlags(cmd)
+	cmd.Flags().StringVar(&f.metricsConfig, "metrics-config", "", "Path to the configuration of the metric name and labels of each report. The results are imported as rhmi_product_downtime if not set")
 }
 
 func newDatahubImportCmd(f *datahubImportCmdFlags, session *session.Session) (*datahubImportCmd, error) {
+	agg, err := parseAggregation(f.aggregation)
+	if err != nil {
+		return nil, err
+	}
+	since, err := f.limits.sinceTime(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var metrics *datahubMetricsConfig = nil
+	if f.metricsConfig != "" {
+		metrics = &datahubMetricsConfig{}
+		if err := utils.PopulateObjectFromYAML(f.metricsConfig, metrics); err != nil {
+			return nil, err
+		}
+		if err := metrics.validate(); err != nil {
+			return nil, err
+		}
+	}
+	var remoteWrite *remotewrite.Client = nil
+	if f.remoteWriteURL != "" {
+		httpConfig := config.HTTPClientConfig{BearerTokenFile: f.remoteWriteBearerTokenFile, FollowRedirects: true}
+		rt, err := config.NewRoundTripperFromConfig(httpConfig, "delorean")
+		if err != nil {
+			return nil, err
+		}
+		remoteWrite = remotewrite.NewClient(f.remoteWriteURL, &http.Client{Transport: rt})
+	}
 	s3i := s3.New(session)
 	s3Downloader := s3manager.NewDownloader(session)
 
@@ -97,18 +137,15 @@ func newDatahubImportCmd(f *datahubImportCmdFlags, session *session.Session) (*d
 		reportName:   f.reportName,
 		pushgateway:  f.pushgateway,
 		jobName:      f.jobName,
+		aggregation:  agg,
+		remoteWrite:  remoteWrite,
+		metrics:      metrics,
+		maxObjects:   f.limits.maxObjects,
+		since:        since,
 	}, nil
 }
 
 func (c *datahubImportCmd) run(ctx context.Context) error {
-	fmt.Println(fmt.Sprintf("[All] Listing objects from bucket %s", c.fromBucket))
-	o, err := c.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: &c.fromBucket, Delimiter: aws.String("/")})
-	if err != nil {
-		return err
-	}
-
-	tasks := make([]utils.Task, len(o.Contents))
-
 	if c.reportName == "" {
 		c.reportName = downtimeReportFilename
 	}
@@ -118,110 +155,52 @@ func (c *datahubImportCmd) run(ctx context.Context) error {
 	if c.jobName == "" {
 		c.jobName = jobName
 	}
-
-	for i, obj := range o.Contents {
-		f := obj
-
-		t := func() (utils.TaskResult, error) {
-			return c.processReportFile(ctx, f)
-		}
-		tasks[i] = t
-	}
-
-	if _, err := utils.ParallelLimit(ctx, tasks, defaultImportWorkers); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (c *datahubImportCmd) processReportFile(ctx context.Context, object *s3.Object) (interface{}, error) {
-	if !strings.HasPrefix(*object.Key, "downtime-report") {
-		fmt.Println(fmt.Sprintf("[%s] Skipping processing object", *object.Key))
-		return &struct{}{}, nil
+	if c.aggregation == "" {
+		c.aggregation = aggregationLast
 	}
 
-	fmt.Println(fmt.Sprintf("[%s] Start processing object", *object.Key))
-
-	tags, err := c.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
-		Bucket: &c.fromBucket,
-		Key:    object.Key,
+	p := s3processor.NewProcessor(c.s3, c.s3Downloader, s3processor.Options{
+		Bucket:       c.fromBucket,
+		Prefix:       downtimeReportPrefix,
+		ProcessedTag: &s3processor.Tag{Key: datahubTagKey, Value: datahubTagVal},
+		Download:     true,
+		Workers:      defaultImportWorkers,
+		MaxObjects:   c.maxObjects,
+		Since:        c.since,
 	})
+	results, err := p.Run(ctx, s3processor.SinkFunc(c.processReportFile))
 	if err != nil {
-		return nil, err
-	}
-
-	if hasTag(tags.TagSet, datahubTagKey, datahubTagVal) {
-		fmt.Println(fmt.Sprintf("[%s] file in bucket %s has been processed already. Ignored.", *object.Key, c.fromBucket))
-		return nil, nil
-	}
-
-	fmt.Println(fmt.Sprintf("[%s] Downloading file from s3 bucket %s", *object.Key, c.fromBucket))
-	// download object to a tmp dir
-	downloaded, err := utils.DownloadS3ObjectToTempDir(ctx, c.s3Downloader, c.fromBucket, *object.Key)
-	if err != nil {
-		return nil, err
+		return err
 	}
-	defer os.Remove(downloaded)
+	return results.Err()
+}
 
+func (c *datahubImportCmd) processReportFile(ctx context.Context, object *s3processor.Object) error {
 	qr := &queryResults{}
-	err = utils.PopulateObjectFromYAML(downloaded, qr)
-	if err != nil {
-		return nil, err
+	if err := utils.PopulateObjectFromYAML(object.File, qr); err != nil {
+		return err
 	}
 
-	fmt.Println(fmt.Sprintf("[%s] Downtime Report file is loaded. Uploading to prometheus at %s", *object.Key, c.pushgateway))
-
 	// Get version string
 	ver, err := utils.NewRHMIVersion(qr.Version)
 	if err != nil {
-		return nil, err
-	}
-	var name string
-	pusher := push.New(c.pushgateway, c.jobName)
-	pusher.Collector(downtimeCount)
-	for _, i := range qr.Results {
-		name = strings.Split(i.Name, "_")[0]
-		if len(i.v.String()) > 0 {
-			// The metric value comes with the query so split it to get the int value we care about
-			count, err := parseValue(i.v.String())
-			if err != nil {
-				return 0, err
-			}
-			downtimeCount.With(prometheus.Labels{"product": name, "query": i.Name, "version": ver.String()}).Set(float64(count))
-			if err != nil {
-				e := fmt.Errorf("failed to push to %s: %s", c.pushgateway, err)
-				return nil, e
-			}
-		}
-	}
-	err = pusher.Push()
-	if err != nil {
-		return nil, err
+		return err
 	}
-	// update tags
-	t := append(tags.TagSet, &s3.Tag{
-		Key:   aws.String(datahubTagKey),
-		Value: aws.String(datahubTagVal),
-	})
-	if _, err = c.s3.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
-		Bucket:  aws.String(c.fromBucket),
-		Key:     object.Key,
-		Tagging: &s3.Tagging{TagSet: t},
-	}); err != nil {
-		return nil, err
+	metric := c.metrics.metricFor(qr.Name)
+	if c.remoteWrite != nil {
+		fmt.Println(fmt.Sprintf("[%s] %s file is loaded. Sending to %s as %s", object.Key, qr.Name, c.remoteWrite.URL, metric.Metric))
+		series := reportTimeSeries(qr, metric, c.jobName, ver.String())
+		return c.remoteWrite.Write(ctx, &remotewrite.WriteRequest{Timeseries: series})
 	}
 
-	return &struct{}{}, nil
-}
-
-func parseValue(in string) (int, error) {
-	p := strings.Split(in, "=>")
-	p = strings.Split(p[1], "@")
-	r := strings.TrimSpace(p[0])
-	n, err := strconv.Atoi(r)
+	fmt.Println(fmt.Sprintf("[%s] %s file is loaded. Uploading to prometheus at %s as %s", object.Key, qr.Name, c.pushgateway, metric.Metric))
+	collector, err := newReportCollector(qr, metric, c.aggregation)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return n, nil
+	// the reports are pushed to a group for each version, so that they don't replace each other. The
+	// Pushgateway adds the version label to the metrics
+	pusher := push.New(c.pushgateway, c.jobName).Grouping("version", ver.String())
+	pusher.Collector(collector)
+	return pusher.Push()
 }
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/integr8ly/delorean/pkg/remotewrite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+type aggregation string
+
+const (
+	aggregationLast aggregation = "last"
+	aggregationSum  aggregation = "sum"
+	aggregationAvg  aggregation = "avg"
+	aggregationMin  aggregation = "min"
+	aggregationMax  aggregation = "max"
+
+	datahubMetricName = "rhmi_product_downtime"
+	datahubMetricHelp = "Downtime count in seconds"
+)
+
+var aggregations = []aggregation{aggregationLast, aggregationSum, aggregationAvg, aggregationMin, aggregationMax}
+
+func parseAggregation(s string) (aggregation, error) {
+	for _, a := range aggregations {
+		if string(a) == s {
+			return a, nil
+		}
+	}
+	var valid []string
+	for _, a := range aggregations {
+		valid = append(valid, string(a))
+	}
+	return "", fmt.Errorf("invalid aggregation %s, expected one of %s", s, strings.Join(valid, ", "))
+}
+
+// aggregate returns a single value for the points of a series
+func (a aggregation) aggregate(points []model.SamplePair) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	value := float64(points[0].Value)
+	if a == aggregationLast {
+		return float64(points[len(points)-1].Value)
+	}
+	for _, p := range points[1:] {
+		v := float64(p.Value)
+		switch a {
+		case aggregationSum, aggregationAvg:
+			value += v
+		case aggregationMin:
+			if v < value {
+				value = v
+			}
+		case aggregationMax:
+			if v > value {
+				value = v
+			}
+		}
+	}
+	if a == aggregationAvg {
+		value = value / float64(len(points))
+	}
+	return value
+}
+
+// querySample is a single value of a query result with the labels of its series
+type querySample struct {
+	Labels model.Metric
+	Value  float64
+}
+
+// querySamples returns a sample for a scalar, a sample for each series of a vector, and a sample for each
+// series of a matrix with the points aggregated by the function. Strings have no sample
+func querySamples(v model.Value, agg aggregation) ([]querySample, error) {
+	var samples []querySample
+	switch r := v.(type) {
+	case *model.Scalar:
+		samples = append(samples, querySample{Labels: model.Metric{}, Value: float64(r.Value)})
+	case model.Vector:
+		for _, s := range r {
+			samples = append(samples, querySample{Labels: s.Metric, Value: float64(s.Value)})
+		}
+	case model.Matrix:
+		for _, s := range r {
+			samples = append(samples, querySample{Labels: s.Metric, Value: agg.aggregate(s.Values)})
+		}
+	case *model.String:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected value type %T", v)
+	}
+	return samples, nil
+}
+
+// reservedLabels are set by the command or by the Pushgateway grouping, like the version
+var reservedLabels = map[string]bool{"product": true, "query": true, "version": true, "job": true}
+
+// datahubMetricConfig is the metric of the reports with the name, like Downtime Report
+type datahubMetricConfig struct {
+	Report string `json:"report"`
+	Metric string `json:"metric"`
+	Help   string `json:"help,omitempty"`
+	// constant labels added to the samples of the reports
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type datahubMetricsConfig struct {
+	Metrics []datahubMetricConfig `json:"metrics"`
+}
+
+func (c *datahubMetricsConfig) validate() error {
+	for _, m := range c.Metrics {
+		if !model.IsValidMetricName(model.LabelValue(m.Metric)) {
+			return fmt.Errorf("report %s: invalid metric name %q", m.Report, m.Metric)
+		}
+		for name := range m.Labels {
+			if !model.LabelName(name).IsValid() || reservedLabels[name] {
+				return fmt.Errorf("report %s: invalid label name %q", m.Report, name)
+			}
+		}
+	}
+	return nil
+}
+
+// metricFor returns the metric of the report, rhmi_product_downtime if the report is not configured
+func (c *datahubMetricsConfig) metricFor(report string) datahubMetricConfig {
+	if c != nil {
+		for _, m := range c.Metrics {
+			if m.Report == report {
+				if m.Help == "" {
+					m.Help = fmt.Sprintf("Results of the %s", report)
+				}
+				return m
+			}
+		}
+	}
+	return datahubMetricConfig{Report: report, Metric: datahubMetricName, Help: datahubMetricHelp}
+}
+
+// sampleLabels returns the labels of a sample of the query: the product, the query, the constant labels of the
+// metric and the labels of the series, renamed with the exported_ prefix if they clash with the reserved labels
+func sampleLabels(query string, series model.Metric, metric datahubMetricConfig) map[string]string {
+	labels := map[string]string{}
+	for name, value := range series {
+		n := string(name)
+		if name == model.MetricNameLabel || !name.IsValid() {
+			continue
+		}
+		if reservedLabels[n] {
+			n = "exported_" + n
+		}
+		labels[n] = string(value)
+	}
+	for name, value := range metric.Labels {
+		labels[name] = value
+	}
+	labels["product"] = strings.Split(query, "_")[0]
+	labels["query"] = query
+	return labels
+}
+
+// reportCollector exposes the results of a report as gauges, with the labels returned by sampleLabels.
+// A query repeated in the report with the same labels keeps its last value
+type reportCollector struct {
+	metrics []prometheus.Metric
+}
+
+func newReportCollector(r *queryResults, metric datahubMetricConfig, agg aggregation) (*reportCollector, error) {
+	c := &reportCollector{}
+	indexes := map[model.Fingerprint]int{}
+	for _, qr := range r.Results {
+		samples, err := querySamples(qr.v, agg)
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %w", qr.Name, err)
+		}
+		for _, s := range samples {
+			labels := sampleLabels(qr.Name, s.Labels, metric)
+			desc := prometheus.NewDesc(metric.Metric, metric.Help, nil, labels)
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.Value)
+			if err != nil {
+				return nil, fmt.Errorf("query %s: %w", qr.Name, err)
+			}
+			fp := model.LabelsToSignature(labels)
+			if i, ok := indexes[model.Fingerprint(fp)]; ok {
+				c.metrics[i] = m
+				continue
+			}
+			indexes[model.Fingerprint(fp)] = len(c.metrics)
+			c.metrics = append(c.metrics, m)
+		}
+	}
+	return c, nil
+}
+
+// Describe sends no descriptor, since the labels of the gauges depend on the results. This makes the
+// collector unchecked
+func (c *reportCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *reportCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+// reportTimeSeries returns the results of a report as time series with their original timestamps, to be sent with
+// remote write. Unlike the gauges, all the points of the range query results are kept. The series have the labels
+// returned by sampleLabels, with the job and the version. A point repeated with the same labels and timestamp
+// keeps its last value
+func reportTimeSeries(r *queryResults, metric datahubMetricConfig, job string, version string) []remotewrite.TimeSeries {
+	var series []*remotewrite.TimeSeries
+	indexes := map[uint64]int{}
+	points := map[uint64]map[int64]int{}
+	add := func(query string, m model.Metric, values ...model.SamplePair) {
+		labels := sampleLabels(query, m, metric)
+		labels[model.MetricNameLabel] = metric.Metric
+		labels["job"] = job
+		labels["version"] = version
+		fp := model.LabelsToSignature(labels)
+		i, ok := indexes[fp]
+		if !ok {
+			ts := &remotewrite.TimeSeries{}
+			for name, value := range labels {
+				ts.Labels = append(ts.Labels, remotewrite.Label{Name: name, Value: value})
+			}
+			i = len(series)
+			indexes[fp] = i
+			points[fp] = map[int64]int{}
+			series = append(series, ts)
+		}
+		ts := series[i]
+		for _, v := range values {
+			sample := remotewrite.Sample{Value: float64(v.Value), Timestamp: int64(v.Timestamp)}
+			if j, ok := points[fp][sample.Timestamp]; ok {
+				ts.Samples[j] = sample
+				continue
+			}
+			points[fp][sample.Timestamp] = len(ts.Samples)
+			ts.Samples = append(ts.Samples, sample)
+		}
+	}
+
+	for _, qr := range r.Results {
+		switch v := qr.v.(type) {
+		case *model.Scalar:
+			add(qr.Name, nil, model.SamplePair{Timestamp: v.Timestamp, Value: v.Value})
+		case model.Vector:
+			for _, s := range v {
+				add(qr.Name, s.Metric, model.SamplePair{Timestamp: s.Timestamp, Value: s.Value})
+			}
+		case model.Matrix:
+			for _, s := range v {
+				add(qr.Name, s.Metric, s.Values...)
+			}
+		}
+	}
+
+	result := make([]remotewrite.TimeSeries, len(series))
+	for i, ts := range series {
+		result[i] = *ts
+	}
+	return result
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/integr8ly/delorean/pkg/remotewrite"
+	"github.com/integr8ly/delorean/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestAggregation(t *testing.T) {
+	points := []model.SamplePair{{Timestamp: 1, Value: 4}, {Timestamp: 2, Value: 1}, {Timestamp: 3, Value: 7}}
+	expected := map[aggregation]float64{
+		aggregationLast: 7,
+		aggregationSum:  12,
+		aggregationAvg:  4,
+		aggregationMin:  1,
+		aggregationMax:  7,
+	}
+	for a, e := range expected {
+		if v := a.aggregate(points); v != e {
+			t.Errorf("expected %s to be %v but got %v", a, e, v)
+		}
+	}
+	if _, err := parseAggregation("median"); err == nil {
+		t.Errorf("expected an error for an invalid aggregation")
+	}
+}
+
+func TestQuerySamples(t *testing.T) {
+	cases := []struct {
+		description string
+		value       model.Value
+		expected    []querySample
+	}{
+		{
+			description: "scalar",
+			value:       &model.Scalar{Value: 2.5},
+			expected:    []querySample{{Labels: model.Metric{}, Value: 2.5}},
+		},
+		{
+			description: "vector with several series",
+			value: model.Vector{
+				{Metric: model.Metric{"endpoint": "zync"}, Value: 10.5},
+				{Metric: model.Metric{"endpoint": "apicast"}, Value: 3},
+			},
+			expected: []querySample{
+				{Labels: model.Metric{"endpoint": "zync"}, Value: 10.5},
+				{Labels: model.Metric{"endpoint": "apicast"}, Value: 3},
+			},
+		},
+		{
+			description: "matrix",
+			value: model.Matrix{{
+				Metric: model.Metric{"endpoint": "zync"},
+				Values: []model.SamplePair{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 3}},
+			}},
+			expected: []querySample{{Labels: model.Metric{"endpoint": "zync"}, Value: 2}},
+		},
+		{
+			description: "string",
+			value:       &model.String{Value: "up"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			samples, err := querySamples(c.value, aggregationAvg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(samples, c.expected) {
+				t.Fatalf("unexpected samples %v", samples)
+			}
+		})
+	}
+}
+
+func TestReportCollector(t *testing.T) {
+	var path string
+	var metrics []*dto.Metric
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			mf := &dto.MetricFamily{}
+			if err := decoder.Decode(mf); err != nil {
+				if err != io.EOF {
+					t.Errorf("failed to decode the metrics: %v", err)
+				}
+				break
+			}
+			if mf.GetName() != "rhoam_downtime_seconds" {
+				t.Errorf("unexpected metric %s", mf.GetName())
+			}
+			metrics = append(metrics, mf.GetMetric()...)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r := &queryResults{
+		Name:    "Downtime Report",
+		Version: "1.2.0",
+		Results: []queryResult{
+			{Name: "3scale_total_downtime_seconds", v: &model.Scalar{Value: 12.5}},
+			{Name: "3scale_endpoint_downtime_seconds", v: model.Vector{
+				{Metric: model.Metric{"__name__": "up", "job": "kube-state-metrics", "endpoint": "zync"}, Value: 10},
+				{Metric: model.Metric{"job": "kube-state-metrics", "endpoint": "apicast"}, Value: 3},
+			}},
+			// repeated with the same labels
+			{Name: "3scale_endpoint_downtime_seconds", v: model.Vector{
+				{Metric: model.Metric{"job": "kube-state-metrics", "endpoint": "apicast"}, Value: 5},
+			}},
+			{Name: "rhsso_endpoint_downtime_seconds", v: model.Matrix{{
+				Metric: model.Metric{"endpoint": "keycloak"},
+				Values: []model.SamplePair{{Timestamp: 1, Value: 20}, {Timestamp: 2, Value: 30}},
+			}}},
+		},
+	}
+	config := &datahubMetricsConfig{Metrics: []datahubMetricConfig{{Report: "Downtime Report", Metric: "rhoam_downtime_seconds", Labels: map[string]string{"source": "query-report"}}}}
+	collector, err := newReportCollector(r, config.metricFor(r.Name), aggregationLast)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := push.New(server.URL, "test").Grouping("version", r.Version).Collector(collector).Push(); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+
+	if expected := "/metrics/job/test/version/1.2.0"; path != expected {
+		t.Errorf("expected the metrics to be pushed to %s but got %s", expected, path)
+	}
+	values := map[string]float64{}
+	for _, m := range metrics {
+		var key string
+		for _, l := range m.GetLabel() {
+			key += l.GetName() + "=" + l.GetValue() + ","
+		}
+		values[key] = m.GetGauge().GetValue()
+	}
+	expected := map[string]float64{
+		"product=3scale,query=3scale_total_downtime_seconds,source=query-report,":                                                     12.5,
+		"endpoint=zync,exported_job=kube-state-metrics,product=3scale,query=3scale_endpoint_downtime_seconds,source=query-report,":    10,
+		"endpoint=apicast,exported_job=kube-state-metrics,product=3scale,query=3scale_endpoint_downtime_seconds,source=query-report,": 5,
+		"endpoint=keycloak,product=rhsso,query=rhsso_endpoint_downtime_seconds,source=query-report,":                                  30,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("unexpected metrics %v", values)
+	}
+}
+
+func TestDatahubMetricsConfig(t *testing.T) {
+	metrics := &datahubMetricsConfig{}
+	if err := utils.PopulateObjectFromYAML("../configurations/datahub-metrics-config.yaml", metrics); err != nil {
+		t.Fatalf("failed to load the configuration: %v", err)
+	}
+	if err := metrics.validate(); err != nil {
+		t.Fatalf("invalid configuration: %v", err)
+	}
+	if m := metrics.metricFor("Downtime Report"); m.Metric != datahubMetricName {
+		t.Errorf("unexpected metric %s for the downtime report", m.Metric)
+	}
+	var none *datahubMetricsConfig
+	if m := none.metricFor("Test Report"); m.Metric != datahubMetricName || m.Help != datahubMetricHelp {
+		t.Errorf("unexpected default metric %v", m)
+	}
+
+	invalid := []datahubMetricConfig{
+		{Report: "Test Report", Metric: "rhoam-downtime"},
+		{Report: "Test Report", Metric: "rhoam_downtime", Labels: map[string]string{"version": "1.0.0"}},
+		{Report: "Test Report", Metric: "rhoam_downtime", Labels: map[string]string{"cluster-id": "1234"}},
+	}
+	for _, m := range invalid {
+		c := &datahubMetricsConfig{Metrics: []datahubMetricConfig{m}}
+		if err := c.validate(); err == nil {
+			t.Errorf("expected an error for %v", m)
+		}
+	}
+}
+
+func TestReportTimeSeries(t *testing.T) {
+	r := &queryResults{
+		Name: "Downtime Report",
+		Results: []queryResult{
+			{Name: "3scale_total_downtime_seconds", v: &model.Scalar{Timestamp: 1600000120000, Value: 12.5}},
+			{Name: "3scale_endpoint_downtime_seconds", v: model.Matrix{{
+				Metric: model.Metric{"job": "kube-state-metrics", "endpoint": "zync"},
+				Values: []model.SamplePair{{Timestamp: 1600000000000, Value: 1}, {Timestamp: 1600000060000, Value: 0}},
+			}}},
+			// repeated with the same labels and timestamp
+			{Name: "3scale_endpoint_downtime_seconds", v: model.Vector{
+				{Metric: model.Metric{"job": "kube-state-metrics", "endpoint": "zync"}, Timestamp: 1600000060000, Value: 1},
+			}},
+		},
+	}
+	series := reportTimeSeries(r, (*datahubMetricsConfig)(nil).metricFor(r.Name), "test", "1.2.0")
+
+	values := map[string][]remotewrite.Sample{}
+	for _, ts := range series {
+		labels := map[string]string{}
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		values[labelSet(labels).String()] = ts.Samples
+	}
+	expected := map[string][]remotewrite.Sample{
+		`{__name__="rhmi_product_downtime", job="test", product="3scale", query="3scale_total_downtime_seconds", version="1.2.0"}`: {
+			{Value: 12.5, Timestamp: 1600000120000},
+		},
+		`{__name__="rhmi_product_downtime", endpoint="zync", exported_job="kube-state-metrics", job="test", product="3scale", query="3scale_endpoint_downtime_seconds", version="1.2.0"}`: {
+			{Value: 1, Timestamp: 1600000000000},
+			{Value: 1, Timestamp: 1600000060000},
+		},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("unexpected time series %v", values)
+	}
+}
+
+func labelSet(labels map[string]string) model.LabelSet {
+	set := model.LabelSet{}
+	for name, value := range labels {
+		set[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return set
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/integr8ly/delorean/pkg/remotewrite"
 	"github.com/integr8ly/delorean/pkg/utils"
 	"io"
 	"io/ioutil"
@@ -162,3 +163,57 @@ func TestDatahubImportCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestDatahubImportCmd_remoteWrite(t *testing.T) {
+	var requests int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	var tagged bool
+	cmd := &datahubImportCmd{
+		s3: &utils.MockS3API{
+			ListObjsFunc: func(input *s3.ListObjectsV2Input) (output *s3.ListObjectsV2Output, err error) {
+				return &s3.ListObjectsV2Output{
+					Contents: []*s3.Object{{Key: aws.String("downtime-report.yaml")}},
+				}, nil
+			},
+			GetObjTaggingFunc: func(input *s3.GetObjectTaggingInput) (output *s3.GetObjectTaggingOutput, err error) {
+				return &s3.GetObjectTaggingOutput{TagSet: []*s3.Tag{}}, nil
+			},
+			PutObjTaggingFunc: func(input *s3.PutObjectTaggingInput) (output *s3.PutObjectTaggingOutput, err error) {
+				tagged = hasTag(input.Tagging.TagSet, datahubTagKey, datahubTagVal)
+				return &s3.PutObjectTaggingOutput{}, nil
+			},
+		},
+		s3Downloader: &utils.MockS3Downloader{
+			DownloadFunc: func(o io.WriterAt, input *s3.GetObjectInput) (i int64, err error) {
+				content, err := ioutil.ReadFile("./testdata/queryReport/downtime-report.yaml")
+				if err != nil {
+					return 0, err
+				}
+				b, err := o.WriteAt(content, 0)
+				return int64(b), err
+			},
+		},
+		fromBucket:  "test-bucket",
+		pushgateway: "not-a-real-gateway",
+		jobName:     "testJob",
+		remoteWrite: remotewrite.NewClient(receiver.URL, nil),
+	}
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 remote write request but got %d", requests)
+	}
+	if !tagged {
+		t.Fatalf("expected the report to be tagged as processed")
+	}
+}
@@ -0,0 +1,471 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	defaultDowntimeReportFile   = "downtime.json"
+	defaultDowntimeSaveInterval = 30 * time.Second
+	downtimeReportName          = "Downtime Report"
+
+	dcWorkload          = "dc"
+	deploymentWorkload  = "deployment"
+	statefulSetWorkload = "statefulset"
+)
+
+// Prefixes of the namespaces of the products, in the order they are detected
+var downtimeNamespacePrefixes = []string{"redhat-rhoam-", "redhat-rhmi-"}
+
+// Workloads watched to measure the downtime of the products
+var downtimeWorkloads = []struct {
+	kind     string
+	resource schema.GroupVersionResource
+}{
+	{kind: dcWorkload, resource: schema.GroupVersionResource{Group: "apps.openshift.io", Version: "v1", Resource: "deploymentconfigs"}},
+	{kind: deploymentWorkload, resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+	{kind: statefulSetWorkload, resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}},
+}
+
+// downtimeWindow is a period of unavailability in epoch seconds. The end is 0 while the window is open
+type downtimeWindow struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type workloadDowntime struct {
+	Name      string           `json:"name"`
+	Namespace string           `json:"namespace"`
+	Ready     int64            `json:"ready,string"`
+	Expected  int64            `json:"expected,string"`
+	Downtimes []downtimeWindow `json:"downtimes"`
+}
+
+type projectDowntime struct {
+	Name              string              `json:"name"`
+	DCs               []*workloadDowntime `json:"dcs"`
+	Deployments       []*workloadDowntime `json:"deployments"`
+	StatefulSets      []*workloadDowntime `json:"statefulsets"`
+	Downtimes         []downtimeWindow    `json:"downtimes"`
+	DowntimeInSeconds int64               `json:"downtimeInSeconds"`
+}
+
+// downtimeReport has the same format as the report of the scripts/ocm/measure-downtime.js script
+type downtimeReport struct {
+	Projects []*projectDowntime `json:"projects"`
+	Start    int64              `json:"start"`
+	End      int64              `json:"end"`
+}
+
+// downtimeMonitor records the downtime windows of the workloads and of their namespaces. A workload is down
+// when none of its replicas is ready or when it's deleted. A namespace is down when any of its workloads is down
+type downtimeMonitor struct {
+	mu        sync.Mutex
+	report    *downtimeReport
+	projects  map[string]*projectDowntime
+	workloads map[string]*workloadDowntime
+}
+
+func newDowntimeMonitor(namespaces []string, start time.Time) *downtimeMonitor {
+	m := &downtimeMonitor{
+		report:    &downtimeReport{Projects: []*projectDowntime{}, Start: start.Unix()},
+		projects:  map[string]*projectDowntime{},
+		workloads: map[string]*workloadDowntime{},
+	}
+	for _, ns := range namespaces {
+		p := &projectDowntime{
+			Name:         ns,
+			DCs:          []*workloadDowntime{},
+			Deployments:  []*workloadDowntime{},
+			StatefulSets: []*workloadDowntime{},
+			Downtimes:    []downtimeWindow{},
+		}
+		m.projects[ns] = p
+		m.report.Projects = append(m.report.Projects, p)
+	}
+	return m
+}
+
+func workloadKey(kind string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// track starts monitoring the workload if it's in one of the monitored namespaces and expects some replicas
+func (m *downtimeMonitor) track(kind string, obj *unstructured.Unstructured, ts time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.projects[obj.GetNamespace()]
+	if !ok {
+		return
+	}
+	ready, expected := workloadReplicas(obj)
+	if expected == 0 {
+		return
+	}
+	w := &workloadDowntime{Name: obj.GetName(), Namespace: obj.GetNamespace(), Downtimes: []downtimeWindow{}}
+	switch kind {
+	case dcWorkload:
+		p.DCs = append(p.DCs, w)
+	case deploymentWorkload:
+		p.Deployments = append(p.Deployments, w)
+	case statefulSetWorkload:
+		p.StatefulSets = append(p.StatefulSets, w)
+	}
+	m.workloads[workloadKey(kind, w.Namespace, w.Name)] = w
+	m.observe(p, w, ready, expected, ts)
+}
+
+// update records the current state of the workload if it's monitored
+func (m *downtimeMonitor) update(kind string, obj *unstructured.Unstructured, ts time.Time) {
+	ready, expected := workloadReplicas(obj)
+	m.set(kind, obj.GetNamespace(), obj.GetName(), ready, expected, ts)
+}
+
+// remove records the workload as down if it's monitored
+func (m *downtimeMonitor) remove(kind string, obj *unstructured.Unstructured, ts time.Time) {
+	m.set(kind, obj.GetNamespace(), obj.GetName(), 0, 0, ts)
+}
+
+func (m *downtimeMonitor) set(kind string, namespace string, name string, ready int64, expected int64, ts time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workloads[workloadKey(kind, namespace, name)]
+	if !ok {
+		return
+	}
+	m.observe(m.projects[namespace], w, ready, expected, ts)
+}
+
+func (m *downtimeMonitor) observe(p *projectDowntime, w *workloadDowntime, ready int64, expected int64, ts time.Time) {
+	w.Ready, w.Expected = ready, expected
+	if updateDowntimes(&w.Downtimes, ready == 0, ts.Unix()) {
+		if ready == 0 {
+			fmt.Println(fmt.Sprintf("[%s] %s is down", w.Namespace, w.Name))
+		} else {
+			fmt.Println(fmt.Sprintf("[%s] %s is up", w.Namespace, w.Name))
+		}
+	}
+
+	down := false
+	for _, workloads := range [][]*workloadDowntime{p.DCs, p.Deployments, p.StatefulSets} {
+		for _, w := range workloads {
+			if w.Ready == 0 {
+				down = true
+			}
+		}
+	}
+	updateDowntimes(&p.Downtimes, down, ts.Unix())
+}
+
+// updateDowntimes opens a new window when it goes down and closes the last one when it comes back up.
+// It returns true if the state changed
+func updateDowntimes(downtimes *[]downtimeWindow, down bool, ts int64) bool {
+	open := len(*downtimes) > 0 && (*downtimes)[len(*downtimes)-1].End == 0
+	if down && !open {
+		*downtimes = append(*downtimes, downtimeWindow{Start: ts})
+		return true
+	}
+	if !down && open {
+		(*downtimes)[len(*downtimes)-1].End = ts
+		return true
+	}
+	return false
+}
+
+// snapshot returns the JSON report at the given time. If final is true, the open windows are closed first
+func (m *downtimeMonitor) snapshot(ts time.Time, final bool) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.report.Projects {
+		if final {
+			closeDowntimes(p.Downtimes, ts.Unix())
+			for _, workloads := range [][]*workloadDowntime{p.DCs, p.Deployments, p.StatefulSets} {
+				for _, w := range workloads {
+					closeDowntimes(w.Downtimes, ts.Unix())
+				}
+			}
+		}
+		p.DowntimeInSeconds = totalDowntime(p.Downtimes)
+	}
+	m.report.End = ts.Unix()
+	return json.Marshal(m.report)
+}
+
+func closeDowntimes(downtimes []downtimeWindow, ts int64) {
+	if len(downtimes) > 0 && downtimes[len(downtimes)-1].End == 0 {
+		downtimes[len(downtimes)-1].End = ts
+	}
+}
+
+// totalDowntime returns the duration of the closed windows in seconds
+func totalDowntime(downtimes []downtimeWindow) int64 {
+	var total int64
+	for _, d := range downtimes {
+		if d.End != 0 {
+			total += d.End - d.Start
+		}
+	}
+	return total
+}
+
+// workloadReplicas returns the ready and the desired replicas of a DeploymentConfig, Deployment or StatefulSet
+func workloadReplicas(obj *unstructured.Unstructured) (int64, int64) {
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	expected, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		// the replicas default to 1 when not set
+		expected = 1
+	}
+	return ready, expected
+}
+
+// toQueryResults converts the report into the format of the query-report command, so that it can be imported
+// with the datahub-import command. There is one result for each namespace and for each workload, and the name
+// of the results starts with the product so that the downtime is grouped by product
+func (r *downtimeReport) toQueryResults(prefix string, version string) *queryResults {
+	results := &queryResults{Name: downtimeReportName, Version: version, Results: []queryResult{}}
+	ts := model.TimeFromUnix(r.End)
+	newResult := func(name string, metric model.Metric, downtimes []downtimeWindow) queryResult {
+		v := model.Vector{&model.Sample{Metric: metric, Value: model.SampleValue(totalDowntime(downtimes)), Timestamp: ts}}
+		return queryResult{Name: name, Query: metric.String(), Type: v.Type(), v: v}
+	}
+	for _, p := range r.Projects {
+		product := strings.TrimPrefix(p.Name, prefix)
+		metric := model.Metric{"namespace": model.LabelValue(p.Name)}
+		results.Results = append(results.Results, newResult(fmt.Sprintf("%s_downtime_seconds", product), metric, p.Downtimes))
+		for kind, workloads := range map[string][]*workloadDowntime{dcWorkload: p.DCs, deploymentWorkload: p.Deployments, statefulSetWorkload: p.StatefulSets} {
+			for _, w := range workloads {
+				name := fmt.Sprintf("%s_%s_%s_downtime_seconds", product, strings.ReplaceAll(w.Name, "-", "_"), kind)
+				metric := model.Metric{"namespace": model.LabelValue(p.Name), model.LabelName(kind): model.LabelValue(w.Name)}
+				results.Results = append(results.Results, newResult(name, metric, w.Downtimes))
+			}
+		}
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		return results.Results[i].Name < results.Results[j].Name
+	})
+	return results
+}
+
+type measureDowntimeCmdFlags struct {
+	namespacePrefix  string
+	output           string
+	queryResultsFile string
+	version          string
+	saveInterval     time.Duration
+}
+
+type measureDowntimeCmd struct {
+	clientset        kubernetes.Interface
+	dynamicClient    dynamic.Interface
+	namespacePrefix  string
+	output           string
+	queryResultsFile string
+	version          string
+	saveInterval     time.Duration
+}
+
+func init() {
+	f := &measureDowntimeCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "measure-downtime",
+		Short: "Measure the downtime of the products until the command is interrupted or times out",
+		Long: `Watch the DeploymentConfigs, Deployments and StatefulSets of the products and record when none of their
+replicas is ready. The command runs until it receives SIGINT or SIGTERM, or until the --command-timeout is reached,
+then writes the final report`,
+		Run: func(cmd *cobra.Command, args []string) {
+			kubeConfig, err := requireValue(KubeConfigKey)
+			if err != nil {
+				handleError(err)
+			}
+			c, err := newMeasureDowntimeCmd(kubeConfig, f)
+			if err != nil {
+				handleError(err)
+			}
+			if err := c.run(cmd.Context()); err != nil {
+				handleError(err)
+			}
+		},
+	}
+	pipelineCmd.AddCommand(cmd)
+	cmd.Flags().StringVar(&f.namespacePrefix, "namespace-prefix", "", fmt.Sprintf("Prefix of the namespaces of the products. Detected from the namespaces if not set (%s)", strings.Join(downtimeNamespacePrefixes, ", ")))
+	cmd.Flags().StringVarP(&f.output, "output", "o", defaultDowntimeReportFile, "Path of the JSON downtime report")
+	cmd.Flags().StringVar(&f.queryResultsFile, "query-results", "", "Path of the downtime report in the format of the query-report command, that can be imported with datahub-import")
+	cmd.Flags().StringVarP(&f.version, "version", "v", "", "The RHMI version installed on the cluster, added to the query results")
+	cmd.Flags().DurationVar(&f.saveInterval, "save-interval", defaultDowntimeSaveInterval, "How often the report is saved while the downtime is measured")
+}
+
+func newMeasureDowntimeCmd(kubeconfig string, f *measureDowntimeCmdFlags) (*measureDowntimeCmd, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &measureDowntimeCmd{
+		clientset:        clientset,
+		dynamicClient:    dynamicClient,
+		namespacePrefix:  f.namespacePrefix,
+		output:           f.output,
+		queryResultsFile: f.queryResultsFile,
+		version:          f.version,
+		saveInterval:     f.saveInterval,
+	}, nil
+}
+
+func (c *measureDowntimeCmd) run(ctx context.Context) error {
+	namespaces, err := c.getNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(fmt.Sprintf("Measure the downtime of the workloads in %d namespaces with prefix %s", len(namespaces), c.namespacePrefix))
+	monitor := newDowntimeMonitor(namespaces, time.Now())
+
+	informersCtx, stopInformers := context.WithCancel(context.Background())
+	defer stopInformers()
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamicClient, 0)
+	var informers []cache.SharedIndexInformer
+	for _, w := range downtimeWorkloads {
+		kind := w.kind
+		informer := factory.ForResource(w.resource).Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if u, ok := obj.(*unstructured.Unstructured); ok {
+					monitor.update(kind, u, time.Now())
+				}
+			},
+			UpdateFunc: func(_, obj interface{}) {
+				if u, ok := obj.(*unstructured.Unstructured); ok {
+					monitor.update(kind, u, time.Now())
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = d.Obj
+				}
+				if u, ok := obj.(*unstructured.Unstructured); ok {
+					monitor.remove(kind, u, time.Now())
+				}
+			},
+		})
+		informers = append(informers, informer)
+	}
+	factory.Start(informersCtx.Done())
+	for i, informer := range informers {
+		if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			return fmt.Errorf("failed to sync the %s informer: %w", downtimeWorkloads[i].resource.Resource, ctx.Err())
+		}
+	}
+
+	// only the workloads that exist when the command starts are monitored, like the ones that exist before an upgrade
+	for i, informer := range informers {
+		objs, err := cache.NewGenericLister(informer.GetIndexer(), downtimeWorkloads[i].resource.GroupResource()).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				monitor.track(downtimeWorkloads[i].kind, u, time.Now())
+			}
+		}
+	}
+
+	ticker := time.NewTicker(c.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.saveReport(monitor, time.Now(), false); err != nil {
+				fmt.Println(fmt.Sprintf("Failed to save the downtime report due to error: %v", err))
+			}
+		case <-ctx.Done():
+			fmt.Println("Stop measuring the downtime")
+			return c.saveReport(monitor, time.Now(), true)
+		}
+	}
+}
+
+// getNamespaces returns the namespaces of the products, detecting their prefix if it's not set
+func (c *measureDowntimeCmd) getNamespaces(ctx context.Context) ([]string, error) {
+	list, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if c.namespacePrefix == "" {
+		for _, prefix := range downtimeNamespacePrefixes {
+			for _, ns := range list.Items {
+				if strings.HasPrefix(ns.GetName(), prefix) {
+					c.namespacePrefix = prefix
+					break
+				}
+			}
+			if c.namespacePrefix != "" {
+				break
+			}
+		}
+		if c.namespacePrefix == "" {
+			return nil, fmt.Errorf("no namespace found with any of the prefixes %s", strings.Join(downtimeNamespacePrefixes, ", "))
+		}
+	}
+	var namespaces []string
+	for _, ns := range list.Items {
+		if strings.HasPrefix(ns.GetName(), c.namespacePrefix) {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (c *measureDowntimeCmd) saveReport(monitor *downtimeMonitor, ts time.Time, final bool) error {
+	b, err := monitor.snapshot(ts, final)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(c.output, b, 0644); err != nil {
+		return err
+	}
+	if !final {
+		return nil
+	}
+	fmt.Println("Downtime report is generated:", c.output)
+
+	if c.queryResultsFile == "" {
+		return nil
+	}
+	r := &downtimeReport{}
+	if err = json.Unmarshal(b, r); err != nil {
+		return err
+	}
+	if err = utils.WriteObjectToYAML(r.toQueryResults(c.namespacePrefix, c.version), c.queryResultsFile); err != nil {
+		return err
+	}
+	fmt.Println("Query results are generated:", c.queryResultsFile)
+	return nil
+}
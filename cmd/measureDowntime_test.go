@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestWorkload(apiVersion string, kind string, namespace string, name string, replicas int64, ready int64) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	unstructured.SetNestedField(u.Object, replicas, "spec", "replicas")
+	unstructured.SetNestedField(u.Object, ready, "status", "readyReplicas")
+	return u
+}
+
+func TestDowntimeMonitor(t *testing.T) {
+	start := time.Unix(1000, 0)
+	m := newDowntimeMonitor([]string{"redhat-rhoam-3scale", "redhat-rhoam-user-sso"}, start)
+	apicast := newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhoam-3scale", "apicast-production", 2, 2)
+	zync := newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhoam-3scale", "zync", 1, 0)
+	keycloak := newTestWorkload("apps/v1", "StatefulSet", "redhat-rhoam-user-sso", "keycloak", 2, 2)
+	m.track(dcWorkload, apicast, start)
+	m.track(dcWorkload, zync, start)
+	m.track(statefulSetWorkload, keycloak, start)
+	// not monitored
+	m.track(deploymentWorkload, newTestWorkload("apps/v1", "Deployment", "redhat-rhoam-3scale", "scaled-down", 0, 0), start)
+	m.track(deploymentWorkload, newTestWorkload("apps/v1", "Deployment", "openshift-monitoring", "prometheus", 1, 1), start)
+
+	m.update(dcWorkload, newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhoam-3scale", "zync", 1, 1), time.Unix(1010, 0))
+	m.update(dcWorkload, newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhoam-3scale", "apicast-production", 2, 0), time.Unix(1020, 0))
+	m.update(dcWorkload, newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhoam-3scale", "apicast-production", 2, 1), time.Unix(1050, 0))
+	m.remove(statefulSetWorkload, keycloak, time.Unix(1100, 0))
+
+	b, err := m.snapshot(time.Unix(1200, 0), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := &downtimeReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		t.Fatalf("failed to unmarshal the report: %v", err)
+	}
+	if report.Start != 1000 || report.End != 1200 || len(report.Projects) != 2 {
+		t.Fatalf("unexpected report: %s", b)
+	}
+
+	threescale := report.Projects[0]
+	if expected := []downtimeWindow{{Start: 1000, End: 1010}, {Start: 1020, End: 1050}}; !reflect.DeepEqual(threescale.Downtimes, expected) {
+		t.Errorf("expected the 3scale downtimes %v but got %v", expected, threescale.Downtimes)
+	}
+	if threescale.DowntimeInSeconds != 40 {
+		t.Errorf("expected 40s of 3scale downtime but got %d", threescale.DowntimeInSeconds)
+	}
+	if len(threescale.DCs) != 2 || len(threescale.Deployments) != 0 {
+		t.Fatalf("unexpected 3scale workloads: %s", b)
+	}
+	if expected := []downtimeWindow{{Start: 1020, End: 1050}}; !reflect.DeepEqual(threescale.DCs[0].Downtimes, expected) {
+		t.Errorf("expected the apicast-production downtimes %v but got %v", expected, threescale.DCs[0].Downtimes)
+	}
+	if threescale.DCs[0].Ready != 1 || threescale.DCs[0].Expected != 2 {
+		t.Errorf("unexpected apicast-production replicas: %+v", threescale.DCs[0])
+	}
+
+	userSSO := report.Projects[1]
+	if expected := []downtimeWindow{{Start: 1100, End: 1200}}; !reflect.DeepEqual(userSSO.Downtimes, expected) {
+		t.Errorf("expected the open user-sso downtime to be closed at the end but got %v", userSSO.Downtimes)
+	}
+	if userSSO.DowntimeInSeconds != 100 {
+		t.Errorf("expected 100s of user-sso downtime but got %d", userSSO.DowntimeInSeconds)
+	}
+
+	// same format as the measure-downtime.js script
+	raw := map[string]interface{}{}
+	json.Unmarshal(b, &raw)
+	dc := raw["projects"].([]interface{})[0].(map[string]interface{})["dcs"].([]interface{})[0].(map[string]interface{})
+	if dc["ready"] != "1" || dc["expected"] != "2" {
+		t.Errorf("expected the replicas to be strings but got %v", dc)
+	}
+}
+
+func TestDowntimeReportToQueryResults(t *testing.T) {
+	report := &downtimeReport{
+		Start: 1000,
+		End:   1200,
+		Projects: []*projectDowntime{
+			{
+				Name:        "redhat-rhoam-3scale",
+				DCs:         []*workloadDowntime{{Name: "apicast-production", Namespace: "redhat-rhoam-3scale", Downtimes: []downtimeWindow{{Start: 1020, End: 1050}}}},
+				Deployments: []*workloadDowntime{{Name: "threescale-operator", Namespace: "redhat-rhoam-3scale"}},
+				Downtimes:   []downtimeWindow{{Start: 1000, End: 1010}, {Start: 1020, End: 1050}},
+			},
+		},
+	}
+	file := path.Join(t.TempDir(), "downtime-report.yaml")
+	if err := utils.WriteObjectToYAML(report.toQueryResults("redhat-rhoam-", "1.2.0"), file); err != nil {
+		t.Fatalf("failed to write the query results: %v", err)
+	}
+
+	// the results can be loaded and parsed like in the datahub-import command
+	qr := &queryResults{}
+	if err := utils.PopulateObjectFromYAML(file, qr); err != nil {
+		t.Fatalf("failed to read the query results: %v", err)
+	}
+	if qr.Version != "1.2.0" {
+		t.Errorf("expected version 1.2.0 but got %s", qr.Version)
+	}
+	expected := map[string]float64{
+		"3scale_downtime_seconds":                                40,
+		"3scale_apicast_production_dc_downtime_seconds":          30,
+		"3scale_threescale_operator_deployment_downtime_seconds": 0,
+	}
+	if len(qr.Results) != len(expected) {
+		t.Fatalf("expected %d results but got %d", len(expected), len(qr.Results))
+	}
+	for _, r := range qr.Results {
+		samples, err := querySamples(r.v, aggregationLast)
+		if err != nil || len(samples) != 1 {
+			t.Fatalf("failed to get the value of %s: %v", r.Name, err)
+		}
+		if e, ok := expected[r.Name]; !ok || samples[0].Value != e {
+			t.Errorf("unexpected result %s: %v", r.Name, samples[0].Value)
+		}
+	}
+}
+
+func TestMeasureDowntimeCmd(t *testing.T) {
+	dcResource := schema.GroupVersionResource{Group: "apps.openshift.io", Version: "v1", Resource: "deploymentconfigs"}
+	deploymentResource := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			dcResource:         "DeploymentConfigList",
+			deploymentResource: "DeploymentList",
+			{Group: "apps", Version: "v1", Resource: "statefulsets"}: "StatefulSetList",
+		},
+		newTestWorkload("apps.openshift.io/v1", "DeploymentConfig", "redhat-rhmi-3scale", "zync", 1, 1),
+		newTestWorkload("apps/v1", "Deployment", "redhat-rhmi-amq-online", "api-server", 1, 1),
+	)
+	clientset := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "redhat-rhmi-3scale"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "redhat-rhmi-amq-online"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-monitoring"}},
+	)
+	dir := t.TempDir()
+	c := &measureDowntimeCmd{
+		clientset:        clientset,
+		dynamicClient:    dynamicClient,
+		output:           path.Join(dir, "downtime.json"),
+		queryResultsFile: path.Join(dir, "downtime-report.yaml"),
+		version:          "2.8.0",
+		saveInterval:     10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- c.run(ctx)
+	}()
+
+	// wait for the workloads to be monitored before making the api-server unavailable
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return utils.FileExists(c.output), nil
+	})
+	if err != nil {
+		t.Fatalf("the report was not saved: %v", err)
+	}
+	apiServer := newTestWorkload("apps/v1", "Deployment", "redhat-rhmi-amq-online", "api-server", 1, 0)
+	if _, err := dynamicClient.Resource(deploymentResource).Namespace("redhat-rhmi-amq-online").Update(context.TODO(), apiServer, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the deployment: %v", err)
+	}
+	if err := dynamicClient.Resource(dcResource).Namespace("redhat-rhmi-3scale").Delete(context.TODO(), "zync", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the deployment config: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.namespacePrefix != "redhat-rhmi-" {
+		t.Errorf("expected the prefix redhat-rhmi- to be detected but got %s", c.namespacePrefix)
+	}
+	b, err := ioutil.ReadFile(c.output)
+	if err != nil {
+		t.Fatalf("failed to read the report: %v", err)
+	}
+	report := &downtimeReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		t.Fatalf("failed to unmarshal the report: %v", err)
+	}
+	if len(report.Projects) != 2 {
+		t.Fatalf("expected 2 projects but got %s", b)
+	}
+	for _, p := range report.Projects {
+		if len(p.Downtimes) != 1 {
+			t.Errorf("expected one downtime for %s but got %v", p.Name, p.Downtimes)
+		}
+	}
+	if len(report.Projects[0].DCs) != 1 || len(report.Projects[0].DCs[0].Downtimes) != 1 {
+		t.Errorf("expected the deleted deployment config to be down but got %s", b)
+	}
+	if len(report.Projects[1].Deployments) != 1 || report.Projects[1].Deployments[0].Ready != 0 {
+		t.Errorf("expected the api-server to be down but got %s", b)
+	}
+
+	qr := &queryResults{}
+	if err := utils.PopulateObjectFromYAML(c.queryResultsFile, qr); err != nil {
+		t.Fatalf("failed to read the query results: %v", err)
+	}
+	if len(qr.Results) != 4 || qr.Version != "2.8.0" {
+		t.Errorf("unexpected query results: %+v", qr)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-github/v30/github"
 	"github.com/integr8ly/delorean/pkg/services"
+	"github.com/integr8ly/delorean/pkg/services/fake"
 	"github.com/integr8ly/delorean/pkg/utils"
 )
 
@@ -624,3 +625,103 @@ func Test_openshiftCIReleaseCmd_DoOpenShiftReleaseUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenshiftCIReleaseWithFakeSCM(t *testing.T) {
+	server, err := fake.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	basedir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CreateRepo("integr8ly/integreatly-operator", "master", path.Join(basedir, "testdata/createReleaseTest")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CreateRepo("openshift/release", "master", path.Join(basedir, "testdata/release")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ForkRepo("openshift/release", "integr8ly/release"); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultGithubURL := githubURL
+	githubURL = server.URL
+	defer func() { githubURL = defaultGithubURL }()
+
+	client, err := github.NewEnterpriseClient(server.GitHubAPIURL(), server.GitHubAPIURL(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ := utils.NewVersion("2.0.0-rc1", types.OlmTypeRhmi)
+	cmd := &openshiftCIReleaseCmd{
+		version:                 version,
+		baseBranch:              plumbing.NewBranchReferenceName("master"),
+		intlyRepoInfo:           &githubRepoInfo{owner: "integr8ly", repo: "integreatly-operator"},
+		releaseRepoInfoOrigin:   &githubRepoInfo{owner: "integr8ly", repo: "release"},
+		releaseRepoInfoUpstream: &githubRepoInfo{owner: "openshift", repo: "release"},
+		githubPRService:         client.PullRequests,
+		gitUser:                 "testuser",
+		gitPass:                 "testpass",
+		gitCloneService:         &services.DefaultGitCloneService{},
+		gitPushService:          &services.DefaultGitPushService{},
+		gitRemoteService:        &services.DefaultGitRemoteService{},
+	}
+
+	intlyOperatorDir, err := cmd.DoIntlyOperatorUpdate()
+	if intlyOperatorDir != "" {
+		defer os.RemoveAll(intlyOperatorDir)
+	}
+	if err != nil {
+		t.Fatalf("failed to update the integreatly-operator repo: %v", err)
+	}
+	operator, err := server.Repo("integr8ly/integreatly-operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := operator.Reference(plumbing.NewBranchReferenceName("release-v2.0"), true); err != nil {
+		t.Fatalf("release branch not pushed to the integreatly-operator repo: %v", err)
+	}
+
+	// a rerun against the same repos finds no new changes and opens no other PR
+	for i := 0; i < 2; i++ {
+		releaseDir, err := cmd.DoOpenShiftReleaseUpdate(context.TODO())
+		if releaseDir != "" {
+			defer os.RemoveAll(releaseDir)
+		}
+		if err != nil {
+			t.Fatalf("failed to update the release repo: %v", err)
+		}
+	}
+
+	prs := server.PullRequests("openshift/release")
+	if len(prs) != 1 {
+		t.Fatalf("expected 1 PR but found %d", len(prs))
+	}
+	if prs[0].GetHead().GetLabel() != "integr8ly:release-v2.0" || prs[0].GetBase().GetRef() != "master" {
+		t.Fatalf("unexpected PR: %+v", prs[0])
+	}
+
+	release, err := server.Repo("integr8ly/release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := release.Reference(plumbing.NewBranchReferenceName("release-v2.0"), true)
+	if err != nil {
+		t.Fatalf("release branch not pushed to the release fork: %v", err)
+	}
+	commit, err := release.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{
+		"ci-operator/config/integr8ly/integreatly-operator/integr8ly-integreatly-operator-release-v2.0.yaml",
+		"core-services/image-mirroring/integr8ly/mapping_integr8ly_operator_2_0",
+	} {
+		if _, err := commit.File(f); err != nil {
+			t.Fatalf("expected the file %s in the release branch: %v", f, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,30 +10,36 @@ import (
 	"strings"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/integr8ly/delorean/pkg/ocm"
 	"github.com/integr8ly/delorean/pkg/services"
 	"github.com/integr8ly/delorean/pkg/utils"
 	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
-const (
-	gitlabTokenKey = "gitlab_token"
-
+var (
 	// Base URL for gitlab API and for the managed-tenenats fork and origin repos
 	gitlabURL = "https://gitlab.cee.redhat.com"
 
-	gitlabAPIEndpoint = "api/v4"
-
 	// Base URL for the integreatly-opeartor repo
 	githubURL = "https://github.com"
+)
+
+const (
+	gitlabTokenKey = "gitlab_token"
+	ocmTokenKey    = "ocm_token"
+
+	gitlabAPIEndpoint = "api/v4"
 
 	// The branch to target with the merge request
 	managedTenantsMainBranch = "main"
@@ -43,6 +51,11 @@ const (
 	commitAuthorEmail         = "cloud-services-delorean@redhat.com"
 	mergeRequestTitleTemplate = "Update %s %s to %s" // channel, version
 
+	// GitLab merge request and pipeline states
+	mergeRequestStateMerged = "merged"
+	mergeRequestStateClosed = "closed"
+	pipelineStatusFailed    = "failed"
+	pipelineStatusCanceled  = "canceled"
 )
 
 type addonImageSet struct {
@@ -60,6 +73,9 @@ type releaseChannel struct {
 	Directory       string `json:"directory"`
 	Environment     string `json:"environment"`
 	AllowPreRelease bool   `json:"allow_pre_release"`
+	OCMAddonID      string `json:"ocm_addon_id,omitempty"`
+	// Transforms are applied after the addon transforms
+	Transforms *csvTransforms `json:"transforms,omitempty"`
 }
 
 type addonBundleConfig struct {
@@ -84,6 +100,10 @@ type deploymentContainerEnvVar struct {
 type deploymentContainer struct {
 	Name    string                      `json:"name"`
 	EnvVars []deploymentContainerEnvVar `json:"env_vars"`
+	// RemoveEnvVars are removed from the container after the EnvVars are set
+	RemoveEnvVars []string `json:"remove_env_vars,omitempty"`
+	// KeepEnvVars preserves the env vars already defined in the CSV instead of replacing them with EnvVars
+	KeepEnvVars bool `json:"keep_env_vars,omitempty"`
 }
 
 type deployment struct {
@@ -95,11 +115,35 @@ type override struct {
 	Deployment deployment `json:"deployment"`
 }
 
+// jsonPatchOperation is a RFC 6902 JSON patch operation
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// csvTransforms are the declarative changes applied to the CSV and to the bundle
+// metadata annotations when the bundle is copied to the managed-tenants repo
+type csvTransforms struct {
+	// NameSuffix is appended to the package name in the CSV name and replaces fields
+	NameSuffix string `json:"name_suffix,omitempty"`
+	// InstallModes sets the supported flag of the install modes
+	InstallModes map[olmapiv1alpha1.InstallModeType]bool `json:"install_modes,omitempty"`
+	// CSVAnnotations are set in the CSV metadata annotations
+	CSVAnnotations map[string]string `json:"csv_annotations,omitempty"`
+	// BundleAnnotations are set in the metadata/annotations.yaml bundle file
+	BundleAnnotations map[string]string `json:"bundle_annotations,omitempty"`
+	// JSONPatch is applied to the CSV after all other transforms
+	JSONPatch []jsonPatchOperation `json:"json_patch,omitempty"`
+}
+
 type addonConfig struct {
-	Name     string            `json:"name"`
-	Bundle   addonBundleConfig `json:"bundle"`
-	Channels []releaseChannel  `json:"channels"`
-	Override *override         `json:"override,omitempty"`
+	Name       string            `json:"name"`
+	Bundle     addonBundleConfig `json:"bundle"`
+	Channels   []releaseChannel  `json:"channels"`
+	Override   *override         `json:"override,omitempty"`
+	Transforms *csvTransforms    `json:"transforms,omitempty"`
 }
 
 type addons struct {
@@ -116,10 +160,43 @@ type osdAddonReleaseFlags struct {
 	version                 string
 	channel                 string
 	mergeRequestDescription string
+	mergeRequestComment     string
 	managedTenantsOrigin    string
 	managedTenantsFork      string
 	addonName               string
 	addonsConfig            string
+	wait                    bool
+	waitInterval            int64
+	waitMax                 int64
+	checkAddonVersion       bool
+	summaryFile             string
+}
+
+// validate rejects the combinations of flags that can not be used together
+func (f *osdAddonReleaseFlags) validate() error {
+	if f.checkAddonVersion && !f.wait {
+		return fmt.Errorf("--check-addon-version requires --wait")
+	}
+	return nil
+}
+
+// mergeRequestState is the state of the merge request as reported by GitLab
+type mergeRequestState struct {
+	State          string `json:"state"`
+	PipelineStatus string `json:"pipeline_status,omitempty"`
+	PipelineURL    string `json:"pipeline_url,omitempty"`
+	ApprovalsLeft  int    `json:"approvals_left"`
+}
+
+// osdAddonReleaseSummary is written to the summary file when waiting for the merge request
+type osdAddonReleaseSummary struct {
+	Addon        string             `json:"addon"`
+	Channel      string             `json:"channel"`
+	Version      string             `json:"version"`
+	MergeRequest string             `json:"merge_request"`
+	Status       *mergeRequestState `json:"status,omitempty"`
+	AddonLive    bool               `json:"addon_live"`
+	Error        string             `json:"error,omitempty"`
 }
 
 type osdAddonReleaseCmd struct {
@@ -128,6 +205,8 @@ type osdAddonReleaseCmd struct {
 	version             *utils.RHMIVersion
 	gitlabMergeRequests services.GitLabMergeRequestsService
 	gitlabProjects      services.GitLabProjectsService
+	gitlabNotes         services.GitLabNotesService
+	ocmAddons           ocm.AddonsManager
 	managedTenantsDir   string
 	managedTenantsRepo  *git.Repository
 	gitPushService      services.GitPushService
@@ -153,13 +232,25 @@ func init() {
 		Short: "Create a MR to the managed-tenants repo for the giving addon to update its version",
 		Run: func(cmd *cobra.Command, args []string) {
 
+			if err := f.validate(); err != nil {
+				handleError(err)
+			}
+
 			gitlabToken, err := requireValue(gitlabTokenKey)
 			if err != nil {
 				handleError(err)
 			}
 
+			ocmToken := ""
+			if f.checkAddonVersion {
+				ocmToken, err = requireValue(ocmTokenKey)
+				if err != nil {
+					handleError(err)
+				}
+			}
+
 			// Prepare
-			c, err := newOSDAddonReleaseCmd(f, gitlabToken)
+			c, err := newOSDAddonReleaseCmd(f, gitlabToken, ocmToken)
 			if err != nil {
 				handleError(err)
 			}
@@ -202,6 +293,29 @@ func init() {
 		"Optional merge request description that can be used to notify secific users (ex \"ping: @dbizzarr\")",
 	)
 
+	cmd.Flags().StringVar(
+		&f.mergeRequestComment,
+		"merge-request-comment",
+		"",
+		"Optional comment to post on the merge request when an already open merge request is updated",
+	)
+
+	cmd.Flags().BoolVar(&f.wait, "wait", false, "Wait for the merge request to be merged")
+	cmd.Flags().Int64Var(&f.waitInterval, "wait-interval", 5, "Specify the interval to check the merge request while waiting. In minutes.")
+	cmd.Flags().Int64Var(&f.waitMax, "wait-max", 240, "Specify the max wait time for the merge request to be merged and the addon version to be live. In minutes.")
+	cmd.Flags().BoolVar(
+		&f.checkAddonVersion,
+		"check-addon-version",
+		false,
+		"After the merge request is merged, wait for the addon version to be live in OCM (requires --wait and the ocm_addon_id in the addons-config)")
+	cmd.Flags().StringVar(&f.summaryFile, "summary-file", "", "Optional path to the file where to write the summary of the wait as JSON")
+
+	cmd.Flags().String(
+		"ocm-token",
+		"",
+		fmt.Sprintf("OCM offline token used to verify the addon version. Can be set via the %s env var", strings.ToUpper(ocmTokenKey)))
+	viper.BindPFlag(ocmTokenKey, cmd.Flags().Lookup("ocm-token"))
+
 	mtOrigin := ""
 	mtFork := ""
 	if f.channel == "stable" {
@@ -249,7 +363,7 @@ func findChannel(addon *addonConfig, channelName string) *releaseChannel {
 	return currentChannel
 }
 
-func newOSDAddonReleaseCmd(flags *osdAddonReleaseFlags, gitlabToken string) (*osdAddonReleaseCmd, error) {
+func newOSDAddonReleaseCmd(flags *osdAddonReleaseFlags, gitlabToken string, ocmToken string) (*osdAddonReleaseCmd, error) {
 	version, err := utils.NewVersion(flags.version, olmType)
 	if err != nil {
 		return nil, err
@@ -281,6 +395,18 @@ func newOSDAddonReleaseCmd(flags *osdAddonReleaseFlags, gitlabToken string) (*os
 	}
 	fmt.Print("gitlab client initialized and authenticated\n")
 
+	var ocmAddons ocm.AddonsManager
+	if flags.checkAddonVersion {
+		if currentChannel.OCMAddonID == "" {
+			return nil, fmt.Errorf("ocm_addon_id is not defined for channel %s of addon %s in config file %s", flags.channel, flags.addonName, flags.addonsConfig)
+		}
+		ocmClient := ocm.NewClient(ocm.NewTokenClient(context.Background(), ocmToken))
+		if currentChannel.Environment == "stage" {
+			ocmClient.BaseURL, _ = ocmClient.BaseURL.Parse(ocm.StageBaseURL)
+		}
+		ocmAddons = ocmClient.Addons
+	}
+
 	gitCloneService := &services.DefaultGitCloneService{}
 	// Clone the managed tenants
 	// TODO: Move the clone functions inside the run() method to improve the test covered code
@@ -329,6 +455,8 @@ func newOSDAddonReleaseCmd(flags *osdAddonReleaseFlags, gitlabToken string) (*os
 		version:             version,
 		gitlabMergeRequests: gitlabClient.MergeRequests,
 		gitlabProjects:      gitlabClient.Projects,
+		gitlabNotes:         gitlabClient.Notes,
+		ocmAddons:           ocmAddons,
 		managedTenantsDir:   managedTenantsDir,
 		managedTenantsRepo:  managedTenantsRepo,
 		gitPushService:      &services.DefaultGitPushService{},
@@ -434,47 +562,237 @@ func (c *osdAddonReleaseCmd) run() error {
 		return fmt.Errorf("the tree is not clean, uncommited changes:\n%+v", status)
 	}
 
-	// Push to fork
+	// Push to fork, the branch is force pushed so that reruns for the same version update the existing branch
 	fmt.Printf("push the managed-tenants repo to the fork remote\n")
 	err = c.gitPushService.Push(c.managedTenantsRepo, &git.PushOptions{
 		RemoteName: "fork",
 		Auth:       &http.BasicAuth{Password: c.gitlabToken},
 		RefSpecs: []config.RefSpec{
-			config.RefSpec(branchRef + ":" + branchRef),
+			config.RefSpec("+" + branchRef + ":" + branchRef),
 		},
+		Force: true,
 	})
-	if err != nil {
+	if err != nil && err != git.NoErrAlreadyUpToDate {
 		return err
 	}
 
-	// Create the merge request
 	targetProject, _, err := c.gitlabProjects.GetProject(c.flags.managedTenantsOrigin, &gitlab.GetProjectOptions{})
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("create the MR to the managed-tenants origin\n")
-	mr, _, err := c.gitlabMergeRequests.CreateMergeRequest(c.flags.managedTenantsFork, &gitlab.CreateMergeRequestOptions{
-		Title:              gitlab.String(fmt.Sprintf(mergeRequestTitleTemplate, c.addonConfig.Name, c.currentChannel.Name, c.version)),
-		Description:        gitlab.String(c.flags.mergeRequestDescription),
-		SourceBranch:       gitlab.String(managedTenantsBranch),
-		TargetBranch:       gitlab.String(managedTenantsMainBranch),
-		TargetProjectID:    gitlab.Int(targetProject.ID),
-		RemoveSourceBranch: gitlab.Bool(true),
-	})
+	forkProject, _, err := c.gitlabProjects.GetProject(c.flags.managedTenantsFork, &gitlab.GetProjectOptions{})
+	if err != nil {
+		return err
+	}
+
+	mr, err := c.findOpenMergeRequest(targetProject.ID, forkProject.ID, managedTenantsBranch)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("merge request for version %s and channel %s created successfully\n", c.version, c.currentChannel.Name)
+	if mr != nil {
+		// Update the existing merge request
+		fmt.Printf("update the existing MR !%d to the managed-tenants origin\n", mr.IID)
+		mr, err = c.updateMergeRequest(targetProject.ID, mr)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("merge request for version %s and channel %s updated successfully\n", c.version, c.currentChannel.Name)
+	} else {
+		// Create the merge request
+		fmt.Print("create the MR to the managed-tenants origin\n")
+		mr, _, err = c.gitlabMergeRequests.CreateMergeRequest(c.flags.managedTenantsFork, &gitlab.CreateMergeRequestOptions{
+			Title:              gitlab.String(fmt.Sprintf(mergeRequestTitleTemplate, c.addonConfig.Name, c.currentChannel.Name, c.version)),
+			Description:        gitlab.String(c.flags.mergeRequestDescription),
+			SourceBranch:       gitlab.String(managedTenantsBranch),
+			TargetBranch:       gitlab.String(managedTenantsMainBranch),
+			TargetProjectID:    gitlab.Int(targetProject.ID),
+			RemoveSourceBranch: gitlab.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("merge request for version %s and channel %s created successfully\n", c.version, c.currentChannel.Name)
+	}
+
 	fmt.Printf("MR: %s\n", mr.WebURL)
 
+	if _, err := c.getMergeRequestState(targetProject.ID, mr); err != nil {
+		return err
+	}
+
 	// Reset the managed repostiroy to master
 	err = managedTenantsTree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(managedTenantsMainBranch)})
 	if err != nil {
 		return err
 	}
 
+	if c.flags.wait {
+		return c.waitForMergeRequest(targetProject.ID, mr)
+	}
+
+	return nil
+}
+
+// findOpenMergeRequest returns the open merge request in the target project for the given
+// source branch of the fork, or nil if there is none. Merge requests from other forks with
+// the same branch name are ignored
+func (c *osdAddonReleaseCmd) findOpenMergeRequest(targetProjectID int, forkProjectID int, sourceBranch string) (*gitlab.MergeRequest, error) {
+	mrs, _, err := c.gitlabMergeRequests.ListProjectMergeRequests(targetProjectID, &gitlab.ListProjectMergeRequestsOptions{
+		State:        gitlab.String("opened"),
+		SourceBranch: gitlab.String(sourceBranch),
+		TargetBranch: gitlab.String(managedTenantsMainBranch),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, mr := range mrs {
+		if mr.SourceProjectID == forkProjectID {
+			return mr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *osdAddonReleaseCmd) updateMergeRequest(targetProjectID int, mr *gitlab.MergeRequest) (*gitlab.MergeRequest, error) {
+	updated, _, err := c.gitlabMergeRequests.UpdateMergeRequest(targetProjectID, mr.IID, &gitlab.UpdateMergeRequestOptions{
+		Title:       gitlab.String(fmt.Sprintf(mergeRequestTitleTemplate, c.addonConfig.Name, c.currentChannel.Name, c.version)),
+		Description: gitlab.String(c.flags.mergeRequestDescription),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.flags.mergeRequestComment != "" {
+		fmt.Printf("comment on the MR !%d\n", updated.IID)
+		_, _, err = c.gitlabNotes.CreateMergeRequestNote(targetProjectID, updated.IID, &gitlab.CreateMergeRequestNoteOptions{
+			Body: gitlab.String(c.flags.mergeRequestComment),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// getMergeRequestState prints and returns the state, the latest pipeline and the approvals of the merge request
+func (c *osdAddonReleaseCmd) getMergeRequestState(targetProjectID int, mr *gitlab.MergeRequest) (*mergeRequestState, error) {
+	state := &mergeRequestState{State: mr.State}
+
+	// The pipelines are returned from the newest to the oldest
+	pipelines, _, err := c.gitlabMergeRequests.ListMergeRequestPipelines(targetProjectID, mr.IID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pipelines) == 0 {
+		fmt.Println("MR pipelines: none")
+	} else {
+		state.PipelineStatus = pipelines[0].Status
+		state.PipelineURL = pipelines[0].WebURL
+	}
+	for _, p := range pipelines {
+		fmt.Printf("MR pipeline %d: %s %s\n", p.ID, p.Status, p.WebURL)
+	}
+
+	approvals, _, err := c.gitlabMergeRequests.GetMergeRequestApprovals(targetProjectID, mr.IID)
+	if err != nil {
+		return nil, err
+	}
+	var approvedBy []string
+	for _, a := range approvals.ApprovedBy {
+		if a.User != nil {
+			approvedBy = append(approvedBy, a.User.Username)
+		}
+	}
+	state.ApprovalsLeft = approvals.ApprovalsLeft
+	fmt.Printf("MR approvals: %d required, %d left, approved by [%s]\n", approvals.ApprovalsRequired, approvals.ApprovalsLeft, strings.Join(approvedBy, ", "))
+	return state, nil
+}
+
+// waitForMergeRequest waits for the merge request to be merged and, if requested, for the
+// addon version to be live in OCM. The summary of the wait is written to the summary file.
+func (c *osdAddonReleaseCmd) waitForMergeRequest(targetProjectID int, mr *gitlab.MergeRequest) error {
+	summary := &osdAddonReleaseSummary{
+		Addon:        c.addonConfig.Name,
+		Channel:      c.currentChannel.Name,
+		Version:      c.version.String(),
+		MergeRequest: mr.WebURL,
+	}
+
+	err := c.pollMergeRequest(targetProjectID, mr, summary)
+	if err == nil && c.ocmAddons != nil {
+		err = c.pollAddonVersion(summary)
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	if c.flags.summaryFile != "" {
+		b, merr := json.MarshalIndent(summary, "", "  ")
+		if merr != nil {
+			return merr
+		}
+		if werr := ioutil.WriteFile(c.flags.summaryFile, b, 0644); werr != nil {
+			return werr
+		}
+		fmt.Printf("summary written to %s\n", c.flags.summaryFile)
+	}
+	return err
+}
+
+func (c *osdAddonReleaseCmd) pollMergeRequest(targetProjectID int, mr *gitlab.MergeRequest, summary *osdAddonReleaseSummary) error {
+	fmt.Println("Wait for the MR to be merged. Will check every", c.flags.waitInterval, "minutes for", c.flags.waitMax, "minutes")
+	err := wait.PollImmediate(time.Duration(c.flags.waitInterval)*time.Minute, time.Duration(c.flags.waitMax)*time.Minute, func() (bool, error) {
+		current, _, err := c.gitlabMergeRequests.GetMergeRequest(targetProjectID, mr.IID, &gitlab.GetMergeRequestsOptions{})
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("MR state: %s\n", current.State)
+		state, err := c.getMergeRequestState(targetProjectID, current)
+		if err != nil {
+			return false, err
+		}
+		summary.Status = state
+
+		switch {
+		case state.State == mergeRequestStateMerged:
+			return true, nil
+		case state.State == mergeRequestStateClosed:
+			return false, fmt.Errorf("the MR %s has been closed without being merged", mr.WebURL)
+		case state.PipelineStatus == pipelineStatusFailed || state.PipelineStatus == pipelineStatusCanceled:
+			return false, fmt.Errorf("the pipeline %s of the MR %s is %s", state.PipelineURL, mr.WebURL, state.PipelineStatus)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("the MR %s has not been merged after %d minutes", mr.WebURL, c.flags.waitMax)
+	}
+	return err
+}
+
+func (c *osdAddonReleaseCmd) pollAddonVersion(summary *osdAddonReleaseSummary) error {
+	addonID := c.currentChannel.OCMAddonID
+	fmt.Printf("Wait for the version %s of the addon %s to be live in OCM\n", c.version.Base(), addonID)
+	err := wait.PollImmediate(time.Duration(c.flags.waitInterval)*time.Minute, time.Duration(c.flags.waitMax)*time.Minute, func() (bool, error) {
+		addon, err := c.ocmAddons.Get(context.TODO(), addonID)
+		if err != nil {
+			return false, err
+		}
+		if addon.Version == nil || addon.Version.ID != c.version.Base() {
+			fmt.Printf("addon %s version in OCM is %+v. Will try again later.\n", addonID, addon.Version)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("the version %s of the addon %s is not live in OCM after %d minutes", c.version.Base(), addonID, c.flags.waitMax)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("the version %s of the addon %s is live in OCM\n", c.version.Base(), addonID)
+	summary.AddonLive = true
 	return nil
 }
 
@@ -592,59 +910,123 @@ func (c *osdAddonReleaseCmd) updateTheCSVManifest() (string, error) {
 		return "", err
 	}
 
-	// We need to make sure that all envs present in the container are removed as they are going to be set directly from addon.yaml file instead, however,
-	// for development ease of use, envs should remain in the base CSV.
 	if c.addonConfig.Override != nil {
-		_, deployment := utils.FindDeploymentByName(csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs, c.addonConfig.Override.Deployment.Name)
-		if deployment != nil {
-			i, container := utils.FindContainerByName(deployment.Spec.Template.Spec.Containers, c.addonConfig.Override.Deployment.Container.Name)
-			if container != nil {
-				container.Env = nil
-				for _, envVar := range c.addonConfig.Override.Deployment.Container.EnvVars {
-					if envVar.Name == "WATCH_NAMESPACE" || envVar.Name == "POD_NAME" {
-						container.Env = utils.AddOrUpdateEnvVarWithSource(container.Env, envVar.Name, envVar.Value, envVar.ValueFrom.FieldRef.FieldPath)
-					} else {
-						container.Env = utils.AddOrUpdateEnvVar(container.Env, envVar.Name, envVar.Value)
-					}
-				}
-			}
-			deployment.Spec.Template.Spec.Containers[i] = *container
+		applyDeploymentOverride(csv, &c.addonConfig.Override.Deployment)
+	}
+
+	for _, t := range []*csvTransforms{c.addonConfig.Transforms, c.currentChannel.Transforms} {
+		if t == nil {
+			continue
+		}
+		csv, err = t.apply(csv, &metadataAnnotations)
+		if err != nil {
+			return "", err
 		}
 	}
 
-	if c.currentChannel.Name == "edge" && c.addonConfig.Name == "managed-api-service" {
-		nameVersion := strings.Split(csv.Name, ".v")[1]
-		csv.Name = fmt.Sprintf("%v-internal.v%v", c.addonConfig.Name, nameVersion)
+	err = utils.WriteK8sObjectToYAML(csv, csvFile)
+	if err != nil {
+		return "", err
+	}
+	err = utils.WriteK8sObjectToYAML(&metadataAnnotations, metadataFile)
+	if err != nil {
+		return "", err
+	}
+	return relative, nil
+}
 
-		replacesVersion := strings.Split(csv.Spec.Replaces, ".v")[1]
-		csv.Spec.Replaces = fmt.Sprintf("%v-internal.v%v", c.addonConfig.Name, replacesVersion)
+// applyDeploymentOverride sets the env vars of the deployment container. We need to make sure that all envs present
+// in the container are removed as they are going to be set directly from addon.yaml file instead, however,
+// for development ease of use, envs should remain in the base CSV.
+func applyDeploymentOverride(csv *olmapiv1alpha1.ClusterServiceVersion, override *deployment) {
+	_, deployment := utils.FindDeploymentByName(csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs, override.Name)
+	if deployment == nil {
+		return
+	}
+	i, container := utils.FindContainerByName(deployment.Spec.Template.Spec.Containers, override.Container.Name)
+	if container == nil {
+		return
+	}
+	if !override.Container.KeepEnvVars {
+		container.Env = nil
+	}
+	for _, envVar := range override.Container.EnvVars {
+		if envVar.ValueFrom.FieldRef.FieldPath != "" {
+			container.Env = utils.AddOrUpdateEnvVarWithSource(container.Env, envVar.Name, envVar.Value, envVar.ValueFrom.FieldRef.FieldPath)
+		} else {
+			container.Env = utils.AddOrUpdateEnvVar(container.Env, envVar.Name, envVar.Value)
+		}
+	}
+	for _, name := range override.Container.RemoveEnvVars {
+		container.Env = utils.RemoveEnvVar(container.Env, name)
+	}
+	deployment.Spec.Template.Spec.Containers[i] = *container
+}
 
-		annotationsToBeUpdated := []string{"operators.operatorframework.io.bundle.package.v1", "operators.operatorframework.io.bundle.channels.v1", "operators.operatorframework.io.bundle.channel.default.v1"}
+// apply applies the transforms to the csv and to the bundle metadata annotations and returns the updated csv
+func (t *csvTransforms) apply(csv *olmapiv1alpha1.ClusterServiceVersion, annotations *metadataAnnotations) (*olmapiv1alpha1.ClusterServiceVersion, error) {
+	if t.NameSuffix != "" {
+		csv.Name = addSuffixToCSVName(csv.Name, t.NameSuffix)
+		if csv.Spec.Replaces != "" {
+			csv.Spec.Replaces = addSuffixToCSVName(csv.Spec.Replaces, t.NameSuffix)
+		}
+	}
 
-		for _, annotation := range annotationsToBeUpdated {
-			if value, found := metadataAnnotations.Annotations[annotation]; found && value == "managed-api-service" {
-				metadataAnnotations.Annotations[annotation] = "managed-api-service-internal"
-			}
-			if value, found := metadataAnnotations.Annotations[annotation]; found && value == "stable" {
-				metadataAnnotations.Annotations[annotation] = "edge"
-			}
+	for mode, supported := range t.InstallModes {
+		i, m := utils.FindInstallMode(csv.Spec.InstallModes, mode)
+		if m == nil {
+			csv.Spec.InstallModes = append(csv.Spec.InstallModes, olmapiv1alpha1.InstallMode{Type: mode, Supported: supported})
+			continue
 		}
+		m.Supported = supported
+		csv.Spec.InstallModes[i] = *m
 	}
 
-	//Set SingleNamespace install mode to true
-	mi, m := utils.FindInstallMode(csv.Spec.InstallModes, olmapiv1alpha1.InstallModeTypeSingleNamespace)
-	if m != nil {
-		m.Supported = true
+	if len(t.CSVAnnotations) > 0 && csv.Annotations == nil {
+		csv.Annotations = map[string]string{}
+	}
+	for k, v := range t.CSVAnnotations {
+		csv.Annotations[k] = v
 	}
-	csv.Spec.InstallModes[mi] = *m
 
-	err = utils.WriteK8sObjectToYAML(csv, csvFile)
+	if len(t.BundleAnnotations) > 0 && annotations.Annotations == nil {
+		annotations.Annotations = map[string]string{}
+	}
+	for k, v := range t.BundleAnnotations {
+		annotations.Annotations[k] = v
+	}
+
+	if len(t.JSONPatch) == 0 {
+		return csv, nil
+	}
+	rawPatch, err := json.Marshal(t.JSONPatch)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	err = utils.WriteK8sObjectToYAML(&metadataAnnotations, metadataFile)
+	patch, err := jsonpatch.DecodePatch(rawPatch)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("invalid json_patch: %w", err)
 	}
-	return relative, nil
+	rawCSV, err := json.Marshal(csv)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patch.Apply(rawCSV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the json_patch to the csv %s: %w", csv.Name, err)
+	}
+	result := &olmapiv1alpha1.ClusterServiceVersion{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addSuffixToCSVName adds the suffix to the package name of the CSV name (ex "name.v1.0.0" -> "name-suffix.v1.0.0")
+func addSuffixToCSVName(name string, suffix string) string {
+	parts := strings.SplitN(name, ".v", 2)
+	if len(parts) != 2 {
+		return name + suffix
+	}
+	return fmt.Sprintf("%s%s.v%s", parts[0], suffix, parts[1])
 }
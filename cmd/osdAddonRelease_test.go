@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
 
+	"github.com/integr8ly/delorean/pkg/ocm"
 	"github.com/integr8ly/delorean/pkg/services"
+	"github.com/integr8ly/delorean/pkg/services/fake"
 	"github.com/integr8ly/delorean/pkg/types"
 
 	"github.com/ghodss/yaml"
@@ -27,13 +31,46 @@ const (
 )
 
 type gitlabMergeRequestMock struct {
-	createMergeRequest func(pid interface{}, opt *gitlab.CreateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error)
+	createMergeRequest        func(pid interface{}, opt *gitlab.CreateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error)
+	getMergeRequest           func(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error)
+	listProjectMergeRequests  func(pid interface{}, opt *gitlab.ListProjectMergeRequestsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequest, *gitlab.Response, error)
+	updateMergeRequest        func(pid interface{}, mergeRequest int, opt *gitlab.UpdateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error)
+	getMergeRequestApprovals  func(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error)
+	listMergeRequestPipelines func(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.PipelineInfo, *gitlab.Response, error)
 }
 
 func (m *gitlabMergeRequestMock) CreateMergeRequest(pid interface{}, opt *gitlab.CreateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
 	return m.createMergeRequest(pid, opt, options...)
 }
 
+func (m *gitlabMergeRequestMock) GetMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	return m.getMergeRequest(pid, mergeRequest, opt, options...)
+}
+
+func (m *gitlabMergeRequestMock) ListProjectMergeRequests(pid interface{}, opt *gitlab.ListProjectMergeRequestsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequest, *gitlab.Response, error) {
+	return m.listProjectMergeRequests(pid, opt, options...)
+}
+
+func (m *gitlabMergeRequestMock) UpdateMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.UpdateMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	return m.updateMergeRequest(pid, mergeRequest, opt, options...)
+}
+
+func (m *gitlabMergeRequestMock) GetMergeRequestApprovals(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	return m.getMergeRequestApprovals(pid, mergeRequest, options...)
+}
+
+func (m *gitlabMergeRequestMock) ListMergeRequestPipelines(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.PipelineInfo, *gitlab.Response, error) {
+	return m.listMergeRequestPipelines(pid, mergeRequest, options...)
+}
+
+type gitlabNotesMock struct {
+	createMergeRequestNote func(pid interface{}, mergeRequest int, opt *gitlab.CreateMergeRequestNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error)
+}
+
+func (m *gitlabNotesMock) CreateMergeRequestNote(pid interface{}, mergeRequest int, opt *gitlab.CreateMergeRequestNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
+	return m.createMergeRequestNote(pid, mergeRequest, opt, options...)
+}
+
 type gitlabProjectsMock struct {
 	getProject func(pid interface{}, opt *gitlab.GetProjectOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Project, *gitlab.Response, error)
 }
@@ -42,6 +79,14 @@ func (m *gitlabProjectsMock) GetProject(pid interface{}, opt *gitlab.GetProjectO
 	return m.getProject(pid, opt, options...)
 }
 
+type ocmAddonsMock struct {
+	get func(ctx context.Context, addonID string) (*ocm.Addon, error)
+}
+
+func (m *ocmAddonsMock) Get(ctx context.Context, addonID string) (*ocm.Addon, error) {
+	return m.get(ctx, addonID)
+}
+
 func initRepoFromTestDir(prefix string, testDir string) (string, *git.Repository, error) {
 	dir, err := ioutil.TempDir(os.TempDir(), prefix)
 	if err != nil {
@@ -136,6 +181,7 @@ func TestOSDAddonRelease(t *testing.T) {
 		olmType                     string
 		channel                     string
 		shouldHaveUseClusterStorage bool
+		existingMergeRequest        bool
 		expectError                 bool
 	}{
 		{version: "1.1.0", olmType: "managed-api-service", channel: "stable", expectError: false},
@@ -149,17 +195,28 @@ func TestOSDAddonRelease(t *testing.T) {
 		{version: "1.1.0", olmType: "integreatly-operator", channel: "stable", expectError: false},
 		{version: "1.1.0", olmType: "integreatly-operator", channel: "stage", expectError: false},
 		{version: "1.1.0", olmType: "integreatly-operator", channel: "some", expectError: true},
+		{version: "1.1.0-rc1", olmType: "managed-api-service", channel: "stage", existingMergeRequest: true, expectError: false},
+		{version: "1.1.0", olmType: "integreatly-operator", channel: "stable", existingMergeRequest: true, expectError: false},
 	}
 
 	for _, c := range cases {
-		t.Run(fmt.Sprintf("test create merge request for version %s and channel %s", c.version, c.channel), func(t *testing.T) {
+		t.Run(fmt.Sprintf("test create merge request for version %s and channel %s (existing: %t)", c.version, c.channel, c.existingMergeRequest), func(t *testing.T) {
 
 			managedTenantsRepoPushed := false
 			managedTenantsMergeRequestCreated := false
+			managedTenantsMergeRequestUpdated := false
+			managedTenantsMergeRequestCommented := false
 
 			var managedTenantsPatch *object.Patch
 
-			flags := &osdAddonReleaseFlags{version: c.version, channel: c.channel, addonName: c.olmType}
+			flags := &osdAddonReleaseFlags{
+				version:              c.version,
+				channel:              c.channel,
+				addonName:            c.olmType,
+				mergeRequestComment:  "updated",
+				managedTenantsOrigin: "service/managed-tenants",
+				managedTenantsFork:   "integreatly-qe/managed-tenants",
+			}
 
 			// Prepare the version
 			version, err := utils.NewVersion(flags.version, c.olmType)
@@ -177,6 +234,9 @@ func TestOSDAddonRelease(t *testing.T) {
 
 			// Mock the push service
 			mockPushService := &mockGitPushService{pushFunc: func(gitRepo *git.Repository, opts *git.PushOptions) error {
+				if !opts.Force {
+					t.Fatal("expected the managed-tenants branch to be force pushed")
+				}
 				// Save the last commit diff before HEAD get reset to master
 				managedTenantsPatch = gitDiff(t, managedTenantsRepo, managedTenantsMainBranch, "HEAD")
 
@@ -187,11 +247,12 @@ func TestOSDAddonRelease(t *testing.T) {
 			// Mock the gitlab api
 			gitlabProjectsMock := &gitlabProjectsMock{
 				getProject: func(
-					_ interface{},
+					pid interface{},
 					_ *gitlab.GetProjectOptions,
 					_ ...gitlab.RequestOptionFunc,
 				) (*gitlab.Project, *gitlab.Response, error) {
-					return &gitlab.Project{}, &gitlab.Response{}, nil
+					projects := map[interface{}]int{flags.managedTenantsOrigin: 1, flags.managedTenantsFork: 2}
+					return &gitlab.Project{ID: projects[pid]}, &gitlab.Response{}, nil
 				},
 			}
 			gitlabMergeRequestMock := &gitlabMergeRequestMock{
@@ -203,6 +264,55 @@ func TestOSDAddonRelease(t *testing.T) {
 					managedTenantsMergeRequestCreated = true
 					return &gitlab.MergeRequest{}, &gitlab.Response{}, nil
 				},
+				listProjectMergeRequests: func(
+					_ interface{},
+					opt *gitlab.ListProjectMergeRequestsOptions,
+					_ ...gitlab.RequestOptionFunc,
+				) ([]*gitlab.MergeRequest, *gitlab.Response, error) {
+					// an open merge request with the same branch from another fork is always ignored
+					mrs := []*gitlab.MergeRequest{{IID: 41, SourceBranch: *opt.SourceBranch, SourceProjectID: 3}}
+					if c.existingMergeRequest {
+						mrs = append(mrs, &gitlab.MergeRequest{IID: 42, SourceBranch: *opt.SourceBranch, SourceProjectID: 2})
+					}
+					return mrs, &gitlab.Response{}, nil
+				},
+				updateMergeRequest: func(
+					_ interface{},
+					mergeRequest int,
+					_ *gitlab.UpdateMergeRequestOptions,
+					_ ...gitlab.RequestOptionFunc,
+				) (*gitlab.MergeRequest, *gitlab.Response, error) {
+					managedTenantsMergeRequestUpdated = true
+					return &gitlab.MergeRequest{IID: mergeRequest}, &gitlab.Response{}, nil
+				},
+				getMergeRequestApprovals: func(
+					_ interface{},
+					_ int,
+					_ ...gitlab.RequestOptionFunc,
+				) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+					return &gitlab.MergeRequestApprovals{}, &gitlab.Response{}, nil
+				},
+				listMergeRequestPipelines: func(
+					_ interface{},
+					_ int,
+					_ ...gitlab.RequestOptionFunc,
+				) ([]*gitlab.PipelineInfo, *gitlab.Response, error) {
+					return []*gitlab.PipelineInfo{{ID: 1, Status: "running"}}, &gitlab.Response{}, nil
+				},
+			}
+			gitlabNotesMock := &gitlabNotesMock{
+				createMergeRequestNote: func(
+					_ interface{},
+					mergeRequest int,
+					_ *gitlab.CreateMergeRequestNoteOptions,
+					_ ...gitlab.RequestOptionFunc,
+				) (*gitlab.Note, *gitlab.Response, error) {
+					if mergeRequest != 42 {
+						t.Fatalf("expected comment on MR 42 but found %d", mergeRequest)
+					}
+					managedTenantsMergeRequestCommented = true
+					return &gitlab.Note{}, &gitlab.Response{}, nil
+				},
 			}
 
 			addonsConfig := &addons{}
@@ -227,6 +337,7 @@ func TestOSDAddonRelease(t *testing.T) {
 				currentChannel:      currentChannel,
 				gitlabMergeRequests: gitlabMergeRequestMock,
 				gitlabProjects:      gitlabProjectsMock,
+				gitlabNotes:         gitlabNotesMock,
 				addonDir:            integreatlyOperatorDir,
 				managedTenantsDir:   managedTenantsDir,
 				managedTenantsRepo:  managedTenantsRepo,
@@ -255,8 +366,19 @@ func TestOSDAddonRelease(t *testing.T) {
 				t.Fatal("the managed-tenants repo hasn't been pushed")
 			}
 
-			// Verify the gitlab create merge request endpoint has been call
-			if !managedTenantsMergeRequestCreated {
+			if c.existingMergeRequest {
+				// Verify the existing merge request has been updated instead of creating a new one
+				if managedTenantsMergeRequestCreated {
+					t.Fatal("a new managed-tenants merge request has been created while one was already open")
+				}
+				if !managedTenantsMergeRequestUpdated {
+					t.Fatal("the existing managed-tenants merge request hasn't been updated")
+				}
+				if !managedTenantsMergeRequestCommented {
+					t.Fatal("the existing managed-tenants merge request hasn't been commented")
+				}
+			} else if !managedTenantsMergeRequestCreated {
+				// Verify the gitlab create merge request endpoint has been call
 				t.Fatal("the managed-tenants repo hasn't been created")
 			}
 
@@ -638,3 +760,348 @@ func Test_osdAddonReleaseCmd_getAddonImageSetName(t *testing.T) {
 		})
 	}
 }
+
+func Test_osdAddonReleaseFlags_validate(t *testing.T) {
+	cases := []struct {
+		flags       osdAddonReleaseFlags
+		expectError bool
+	}{
+		{flags: osdAddonReleaseFlags{}},
+		{flags: osdAddonReleaseFlags{wait: true}},
+		{flags: osdAddonReleaseFlags{wait: true, checkAddonVersion: true}},
+		{flags: osdAddonReleaseFlags{checkAddonVersion: true}, expectError: true},
+	}
+	for _, c := range cases {
+		if err := c.flags.validate(); (err != nil) != c.expectError {
+			t.Errorf("unexpected validation result for wait=%t and check-addon-version=%t: %v", c.flags.wait, c.flags.checkAddonVersion, err)
+		}
+	}
+}
+
+func Test_osdAddonReleaseCmd_waitForMergeRequest(t *testing.T) {
+	version, err := utils.NewVersion("1.27.0", types.OlmTypeRhoam)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approvals := func(_ interface{}, _ int, _ ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+		return &gitlab.MergeRequestApprovals{ApprovalsLeft: 0}, &gitlab.Response{}, nil
+	}
+	pipelines := func(status string) func(_ interface{}, _ int, _ ...gitlab.RequestOptionFunc) ([]*gitlab.PipelineInfo, *gitlab.Response, error) {
+		return func(_ interface{}, _ int, _ ...gitlab.RequestOptionFunc) ([]*gitlab.PipelineInfo, *gitlab.Response, error) {
+			return []*gitlab.PipelineInfo{{ID: 2, Status: status}, {ID: 1, Status: "failed"}}, &gitlab.Response{}, nil
+		}
+	}
+	mergeRequest := func(state string) func(_ interface{}, mr int, _ *gitlab.GetMergeRequestsOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+		return func(_ interface{}, mr int, _ *gitlab.GetMergeRequestsOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+			return &gitlab.MergeRequest{IID: mr, State: state}, &gitlab.Response{}, nil
+		}
+	}
+	addon := func(version string) *ocmAddonsMock {
+		return &ocmAddonsMock{get: func(_ context.Context, addonID string) (*ocm.Addon, error) {
+			return &ocm.Addon{ID: addonID, Version: &ocm.AddonVersion{ID: version}}, nil
+		}}
+	}
+
+	tests := []struct {
+		name                string
+		gitlabMergeRequests *gitlabMergeRequestMock
+		ocmAddons           ocm.AddonsManager
+		wantAddonLive       bool
+		wantErr             bool
+	}{
+		{
+			name: "test merged MR",
+			gitlabMergeRequests: &gitlabMergeRequestMock{
+				getMergeRequest:           mergeRequest("merged"),
+				listMergeRequestPipelines: pipelines("success"),
+				getMergeRequestApprovals:  approvals,
+			},
+		},
+		{
+			name: "test merged MR with addon version live in OCM",
+			gitlabMergeRequests: &gitlabMergeRequestMock{
+				getMergeRequest:           mergeRequest("merged"),
+				listMergeRequestPipelines: pipelines("success"),
+				getMergeRequestApprovals:  approvals,
+			},
+			ocmAddons:     addon("1.27.0"),
+			wantAddonLive: true,
+		},
+		{
+			name: "test error when the OCM request fails",
+			gitlabMergeRequests: &gitlabMergeRequestMock{
+				getMergeRequest:           mergeRequest("merged"),
+				listMergeRequestPipelines: pipelines("success"),
+				getMergeRequestApprovals:  approvals,
+			},
+			ocmAddons: &ocmAddonsMock{get: func(_ context.Context, _ string) (*ocm.Addon, error) {
+				return nil, fmt.Errorf("not found")
+			}},
+			wantErr: true,
+		},
+		{
+			name: "test error when the MR is closed",
+			gitlabMergeRequests: &gitlabMergeRequestMock{
+				getMergeRequest:           mergeRequest("closed"),
+				listMergeRequestPipelines: pipelines("success"),
+				getMergeRequestApprovals:  approvals,
+			},
+			wantErr: true,
+		},
+		{
+			name: "test error when the latest MR pipeline failed",
+			gitlabMergeRequests: &gitlabMergeRequestMock{
+				getMergeRequest:           mergeRequest("opened"),
+				listMergeRequestPipelines: pipelines("failed"),
+				getMergeRequestApprovals:  approvals,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaryFile := path.Join(t.TempDir(), "summary.json")
+			c := &osdAddonReleaseCmd{
+				flags:               &osdAddonReleaseFlags{wait: true, waitInterval: 1, waitMax: 1, summaryFile: summaryFile},
+				version:             version,
+				gitlabMergeRequests: tt.gitlabMergeRequests,
+				ocmAddons:           tt.ocmAddons,
+				addonConfig:         &addonConfig{Name: types.OlmTypeRhoam},
+				currentChannel:      &releaseChannel{Name: "stage", OCMAddonID: "managed-api-service"},
+			}
+			err := c.waitForMergeRequest(1, &gitlab.MergeRequest{IID: 42, WebURL: "https://gitlab/mr/42"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitForMergeRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			b, err := ioutil.ReadFile(summaryFile)
+			if err != nil {
+				t.Fatalf("failed to read the summary file: %v", err)
+			}
+			summary := &osdAddonReleaseSummary{}
+			if err := json.Unmarshal(b, summary); err != nil {
+				t.Fatalf("invalid summary file: %v", err)
+			}
+			if summary.MergeRequest != "https://gitlab/mr/42" {
+				t.Errorf("expected merge request https://gitlab/mr/42 in the summary but found %s", summary.MergeRequest)
+			}
+			if summary.AddonLive != tt.wantAddonLive {
+				t.Errorf("expected addon live to be %t in the summary but found %t", tt.wantAddonLive, summary.AddonLive)
+			}
+			if (summary.Error != "") != tt.wantErr {
+				t.Errorf("expected error in the summary to be set %t but found %q", tt.wantErr, summary.Error)
+			}
+		})
+	}
+}
+
+func Test_csvTransforms_apply(t *testing.T) {
+	newCSV := func() *olmapiv1alpha1.ClusterServiceVersion {
+		csv := &olmapiv1alpha1.ClusterServiceVersion{}
+		csv.Name = "managed-api-service.v1.1.0"
+		csv.Spec.Replaces = "managed-api-service.v1.0.1"
+		csv.Spec.InstallModes = []olmapiv1alpha1.InstallMode{
+			{Type: olmapiv1alpha1.InstallModeTypeOwnNamespace, Supported: true},
+			{Type: olmapiv1alpha1.InstallModeTypeSingleNamespace, Supported: false},
+		}
+		return csv
+	}
+
+	tests := []struct {
+		name            string
+		transforms      *csvTransforms
+		wantErr         bool
+		wantName        string
+		wantReplaces    string
+		wantInstallMode map[olmapiv1alpha1.InstallModeType]bool
+		wantAnnotations map[string]string
+		verify          func(t *testing.T, csv *olmapiv1alpha1.ClusterServiceVersion)
+	}{
+		{
+			name: "test name suffix and bundle annotations",
+			transforms: &csvTransforms{
+				NameSuffix:        "-internal",
+				BundleAnnotations: map[string]string{"operators.operatorframework.io.bundle.channels.v1": "edge"},
+			},
+			wantName:        "managed-api-service-internal.v1.1.0",
+			wantReplaces:    "managed-api-service-internal.v1.0.1",
+			wantAnnotations: map[string]string{"operators.operatorframework.io.bundle.channels.v1": "edge"},
+		},
+		{
+			name: "test install modes",
+			transforms: &csvTransforms{
+				InstallModes: map[olmapiv1alpha1.InstallModeType]bool{
+					olmapiv1alpha1.InstallModeTypeSingleNamespace: true,
+					olmapiv1alpha1.InstallModeTypeAllNamespaces:   false,
+				},
+			},
+			wantName:     "managed-api-service.v1.1.0",
+			wantReplaces: "managed-api-service.v1.0.1",
+			wantInstallMode: map[olmapiv1alpha1.InstallModeType]bool{
+				olmapiv1alpha1.InstallModeTypeOwnNamespace:    true,
+				olmapiv1alpha1.InstallModeTypeSingleNamespace: true,
+				olmapiv1alpha1.InstallModeTypeAllNamespaces:   false,
+			},
+		},
+		{
+			name: "test csv annotations and json patch",
+			transforms: &csvTransforms{
+				CSVAnnotations: map[string]string{"olm.skipRange": ">=1.0.0 <1.1.0"},
+				JSONPatch: []jsonPatchOperation{
+					{Op: "replace", Path: "/spec/displayName", Value: json.RawMessage(`"RHOAM"`)},
+					{Op: "remove", Path: "/spec/replaces"},
+				},
+			},
+			wantName: "managed-api-service.v1.1.0",
+			verify: func(t *testing.T, csv *olmapiv1alpha1.ClusterServiceVersion) {
+				if csv.Annotations["olm.skipRange"] != ">=1.0.0 <1.1.0" {
+					t.Errorf("expected olm.skipRange annotation but found %v", csv.Annotations)
+				}
+				if csv.Spec.DisplayName != "RHOAM" {
+					t.Errorf("expected displayName RHOAM but found %s", csv.Spec.DisplayName)
+				}
+			},
+		},
+		{
+			name: "test invalid json patch",
+			transforms: &csvTransforms{
+				JSONPatch: []jsonPatchOperation{{Op: "remove", Path: "/spec/notExisting"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := &metadataAnnotations{}
+			got, err := tt.transforms.apply(newCSV(), annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Name != tt.wantName {
+				t.Errorf("expected csv name %s but found %s", tt.wantName, got.Name)
+			}
+			if got.Spec.Replaces != tt.wantReplaces {
+				t.Errorf("expected csv replaces %s but found %s", tt.wantReplaces, got.Spec.Replaces)
+			}
+			for mode, supported := range tt.wantInstallMode {
+				_, m := utils.FindInstallMode(got.Spec.InstallModes, mode)
+				if m == nil || m.Supported != supported {
+					t.Errorf("expected install mode %s to be supported %t but found %v", mode, supported, m)
+				}
+			}
+			for k, v := range tt.wantAnnotations {
+				if annotations.Annotations[k] != v {
+					t.Errorf("expected bundle annotation %s=%s but found %v", k, v, annotations.Annotations)
+				}
+			}
+			if tt.verify != nil {
+				tt.verify(t, got)
+			}
+		})
+	}
+}
+
+func TestOSDAddonReleaseWithFakeSCM(t *testing.T) {
+	basedir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := fake.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	origin := "service/managed-tenants-bundles"
+	fork := "integreatly-qe/managed-tenants-bundles"
+	operator := "integr8ly/integreatly-operator"
+	if err := server.CreateRepo(origin, "main", path.Join(basedir, "testdata/osdAddonReleaseManagedTenantsBundles")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ForkRepo(origin, fork); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CreateRepo(operator, "master", path.Join(basedir, "testdata/osdAddonReleaseIntegreatlyOperator1.1.0-rc1")); err != nil {
+		t.Fatal(err)
+	}
+	// The tag is resolved using the global olmType flag
+	version, err := utils.NewVersion("1.1.0-rc1", olmType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CreateTag(operator, "master", version.TagName()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Point the addon bundle to the fake operator repo
+	addonsConfig := &addons{}
+	if err := utils.PopulateObjectFromYAML("../configurations/managed-tenants-addons-config-rhoam.yaml", addonsConfig); err != nil {
+		t.Fatal(err)
+	}
+	addonsConfig.Addons[0].Bundle.Repo = server.RepoURL(operator)
+	addonsConfigFile := path.Join(t.TempDir(), "addons-config.yaml")
+	if err := utils.WriteObjectToYAML(addonsConfig, addonsConfigFile); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultGitlabURL := gitlabURL
+	gitlabURL = server.URL
+	defer func() { gitlabURL = defaultGitlabURL }()
+
+	flags := &osdAddonReleaseFlags{
+		version:                 "1.1.0-rc1",
+		channel:                 "stage",
+		addonName:               types.OlmTypeRhoam,
+		addonsConfig:            addonsConfigFile,
+		managedTenantsOrigin:    origin,
+		managedTenantsFork:      fork,
+		mergeRequestDescription: "first",
+	}
+
+	// Run twice to verify that the second run updates the existing MR
+	for _, description := range []string{"first", "second"} {
+		flags.mergeRequestDescription = description
+		flags.mergeRequestComment = description
+		c, err := newOSDAddonReleaseCmd(flags, "token", "")
+		if err != nil {
+			t.Fatalf("failed to prepare the osd-addon command: %v", err)
+		}
+		if err := c.run(); err != nil {
+			t.Fatalf("osd-addon failed for the %s run: %v", description, err)
+		}
+	}
+
+	mrs := server.MergeRequests(origin)
+	if len(mrs) != 1 {
+		t.Fatalf("expected 1 MR in %s but found %d", origin, len(mrs))
+	}
+	branch := fmt.Sprintf(branchNameTemplate, types.OlmTypeRhoam, "stage", "1.1.0-rc1")
+	if mrs[0].SourceBranch != branch || mrs[0].Description != "second" {
+		t.Fatalf("unexpected MR: %+v", mrs[0])
+	}
+	if notes := server.MergeRequestNotes(origin, mrs[0].IID); len(notes) != 1 || notes[0].Body != "second" {
+		t.Fatalf("expected the second run to comment the MR but found %v", notes)
+	}
+
+	forkRepo, err := server.Repo(fork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := forkRepo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		t.Fatalf("branch %s not pushed to the fork: %v", branch, err)
+	}
+	commit, err := forkRepo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvFile := fmt.Sprintf("addons/rhoams/main/1.1.0/manifests/%s.clusterserviceversion.yaml", types.OlmTypeRhoam)
+	if _, err := commit.File(csvFile); err != nil {
+		t.Fatalf("expected %s in the pushed commit: %v", csvFile, err)
+	}
+}
@@ -2,11 +2,9 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -18,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/integr8ly/delorean/pkg/polarion"
+	"github.com/integr8ly/delorean/pkg/s3processor"
 	"github.com/integr8ly/delorean/pkg/utils"
 	"github.com/jstemmer/go-junit-report/formatter"
 	"github.com/spf13/cobra"
@@ -38,6 +37,7 @@ var errJunitNotFound = errors.New("JUnit file not found. Marking the archive as
 type polarionImportCmdFlags struct {
 	bucket string
 	stage  bool
+	limits s3ImportFlags
 }
 
 type polarionImportCmd struct {
@@ -46,6 +46,8 @@ type polarionImportCmd struct {
 	s3downloader     s3manageriface.DownloaderAPI
 	polarionURL      string
 	polarionImporter polarion.XUnitImporterService
+	maxObjects       int
+	since            time.Time
 }
 
 func init() {
@@ -70,9 +72,14 @@ func init() {
 	cmd.MarkFlagRequired("bucket")
 
 	cmd.Flags().BoolVar(&f.stage, "stage", false, "Create the release in the Polarion staging environment")
+	f.limits.addFlags(cmd)
 }
 
 func newPolarionImportCmd(f *polarionImportCmdFlags) (*polarionImportCmd, error) {
+	since, err := f.limits.sinceTime(time.Now())
+	if err != nil {
+		return nil, err
+	}
 
 	awsAccessKeyID, err := requireValue(AWSAccessKeyIDEnv)
 	if err != nil {
@@ -116,94 +123,47 @@ func newPolarionImportCmd(f *polarionImportCmdFlags) (*polarionImportCmd, error)
 		s3downloader:     s3Downloader,
 		polarionURL:      url,
 		polarionImporter: importer,
+		maxObjects:       f.limits.maxObjects,
+		since:            since,
 	}, nil
 }
 
 func (c *polarionImportCmd) run(ctx context.Context) error {
-	o, err := c.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: &c.fromBucket, Delimiter: aws.String("/")})
+	p := s3processor.NewProcessor(c.s3, c.s3downloader, s3processor.Options{
+		Bucket:       c.fromBucket,
+		Filter:       isZipFile,
+		ProcessedTag: &s3processor.Tag{Key: polarionTagKey, Value: polarionTagVal},
+		Download:     true,
+		Workers:      defaultImportWorkers,
+		MaxObjects:   c.maxObjects,
+		Since:        c.since,
+	})
+	results, err := p.Run(ctx, s3processor.SinkFunc(c.processReportFile))
 	if err != nil {
 		return err
 	}
-
-	tasks := make([]utils.Task, len(o.Contents))
-	for i, obj := range o.Contents {
-		f := obj
-		t := func() (utils.TaskResult, error) {
-			return c.processReportFile(ctx, f)
-		}
-		tasks[i] = t
-	}
-
-	if _, err := utils.ParallelLimit(ctx, tasks, defaultImportWorkers); err != nil {
-		return err
-	}
-
-	return nil
+	return results.Err()
 }
 
-func (c *polarionImportCmd) processReportFile(ctx context.Context, object *s3.Object) (interface{}, error) {
-	tags, err := c.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
-		Bucket: &c.fromBucket,
-		Key:    object.Key,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if hasTag(tags.TagSet, polarionTagKey, polarionTagVal) {
-		fmt.Println(fmt.Sprintf("[%s] file in bucket %s has been processed already. Ignored.", *object.Key, c.fromBucket))
-		return &reportProcessResult{}, nil
-	}
-
-	if !strings.HasSuffix(*object.Key, ".zip") {
-		fmt.Println(fmt.Sprintf("[%s] file in bucket %s is ignored as it is not a zip file", *object.Key, c.fromBucket))
-		return &reportProcessResult{}, nil
-	}
-
-	// download object to a tmp dir
-	fmt.Println(fmt.Sprintf("[%s] downloading file from s3 bucket %s", *object.Key, c.fromBucket))
-	downloaded, err := utils.DownloadS3ObjectToTempDir(ctx, c.s3downloader, c.fromBucket, *object.Key)
+func (c *polarionImportCmd) processReportFile(ctx context.Context, object *s3processor.Object) error {
+	m, err := readTestMetadata(object.File)
 	if err != nil {
-		return nil, err
-	}
-	defer os.Remove(downloaded)
-
-	// get the metadata.json file
-	b, err := utils.ReadFileFromZip(downloaded, metadataFileName)
-	if err != nil {
-		return nil, err
-	}
-	m := &testMetadata{}
-	if err := json.Unmarshal(b, m); err != nil {
-		return nil, err
+		return err
 	}
 
 	// upload it to Polarion
-	fmt.Println(fmt.Sprintf("[%s] uploading results to Polarion", *object.Key))
-	err = c.importToPolarion(*object.Key, m, downloaded)
+	fmt.Println(fmt.Sprintf("[%s] uploading results to Polarion", object.Key))
+	err = c.importToPolarion(object.Key, m, object.File)
 
 	if err != nil {
 		// If JUnit file is not found, ignore the error and mark the archive as processed
 		if err == errJunitNotFound {
-			fmt.Printf("[%s] %s", *object.Key, errJunitNotFound)
+			fmt.Printf("[%s] %s", object.Key, errJunitNotFound)
 		} else {
-			return nil, err
+			return err
 		}
 	}
-
-	// update the tags on the obj
-	t := append(tags.TagSet, &s3.Tag{
-		Key:   aws.String(polarionTagKey),
-		Value: aws.String(polarionTagVal),
-	})
-	if _, err = c.s3.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
-		Bucket:  aws.String(c.fromBucket),
-		Key:     object.Key,
-		Tagging: &s3.Tagging{TagSet: t},
-	}); err != nil {
-		return nil, err
-	}
-
-	return &struct{}{}, nil
+	return nil
 }
 
 func (c *polarionImportCmd) importToPolarion(key string, metadata *testMetadata, zipfile string) error {
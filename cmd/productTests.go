@@ -4,19 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/integr8ly/delorean/pkg/utils"
 	"github.com/spf13/cobra"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/utils/pointer"
@@ -28,8 +31,21 @@ const (
 	testJobBackoffLimit = 0
 	defaultNamespace    = "rhmi-product-tests"
 	serviceAccountName  = "cluster-admin-sa"
+	defaultClusterRole  = "cluster-admin"
+	testContainerName   = "test"
+	sidecarName         = "sidecar"
+	testResultsVolume   = "test-run-results"
+	boundSATokenVolume  = "bound-sa-token"
+	defaultRetryBackoff = 30 * time.Second
+	// time to wait for the end of the logs stream once the test container is terminated
+	logsStreamTimeout = 30 * time.Second
+	followAllTests    = "all"
+	// max time to save the partial results and delete the resources when the run is cancelled
+	abortCleanupTimeout = 2 * time.Minute
 )
 
+var errTestContainerSkipped = errors.New("test container skipped")
+
 type TestContainer struct {
 	Name            string      `json:"name"`
 	Image           string      `json:"image"`
@@ -37,13 +53,52 @@ type TestContainer struct {
 	ImagePullSecret string      `json:"ImagePullSecretEnvVar,omitempty"`
 	EnvVars         []v1.EnvVar `json:"envVars,omitempty"`
 	RegExpFilter    string      `json:"regExpFilter,omitempty"`
-	Entrypoint      []string    `json:"entrypoint, omitempty"`
-	Argument        []string    `json:"argument, omitempty"`
-	Success         bool
+	Entrypoint      []string    `json:"entrypoint,omitempty"`
+	Argument        []string    `json:"argument,omitempty"`
+	// Labels used to select the tests with --include and --exclude
+	Labels map[string]string `json:"labels,omitempty"`
+	// Names of the tests that must pass before this test is started
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Number of times the test is retried if it fails
+	Retries int `json:"retries,omitempty"`
+	// Seconds to wait before the first retry, doubled for each following retry
+	RetryBackoff int64 `json:"retryBackoff,omitempty"`
+	// Don't fail the run if this test fails
+	AllowFailure bool `json:"allowFailure,omitempty"`
+	// Compute resources of the test container
+	Resources       v1.ResourceRequirements `json:"resources,omitempty"`
+	SecurityContext *v1.SecurityContext     `json:"securityContext,omitempty"`
+	NodeSelector    map[string]string       `json:"nodeSelector,omitempty"`
+	Tolerations     []v1.Toleration         `json:"tolerations,omitempty"`
+	// Service account of the test, created if it doesn't exist. Defaults to cluster-admin-sa, or to
+	// <name>-sa when a cluster role is set
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Cluster role bound to the service account of the test. Defaults to cluster-admin when no
+	// service account is set, otherwise the service account is used as it is
+	ClusterRole string `json:"clusterRole,omitempty"`
+	// ConfigMaps and Secrets mounted in the test container
+	Volumes []TestVolume `json:"volumes,omitempty"`
+	// Extra containers running next to the test container
+	Sidecars []v1.Container `json:"sidecars,omitempty"`
+	Success  bool
+	Skipped  bool          `json:"-"`
+	Attempts int           `json:"-"`
+	ExitCode *int32        `json:"-"`
+	Duration time.Duration `json:"-"`
+}
+
+// TestVolume is a ConfigMap or a Secret mounted in the test container
+type TestVolume struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ConfigMap string `json:"configMap,omitempty"`
+	Secret    string `json:"secret,omitempty"`
 }
 
 type testContainerList struct {
-	Tests []*TestContainer `json:"tests"`
+	// Max number of tests running at the same time, no limit if 0
+	MaxParallel int              `json:"maxParallel,omitempty"`
+	Tests       []*TestContainer `json:"tests"`
 }
 
 type runTestsCmdFlags struct {
@@ -52,15 +107,35 @@ type runTestsCmdFlags struct {
 	namespace       string
 	cleanup         bool
 	testsConfigFile string
+	maxParallel     int
+	follow          []string
+	include         []string
+	exclude         []string
+	shard           string
+	list            bool
+	pushgateway     string
+	pushJobName     string
+	version         string
+	clusterID       string
 }
 
 type runTestsCmd struct {
-	clientset kubernetes.Interface
-	tests     []*TestContainer
-	outputDir string
-	namespace string
-	cleanup   bool
-	oc        utils.OCInterface
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	tests         []*TestContainer
+	outputDir     string
+	namespace     string
+	cleanup       bool
+	executor      utils.PodExecutor
+	maxParallel   int
+	retryBackoff  time.Duration
+	follow        []string
+	// interval between the attempts to open the logs stream while the test container is starting
+	logsInterval time.Duration
+	pushgateway  string
+	pushJobName  string
+	version      string
+	clusterID    string
 }
 
 func init() {
@@ -69,11 +144,22 @@ func init() {
 		Use:   "product-tests",
 		Short: "Execute RHMI product test containers",
 		Run: func(cmd *cobra.Command, args []string) {
+			testList, err := loadTestList(f)
+			if err != nil {
+				handleError(err)
+			}
+			if f.list {
+				printTestPlan(os.Stdout, testList.Tests, testList.MaxParallel)
+				return
+			}
+			if f.outputDir == "" {
+				handleError(errors.New("required flag \"output\" not set"))
+			}
 			kubeConfig, err := requireValue(KubeConfigKey)
 			if err != nil {
 				handleError(err)
 			}
-			c, err := newRunTestsCmd(kubeConfig, f)
+			c, err := newRunTestsCmd(kubeConfig, f, testList)
 			if err != nil {
 				handleError(err)
 			}
@@ -84,15 +170,41 @@ func init() {
 	}
 
 	pipelineCmd.AddCommand(cmd)
-	cmd.Flags().StringVarP(&f.outputDir, "output", "o", "", "Absolute path of the output directory to save reports")
-	cmd.MarkFlagRequired("output")
+	cmd.Flags().StringVarP(&f.outputDir, "output", "o", "", "Absolute path of the output directory to save reports. Required unless --list is set")
 	cmd.Flags().StringVarP(&f.namespace, "namespace", "n", defaultNamespace, "The namespace to run the test containers")
 	cmd.Flags().BoolVar(&f.cleanup, "post-cleanup", false, "Delete the namespace after test containers finish")
 	cmd.Flags().StringVar(&f.testsConfigFile, "test-config", "", "Path to the tests configuration file")
 	cmd.MarkFlagRequired("test-config")
+	cmd.Flags().IntVar(&f.maxParallel, "max-parallel", 0, "Max number of test containers running at the same time. Overrides the maxParallel value of the tests configuration")
+	cmd.Flags().StringSliceVar(&f.include, "include", []string{}, "Run only the tests with a name matching one of these regular expressions, or with one of these labels in the format key=value, and the tests they depend on")
+	cmd.Flags().StringSliceVar(&f.exclude, "exclude", []string{}, "Don't run the tests with a name matching one of these regular expressions, or with one of these labels in the format key=value")
+	cmd.Flags().StringVar(&f.shard, "shard", "", "Run only the shard of the selected tests in the format index/total, ex. 1/3 for the first third. The tests depending on each other are in the same shard")
+	cmd.Flags().BoolVar(&f.list, "list", false, "Print the tests that would be run without running them")
+	cmd.Flags().StringVar(&f.pushgateway, "pushgateway", "", "URL of the Prometheus Pushgateway to push the metrics of the tests to. The metrics are not pushed if empty")
+	cmd.Flags().StringVar(&f.pushJobName, "push-job", productTestsJobName, "The job name of the metrics pushed to the Pushgateway")
+	cmd.Flags().StringVarP(&f.version, "version", "v", "", "the RHMI version installed on the cluster")
+	cmd.Flags().StringVar(&f.clusterID, "cluster-id", "", "ID of the cluster, added to the metrics. Defaults to the id of the ClusterVersion")
+	cmd.Flags().StringSliceVar(&f.follow, "follow", []string{}, fmt.Sprintf("Names of the tests to print the live logs of on the console, or %s for all the tests", followAllTests))
+}
+
+// loadTestList reads the tests configuration and keeps the tests selected by the flags
+func loadTestList(f *runTestsCmdFlags) (*testContainerList, error) {
+	testList := &testContainerList{}
+	if err := utils.PopulateObjectFromYAML(f.testsConfigFile, testList); err != nil {
+		return nil, err
+	}
+	tests, err := selectTests(testList.Tests, f.include, f.exclude, f.shard)
+	if err != nil {
+		return nil, err
+	}
+	testList.Tests = tests
+	if f.maxParallel > 0 {
+		testList.MaxParallel = f.maxParallel
+	}
+	return testList, nil
 }
 
-func newRunTestsCmd(kubeconfig string, f *runTestsCmdFlags) (*runTestsCmd, error) {
+func newRunTestsCmd(kubeconfig string, f *runTestsCmdFlags, testList *testContainerList) (*runTestsCmd, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
 		return nil, err
@@ -101,50 +213,69 @@ func newRunTestsCmd(kubeconfig string, f *runTestsCmdFlags) (*runTestsCmd, error
 	if err != nil {
 		return nil, err
 	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
 	dt := time.Now()
 	outputDir := path.Join(f.outputDir, dt.Format("2006-01-02-03-04-05"))
 	if err = os.MkdirAll(outputDir, os.ModePerm); err != nil {
 		return nil, err
 	}
-	testList := &testContainerList{}
-	if err := utils.PopulateObjectFromYAML(f.testsConfigFile, testList); err != nil {
-		return nil, err
-	}
 
 	return &runTestsCmd{
-		clientset: clientset,
-		tests:     testList.Tests,
-		outputDir: outputDir,
-		namespace: f.namespace,
-		cleanup:   f.cleanup,
-		oc:        utils.NewOC(kubeconfig),
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		tests:         testList.Tests,
+		outputDir:     outputDir,
+		namespace:     f.namespace,
+		cleanup:       f.cleanup,
+		executor:      utils.NewSPDYPodExecutor(config, clientset),
+		maxParallel:   testList.MaxParallel,
+		retryBackoff:  defaultRetryBackoff,
+		follow:        f.follow,
+		logsInterval:  time.Second,
+		pushgateway:   f.pushgateway,
+		pushJobName:   f.pushJobName,
+		version:       f.version,
+		clusterID:     f.clusterID,
 	}, nil
 }
 
 func (c *runTestsCmd) run(ctx context.Context) error {
+	var tasks []utils.DAGTask
+	for _, testContainer := range c.tests {
+		t := testContainer
+		if err := validateTestContainer(t); err != nil {
+			return err
+		}
+		tasks = append(tasks, utils.DAGTask{
+			Name:      t.Name,
+			DependsOn: t.DependsOn,
+			Run: func(ctx context.Context) error {
+				return c.runTestContainerWithRetries(ctx, t)
+			},
+		})
+	}
+	if err := utils.ValidateDAG(tasks); err != nil {
+		return fmt.Errorf("invalid test dependencies: %w", err)
+	}
+
 	var ns *v1.Namespace
-	var sa *v1.ServiceAccount
 	var err error
 	fmt.Println("[Prepare] Create namespace", c.namespace)
 	if ns, err = utils.CreateNamespace(c.clientset, c.namespace); err != nil {
 		return err
 	}
-	fmt.Println("[Prepare] Create serviceAccount", serviceAccountName)
-	if sa, err = utils.CreateServiceAccount(c.clientset, c.namespace, serviceAccountName); err != nil {
+	if err = c.createServiceAccounts(ns); err != nil {
 		return err
 	}
-	fmt.Println("[Prepare] Create ClusterRoleBinding for the service account")
-	gvk := schema.FromAPIVersionAndKind("v1", "namespace")
-	owner := metav1.NewControllerRef(ns, gvk)
-	if _, err = utils.CreateClusterRoleBinding(c.clientset, sa, "cluster-admin", *owner); err != nil {
-		return err
-	}
-	var wg sync.WaitGroup
 	for _, testContainer := range c.tests {
 		if testContainer.ImagePullSecret != "" {
 			if os.Getenv(testContainer.ImagePullSecret) == "" {
 				fmt.Println(fmt.Sprintf("[%s] ImagePullSecret %s defined in configuration but no value found", testContainer.Name, testContainer.ImagePullSecret))
+				testContainer.Skipped = true
 				continue
 			}
 			fmt.Println(fmt.Sprintf("[%s] Creating secret %s", testContainer.Name, testContainer.ImagePullSecret))
@@ -153,25 +284,52 @@ func (c *runTestsCmd) run(ctx context.Context) error {
 				return err
 			}
 		}
-		wg.Add(1)
-		go func(t *TestContainer) {
-			defer wg.Done()
-			fmt.Println(fmt.Sprintf("[%s] Start test container", t.Name))
-			ok, err := c.runTestContainer(ctx, t)
-			if err != nil {
-				fmt.Println(fmt.Sprintf("[%s] Error when run test container: %v", t.Name, err))
-			}
-			if ok {
-				testContainer.Success = true
-				fmt.Println(fmt.Sprintf("[%s] Test container finished successfully", t.Name))
+	}
+
+	results, err := utils.RunDAG(ctx, tasks, c.maxParallel)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, t := range c.tests {
+		r := results[t.Name]
+		if r.Status == utils.DAGTaskSkipped {
+			fmt.Println(fmt.Sprintf("[%s] Test container skipped: %v", t.Name, r.Err))
+			t.Skipped = true
+		}
+		// tests skipped because of a missing image pull secret never fail the run, neither do the tests
+		// depending on them since the error of a dependency is wrapped by the skipped tests
+		if r.Status != utils.DAGTaskSucceeded && !errors.Is(r.Err, errTestContainerSkipped) {
+			if t.AllowFailure {
+				fmt.Println(fmt.Sprintf("[%s] Test container failure is allowed", t.Name))
 			} else {
-				testContainer.Success = false
-				fmt.Println(fmt.Sprintf("[%s] Test container failed", t.Name))
+				failed = append(failed, t.Name)
 			}
-		}(testContainer)
+		}
+	}
+
+	fmt.Println("[Reporting] Write the tests summary")
+	if err = c.writeReports(); err != nil {
+		fmt.Println(fmt.Sprintf("[Reporting] Failed to write the tests summary due to error: %v", err))
 	}
-	wg.Wait()
 	fmt.Println(fmt.Sprintf("[Reporting] Tests completed. Results can be found in %s", c.outputDir))
+	// the namespace is always deleted when the run is cancelled so that no test keeps running in the cluster
+	if aborted := ctx.Err(); aborted != nil {
+		fmt.Println("[TearDown] Tests cancelled, delete namespace", c.namespace)
+		deleteCtx, cancel := context.WithTimeout(context.Background(), abortCleanupTimeout)
+		defer cancel()
+		if err = c.clientset.CoreV1().Namespaces().Delete(deleteCtx, c.namespace, metav1.DeleteOptions{}); err != nil && !k8serr.IsNotFound(err) {
+			fmt.Println(fmt.Sprintf("[TearDown] Failed to delete namespace %s due to error: %v", c.namespace, err))
+		}
+		return fmt.Errorf("product tests cancelled: %w", aborted)
+	}
+	if c.pushgateway != "" {
+		fmt.Println("[Reporting] Push the metrics of the tests to", c.pushgateway)
+		if err = c.pushMetrics(ctx); err != nil {
+			fmt.Println(fmt.Sprintf("[Reporting] Failed to push the metrics due to error: %v", err))
+		}
+	}
 	if c.cleanup {
 		fmt.Println("[TearDown] Delete namespace", c.namespace)
 		err = c.clientset.CoreV1().Namespaces().Delete(ctx, c.namespace, metav1.DeleteOptions{})
@@ -179,9 +337,177 @@ func (c *runTestsCmd) run(ctx context.Context) error {
 			return err
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d test containers failed or were skipped: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// createServiceAccounts creates the service accounts of the tests and binds them to their cluster roles
+func (c *runTestsCmd) createServiceAccounts(ns *v1.Namespace) error {
+	gvk := schema.FromAPIVersionAndKind("v1", "namespace")
+	owner := metav1.NewControllerRef(ns, gvk)
+	created := map[string]bool{}
+	for _, t := range c.tests {
+		saName, clusterRole := testServiceAccount(t)
+		if created[saName+"/"+clusterRole] {
+			continue
+		}
+		created[saName+"/"+clusterRole] = true
+		fmt.Println("[Prepare] Create serviceAccount", saName)
+		sa, err := utils.CreateServiceAccount(c.clientset, c.namespace, saName)
+		if err != nil {
+			return err
+		}
+		if clusterRole == "" {
+			continue
+		}
+		fmt.Println(fmt.Sprintf("[Prepare] Create ClusterRoleBinding to %s for the service account %s", clusterRole, saName))
+		if _, err = utils.CreateClusterRoleBinding(c.clientset, sa, clusterRole, *owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testServiceAccount returns the service account of the test and the cluster role to bind to it
+func testServiceAccount(t *TestContainer) (string, string) {
+	switch {
+	case t.ServiceAccount == "" && t.ClusterRole == "":
+		return serviceAccountName, defaultClusterRole
+	case t.ServiceAccount == "":
+		return fmt.Sprintf("%s-sa", t.Name), t.ClusterRole
+	}
+	return t.ServiceAccount, t.ClusterRole
+}
+
+func validateTestContainer(t *TestContainer) error {
+	for _, v := range t.Volumes {
+		if v.Name == "" || v.MountPath == "" {
+			return fmt.Errorf("[%s] the volumes require a name and a mountPath", t.Name)
+		}
+		if (v.ConfigMap == "") == (v.Secret == "") {
+			return fmt.Errorf("[%s] the volume %s requires either a configMap or a secret", t.Name, v.Name)
+		}
+		if v.Name == testResultsVolume || v.Name == boundSATokenVolume {
+			return fmt.Errorf("[%s] the volume name %s is reserved", t.Name, v.Name)
+		}
+	}
+	for _, sidecar := range t.Sidecars {
+		if sidecar.Name == testContainerName || sidecar.Name == sidecarName {
+			return fmt.Errorf("[%s] the sidecar name %s is reserved", t.Name, sidecar.Name)
+		}
+	}
+	return nil
+}
+
+// runTestContainerWithRetries runs the test container until it passes or the retries are exhausted.
+// The outputs of the failed attempts are kept in the <test>-attempt-<n> folders
+func (c *runTestsCmd) runTestContainerWithRetries(ctx context.Context, t *TestContainer) error {
+	if t.Skipped {
+		return errTestContainerSkipped
+	}
+	start := time.Now()
+	defer func() { t.Duration = time.Since(start) }()
+	backoff := c.retryBackoff
+	if t.RetryBackoff > 0 {
+		backoff = time.Duration(t.RetryBackoff) * time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		t.Attempts = attempt
+		fmt.Println(fmt.Sprintf("[%s] Start test container (attempt %d/%d)", t.Name, attempt, t.Retries+1))
+		ok, err := c.runTestContainer(ctx, t)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Error when run test container: %v", t.Name, err))
+		}
+		t.Success = ok
+		if ok {
+			fmt.Println(fmt.Sprintf("[%s] Test container finished successfully", t.Name))
+			return nil
+		}
+		if ctx.Err() != nil {
+			fmt.Println(fmt.Sprintf("[%s] Test container cancelled", t.Name))
+			return ctx.Err()
+		}
+		fmt.Println(fmt.Sprintf("[%s] Test container failed", t.Name))
+		c.collectDiagnostics(ctx, t)
+		if attempt > t.Retries {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("test container %s failed", t.Name)
+		}
+
+		if err = c.cleanupFailedAttempt(ctx, t.Name, attempt); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to clean up the failed attempt: %v", t.Name, err))
+		}
+		fmt.Println(fmt.Sprintf("[%s] Retry test container in %s", t.Name, backoff))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// cleanupFailedAttempt deletes the job and the pods of the failed attempt and moves its outputs
+// so that the next attempt starts from scratch
+func (c *runTestsCmd) cleanupFailedAttempt(ctx context.Context, testName string, attempt int) error {
+	if err := c.deleteTestJob(ctx, testName); err != nil {
+		return err
+	}
+	testOutputDir := path.Join(c.outputDir, testName)
+	if _, err := os.Stat(testOutputDir); os.IsNotExist(err) {
+		return nil
+	}
+	return os.Rename(testOutputDir, path.Join(c.outputDir, fmt.Sprintf("%s-attempt-%d", testName, attempt)))
+}
+
+// deleteTestJob deletes the job of the test and its pods straight away
+func (c *runTestsCmd) deleteTestJob(ctx context.Context, testName string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.clientset.BatchV1().Jobs(c.namespace).Delete(ctx, testName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !k8serr.IsNotFound(err) {
+		return err
+	}
+	pods, err := utils.GetPods(c.clientset, c.namespace, fmt.Sprintf("job-name=%s", testName))
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		err = c.clientset.CoreV1().Pods(c.namespace).Delete(ctx, pod.GetName(), metav1.DeleteOptions{GracePeriodSeconds: pointer.Int64Ptr(0)})
+		if err != nil && !k8serr.IsNotFound(err) {
+			return err
+		}
+	}
 	return nil
 }
 
+// abortTestContainer saves the partial results of the cancelled test and deletes its job. The context of
+// the run is cancelled at this point so a new one is used
+func (c *runTestsCmd) abortTestContainer(pod *v1.Pod, test *TestContainer, logsDone <-chan error) {
+	ctx, cancel := context.WithTimeout(context.Background(), abortCleanupTimeout)
+	defer cancel()
+	if pod != nil {
+		fmt.Println(fmt.Sprintf("[%s] Save the partial results of the cancelled test", test.Name))
+		if err := c.savePodStatus(ctx, *pod, test.Name); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to save test pod status due to error: %v", test.Name, err))
+		}
+		if err := c.downloadTestResults(ctx, *pod, test.Name); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to download test result due to error: %v", test.Name, err))
+		}
+	}
+	if logsDone != nil {
+		// the logs streamed so far are already in the log file
+		<-logsDone
+	}
+	fmt.Println(fmt.Sprintf("[%s] Delete the job of the cancelled test", test.Name))
+	if err := c.deleteTestJob(ctx, test.Name); err != nil {
+		fmt.Println(fmt.Sprintf("[%s] Failed to delete the test job due to error: %v", test.Name, err))
+	}
+}
+
 func (c *runTestsCmd) runTestContainer(ctx context.Context, test *TestContainer) (bool, error) {
 	job := getTestContainerJob(c.namespace, test)
 	if _, err := utils.CreateJob(c.clientset, job); err != nil {
@@ -191,7 +517,9 @@ func (c *runTestsCmd) runTestContainer(ctx context.Context, test *TestContainer)
 	var podList *v1.PodList
 	var err error
 	fmt.Println(fmt.Sprintf("[%s] Waiting for job to be started", test.Name))
-	err = wait.PollImmediate(time.Duration(1)*time.Second, time.Duration(60)*time.Second, func() (done bool, err error) {
+	startCtx, cancelStart := context.WithTimeout(ctx, time.Duration(60)*time.Second)
+	defer cancelStart()
+	err = wait.PollImmediateUntil(time.Duration(1)*time.Second, func() (done bool, err error) {
 		if podList, err = utils.GetPods(c.clientset, c.namespace, podSelector); err != nil {
 			return false, err
 		}
@@ -199,32 +527,57 @@ func (c *runTestsCmd) runTestContainer(ctx context.Context, test *TestContainer)
 			return true, nil
 		}
 		return false, nil
-	})
+	}, startCtx.Done())
+	if ctx.Err() != nil {
+		c.abortTestContainer(nil, test, nil)
+		return false, ctx.Err()
+	}
 	if err != nil {
 		return false, errors.New(fmt.Sprintf("[%s] Failed to list pods for job %s", test.Name, job.GetName()))
 	}
 	pod := podList.Items[0]
 	fmt.Println(fmt.Sprintf("[%s] Pod found for job: %s", test.Name, pod.GetName()))
+	logsCtx, cancelLogs := context.WithCancel(ctx)
+	defer cancelLogs()
+	logsDone := make(chan error, 1)
+	go func() {
+		logsDone <- c.streamLogs(logsCtx, pod, test.Name)
+	}()
 	fmt.Println(fmt.Sprintf("[%s] Wait for test container to finish", test.Name))
 	var containerResult *v1.ContainerStateTerminated
 	timeout := time.Duration(test.Timeout) * time.Second
-	if containerResult, err = utils.WaitForContainerToComplete(c.clientset, c.namespace, podSelector, "test", timeout, test.Name); err != nil {
+	if containerResult, err = utils.WaitForContainerToComplete(ctx, c.clientset, c.namespace, podSelector, testContainerName, timeout, test.Name); err != nil {
+		if ctx.Err() != nil {
+			cancelLogs()
+			c.abortTestContainer(&pod, test, logsDone)
+		}
 		return false, err
 	}
 	fmt.Println(fmt.Sprintf("[%s] Tests completed. Exit code = %d", test.Name, containerResult.ExitCode))
+	test.ExitCode = &containerResult.ExitCode
 	fmt.Println(fmt.Sprintf("[%s] Save test pod status", test.Name))
-	if err = c.savePodStatus(pod, test.Name); err != nil {
+	if err = c.savePodStatus(ctx, pod, test.Name); err != nil {
 		fmt.Println(fmt.Sprintf("[%s] Failed to save test pod status due to error: %v", test.Name, err))
 	}
 	fmt.Println(fmt.Sprintf("[%s] Download test results", test.Name))
-	if err = c.downloadTestResults(pod, test.Name); err != nil {
+	if err = c.downloadTestResults(ctx, pod, test.Name); err != nil {
 		fmt.Println(fmt.Sprintf("[%s] Failed to download test result due to error: %v", test.Name, err))
 	}
-	fmt.Println(fmt.Sprintf("[%s] Download test container logs", test.Name))
-	if err = c.downloadLogs(pod, test.Name); err != nil {
-		fmt.Println(fmt.Sprintf("[%s] Failed to container logs due to error: %v", test.Name, err))
+	fmt.Println(fmt.Sprintf("[%s] Wait for the end of the test container logs", test.Name))
+	select {
+	case err = <-logsDone:
+	case <-time.After(logsStreamTimeout):
+		cancelLogs()
+		err = <-logsDone
+	}
+	if err != nil {
+		fmt.Println(fmt.Sprintf("[%s] Failed to stream the test container logs due to error: %v", test.Name, err))
+		fmt.Println(fmt.Sprintf("[%s] Download test container logs", test.Name))
+		if err = c.downloadLogs(ctx, pod, test.Name); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to container logs due to error: %v", test.Name, err))
+		}
 	}
-	if err = c.completeJob(pod); err != nil {
+	if err = c.completeJob(ctx, pod); err != nil {
 		return false, err
 	}
 	fmt.Println(fmt.Sprintf("[%s] Delete test job", test.Name))
@@ -242,6 +595,70 @@ func getTestContainerJob(namespace string, t *TestContainer) *batchv1.Job {
 	// cli to retrieve the logs from the containers before the pod is destroyed
 	// from the job
 	var extendedTimeout = t.Timeout + 180
+	saName, _ := testServiceAccount(t)
+	sharedMounts := []v1.VolumeMount{
+		{
+			Name:      testResultsVolume,
+			MountPath: "/test-run-results",
+		},
+		{
+			Name:      boundSATokenVolume,
+			MountPath: "/var/run/secrets/openshift/serviceaccount",
+		},
+	}
+	volumes := []v1.Volume{
+		{
+			Name: testResultsVolume,
+		},
+		{
+			Name: boundSATokenVolume,
+			VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{
+					Sources: []v1.VolumeProjection{
+						{
+							ServiceAccountToken: &v1.ServiceAccountTokenProjection{
+								Audience: "openshift",
+								Path:     "token",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	testMounts := append([]v1.VolumeMount{}, sharedMounts...)
+	for _, v := range t.Volumes {
+		volume := v1.Volume{Name: v.Name}
+		if v.ConfigMap != "" {
+			volume.ConfigMap = &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: v.ConfigMap}}
+		} else {
+			volume.Secret = &v1.SecretVolumeSource{SecretName: v.Secret}
+		}
+		volumes = append(volumes, volume)
+		testMounts = append(testMounts, v1.VolumeMount{Name: v.Name, MountPath: v.MountPath, ReadOnly: true})
+	}
+
+	containers := []v1.Container{
+		{
+			Name:            testContainerName,
+			Image:           t.Image,
+			VolumeMounts:    testMounts,
+			Env:             t.EnvVars,
+			Command:         t.Entrypoint,
+			Args:            assignArguments(t),
+			Resources:       t.Resources,
+			SecurityContext: t.SecurityContext,
+		},
+		{
+			Name:         sidecarName,
+			Image:        "quay.io/quay/busybox:latest",
+			VolumeMounts: sharedMounts,
+			Command:      []string{"sh"},
+			Args:         []string{"-c", "while true; if [[ -f /tmp/done ]]; then exit 0; fi; do sleep 1; done"},
+		},
+	}
+	containers = append(containers, t.Sidecars...)
+
 	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      t.Name,
@@ -254,63 +671,12 @@ func getTestContainerJob(namespace string, t *TestContainer) *batchv1.Job {
 			BackoffLimit:          pointer.Int32Ptr(testJobBackoffLimit),
 			Template: v1.PodTemplateSpec{
 				Spec: v1.PodSpec{
-					Volumes: []v1.Volume{
-						{
-							Name: "test-run-results",
-						},
-						{
-							Name: "bound-sa-token",
-							VolumeSource: v1.VolumeSource{
-								Projected: &v1.ProjectedVolumeSource{
-									Sources: []v1.VolumeProjection{
-										{
-											ServiceAccountToken: &v1.ServiceAccountTokenProjection{
-												Audience: "openshift",
-												Path:     "token",
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-					Containers: []v1.Container{
-						{
-							Name:  "test",
-							Image: t.Image,
-							VolumeMounts: []v1.VolumeMount{
-								{
-									Name:      "test-run-results",
-									MountPath: "/test-run-results",
-								},
-								{
-									Name:      "bound-sa-token",
-									MountPath: "/var/run/secrets/openshift/serviceaccount",
-								},
-							},
-							Env:     t.EnvVars,
-							Command: t.Entrypoint,
-							Args:    assignArguments(t),
-						},
-						{
-							Name:  "sidecar",
-							Image: "quay.io/quay/busybox:latest",
-							VolumeMounts: []v1.VolumeMount{
-								{
-									Name:      "test-run-results",
-									MountPath: "/test-run-results",
-								},
-								{
-									Name:      "bound-sa-token",
-									MountPath: "/var/run/secrets/openshift/serviceaccount",
-								},
-							},
-							Command: []string{"sh"},
-							Args:    []string{"-c", "while true; if [[ -f /tmp/done ]]; then exit 0; fi; do sleep 1; done"},
-						},
-					},
+					Volumes:            volumes,
+					Containers:         containers,
 					RestartPolicy:      "Never",
-					ServiceAccountName: serviceAccountName,
+					ServiceAccountName: saName,
+					NodeSelector:       t.NodeSelector,
+					Tolerations:        t.Tolerations,
 					ImagePullSecrets: []v1.LocalObjectReference{{
 						Name: parseSecretName(t.ImagePullSecret),
 					},
@@ -321,8 +687,8 @@ func getTestContainerJob(namespace string, t *TestContainer) *batchv1.Job {
 	}
 }
 
-func (c *runTestsCmd) savePodStatus(pod v1.Pod, testName string) error {
-	p, err := utils.GetPod(c.clientset, c.namespace, pod.GetName())
+func (c *runTestsCmd) savePodStatus(ctx context.Context, pod v1.Pod, testName string) error {
+	p, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, pod.GetName(), metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -333,25 +699,55 @@ func (c *runTestsCmd) savePodStatus(pod v1.Pod, testName string) error {
 	return utils.WriteObjectToYAML(p, o)
 }
 
-func (c *runTestsCmd) downloadTestResults(pod v1.Pod, testName string) error {
-	from := fmt.Sprintf("%s:/test-run-results/", pod.GetName())
+// downloadTestResults copies the /test-run-results folder of the sidecar container, like `oc cp` does
+func (c *runTestsCmd) downloadTestResults(ctx context.Context, pod v1.Pod, testName string) error {
 	to := path.Join(c.outputDir, testName, "results")
 	if err := os.MkdirAll(to, os.ModePerm); err != nil {
 		return err
 	}
-	return c.oc.Run("rsync", from, to, "-c", "sidecar", "-n", c.namespace)
+	return utils.CopyFromPod(ctx, c.executor, c.namespace, pod.GetName(), sidecarName, "/test-run-results", to)
 }
 
-func (c *runTestsCmd) downloadLogs(pod v1.Pod, testName string) error {
+func (c *runTestsCmd) downloadLogs(ctx context.Context, pod v1.Pod, testName string) error {
 	outputFile := path.Join(c.outputDir, testName, "logs", "container.log")
 	if err := os.MkdirAll(path.Dir(outputFile), os.ModePerm); err != nil {
 		return err
 	}
-	return c.oc.RunWithOutputFile(outputFile, "logs", pod.GetName(), "-c", "test", "-n", c.namespace)
+	return c.saveContainerLogs(ctx, pod, &v1.PodLogOptions{Container: testContainerName}, outputFile)
+}
+
+// streamLogs writes the live logs of the test container to its log file, and to the console
+// with the test name as prefix if the test is followed
+func (c *runTestsCmd) streamLogs(ctx context.Context, pod v1.Pod, testName string) error {
+	outputFile := path.Join(c.outputDir, testName, "logs", "container.log")
+	if err := os.MkdirAll(path.Dir(outputFile), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !c.isFollowed(testName) {
+		return utils.StreamContainerLogs(ctx, c.clientset, c.namespace, pod.GetName(), testContainerName, c.logsInterval, f)
+	}
+	console := utils.NewPrefixWriter(os.Stdout, fmt.Sprintf("[%s] ", testName))
+	defer console.Flush()
+	return utils.StreamContainerLogs(ctx, c.clientset, c.namespace, pod.GetName(), testContainerName, c.logsInterval, io.MultiWriter(f, console))
+}
+
+func (c *runTestsCmd) isFollowed(testName string) bool {
+	for _, f := range c.follow {
+		if f == testName || f == followAllTests {
+			return true
+		}
+	}
+	return false
 }
 
-func (c *runTestsCmd) completeJob(pod v1.Pod) error {
-	return c.oc.Run("exec", pod.GetName(), "-c", "sidecar", "-n", c.namespace, "--", "touch", "/tmp/done")
+func (c *runTestsCmd) completeJob(ctx context.Context, pod v1.Pod) error {
+	return c.executor.Exec(ctx, c.namespace, pod.GetName(), sidecarName, []string{"touch", "/tmp/done"}, ioutil.Discard)
 }
 
 func parseSecretName(pullSecret string) string {
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	diagnosticsDir = "diagnostics"
+	// env var of the test containers with the namespaces to collect the diagnostics of
+	namespaceEnvVar = "NAMESPACE"
+	// number of lines of the logs collected for each container of the failing pods
+	diagnosticsLogsTailLines = 1000
+)
+
+// Custom resources collected with the diagnostics of the namespaces
+var diagnosticsResources = []schema.GroupVersionResource{
+	{Group: "integreatly.org", Version: "v1alpha1", Resource: "rhmis"},
+	{Group: "integreatly.org", Version: "v1alpha1", Resource: "rhmiconfigs"},
+}
+
+// diagnosticsNamespaces returns the namespaces found in the NAMESPACE and *_NAMESPACE env vars of the test.
+// The value of the env vars can be a comma separated list of namespaces
+func diagnosticsNamespaces(t *TestContainer) []string {
+	found := map[string]bool{}
+	var namespaces []string
+	for _, e := range t.EnvVars {
+		if e.Name != namespaceEnvVar && !strings.HasSuffix(e.Name, "_"+namespaceEnvVar) {
+			continue
+		}
+		for _, ns := range strings.Split(e.Value, ",") {
+			ns = strings.TrimSpace(ns)
+			if ns != "" && !found[ns] {
+				found[ns] = true
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	return namespaces
+}
+
+// collectDiagnostics saves the events, the pods, the logs of the failing pods and the custom resources of
+// the namespaces of the test into the diagnostics folder of the test. Errors are logged but not returned
+// as the diagnostics are collected on a best effort basis
+func (c *runTestsCmd) collectDiagnostics(ctx context.Context, t *TestContainer) {
+	namespaces := diagnosticsNamespaces(t)
+	if len(namespaces) == 0 {
+		return
+	}
+	for _, ns := range namespaces {
+		fmt.Println(fmt.Sprintf("[%s] Collect diagnostics for namespace %s", t.Name, ns))
+		dir := path.Join(c.outputDir, t.Name, diagnosticsDir, ns)
+		if err := os.MkdirAll(path.Join(dir, "logs"), os.ModePerm); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to create the diagnostics directory due to error: %v", t.Name, err))
+			return
+		}
+		if err := c.collectEvents(ctx, ns, dir); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to collect the events of namespace %s due to error: %v", t.Name, ns, err))
+		}
+		if err := c.collectPods(ctx, ns, dir); err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Failed to collect the pods of namespace %s due to error: %v", t.Name, ns, err))
+		}
+		if c.dynamicClient == nil {
+			continue
+		}
+		for _, r := range diagnosticsResources {
+			if err := c.collectResources(ctx, ns, r, dir); err != nil {
+				fmt.Println(fmt.Sprintf("[%s] Failed to collect the %s of namespace %s due to error: %v", t.Name, r.Resource, ns, err))
+			}
+		}
+	}
+}
+
+func (c *runTestsCmd) collectEvents(ctx context.Context, namespace string, dir string) error {
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+	return utils.WriteObjectToYAML(events, path.Join(dir, "events.yaml"))
+}
+
+// collectPods saves the description of all the pods of the namespace and the logs of the failing ones
+func (c *runTestsCmd) collectPods(ctx context.Context, namespace string, dir string) error {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if err = utils.WriteObjectToYAML(pods, path.Join(dir, "pods.yaml")); err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if !isFailingPod(pod) {
+			continue
+		}
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			file := path.Join(dir, "logs", fmt.Sprintf("%s-%s.log", pod.GetName(), status.Name))
+			options := &v1.PodLogOptions{Container: status.Name, TailLines: pointer.Int64Ptr(diagnosticsLogsTailLines)}
+			if err = c.saveContainerLogs(ctx, pod, options, file); err != nil {
+				return err
+			}
+			if status.RestartCount > 0 {
+				file = path.Join(dir, "logs", fmt.Sprintf("%s-%s-previous.log", pod.GetName(), status.Name))
+				options = &v1.PodLogOptions{Container: status.Name, Previous: true, TailLines: pointer.Int64Ptr(diagnosticsLogsTailLines)}
+				if err = c.saveContainerLogs(ctx, pod, options, file); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// saveContainerLogs writes the logs of the pod container to the file
+func (c *runTestsCmd) saveContainerLogs(ctx context.Context, pod v1.Pod, options *v1.PodLogOptions, file string) error {
+	stream, err := c.clientset.CoreV1().Pods(pod.GetNamespace()).GetLogs(pod.GetName(), options).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, stream)
+	return err
+}
+
+func (c *runTestsCmd) collectResources(ctx context.Context, namespace string, resource schema.GroupVersionResource, dir string) error {
+	list, err := c.dynamicClient.Resource(resource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// the CRD doesn't exist in the cluster
+		if k8serr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if len(list.Items) == 0 {
+		return nil
+	}
+	return utils.WriteObjectToYAML(list, path.Join(dir, fmt.Sprintf("%s.yaml", resource.GroupResource().String())))
+}
+
+// isFailingPod returns true if the pod failed or one of its containers isn't ready or was restarted
+func isFailingPod(pod v1.Pod) bool {
+	switch pod.Status.Phase {
+	case v1.PodSucceeded:
+		return false
+	case v1.PodFailed, v1.PodUnknown, v1.PodPending:
+		return true
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if !s.Ready || s.RestartCount > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiagnosticsNamespaces(t *testing.T) {
+	test := &TestContainer{
+		EnvVars: []v1.EnvVar{
+			{Name: "NAMESPACE", Value: "redhat-rhmi-3scale"},
+			{Name: "OPERATOR_NAMESPACE", Value: "redhat-rhmi-operator, redhat-rhmi-3scale"},
+			{Name: "NAMESPACES_PREFIX", Value: "redhat-rhmi-"},
+			{Name: "OTHER", Value: "value"},
+		},
+	}
+	expected := []string{"redhat-rhmi-3scale", "redhat-rhmi-operator"}
+	if namespaces := diagnosticsNamespaces(test); !reflect.DeepEqual(namespaces, expected) {
+		t.Fatalf("expected namespaces %v but got %v", expected, namespaces)
+	}
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+	namespace := "redhat-rhmi-3scale"
+	client := fake.NewSimpleClientset(
+		&v1.Event{ObjectMeta: metav1.ObjectMeta{Name: "event", Namespace: namespace}, Message: "Back-off restarting failed container"},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "crashing", Namespace: namespace},
+			Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{{Name: "apicast", RestartCount: 3}},
+			},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "healthy", Namespace: namespace},
+			Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{{Name: "system", Ready: true}},
+			},
+		},
+	)
+	rhmi := &unstructured.Unstructured{}
+	rhmi.SetAPIVersion("integreatly.org/v1alpha1")
+	rhmi.SetKind("RHMI")
+	rhmi.SetName("rhmi")
+	rhmi.SetNamespace(namespace)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		diagnosticsResources[0]: "RHMIList",
+		diagnosticsResources[1]: "RHMIConfigList",
+	}, rhmi)
+
+	outputDir := t.TempDir()
+	cmd := &runTestsCmd{clientset: client, dynamicClient: dynamicClient, outputDir: outputDir}
+	cmd.collectDiagnostics(context.TODO(), &TestContainer{
+		Name:    "3scale-tests",
+		EnvVars: []v1.EnvVar{{Name: "NAMESPACE", Value: namespace}},
+	})
+
+	dir := path.Join(outputDir, "3scale-tests", diagnosticsDir, namespace)
+	expectedFiles := map[string]string{
+		"events.yaml":                        "Back-off restarting failed container",
+		"pods.yaml":                          "healthy",
+		"logs/crashing-apicast.log":          "fake logs",
+		"logs/crashing-apicast-previous.log": "fake logs",
+		"rhmis.integreatly.org.yaml":         "name: rhmi",
+	}
+	for file, content := range expectedFiles {
+		b, err := ioutil.ReadFile(path.Join(dir, file))
+		if err != nil {
+			t.Errorf("expected the diagnostics file %s: %v", file, err)
+			continue
+		}
+		if !strings.Contains(string(b), content) {
+			t.Errorf("expected %s to contain %q but got:\n%s", file, content, b)
+		}
+	}
+	for _, file := range []string{"logs/healthy-system.log", "rhmiconfigs.integreatly.org.yaml"} {
+		if utils.FileExists(path.Join(dir, file)) {
+			t.Errorf("unexpected diagnostics file %s", file)
+		}
+	}
+}
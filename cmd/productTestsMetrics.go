@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const productTestsJobName = "rhmi-product-tests"
+
+var clusterVersionResource = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+
+var (
+	// the cluster_id label is added by the Pushgateway from the grouping key
+	productTestLabels = []string{"test", "version"}
+
+	productTestDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rhmi_product_test_duration_seconds",
+		Help: "Duration of the test container in seconds, including the retries",
+	}, productTestLabels)
+	productTestSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rhmi_product_test_success",
+		Help: "1 if the test container passed, 0 otherwise",
+	}, productTestLabels)
+	productTestExitCode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rhmi_product_test_exit_code",
+		Help: "Exit code of the last attempt of the test container",
+	}, productTestLabels)
+	productTestAttempts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rhmi_product_test_attempts",
+		Help: "Number of times the test container was run",
+	}, productTestLabels)
+)
+
+// pushMetrics pushes the duration, the result, the exit code and the attempts of the tests that were run
+// to the Pushgateway. The metrics are grouped by cluster so that the runs on different clusters don't
+// replace each other
+func (c *runTestsCmd) pushMetrics(ctx context.Context) error {
+	clusterID := c.clusterID
+	if clusterID == "" && c.dynamicClient != nil {
+		var err error
+		if clusterID, err = c.getClusterID(ctx); err != nil {
+			fmt.Println(fmt.Sprintf("[Reporting] Failed to get the cluster id due to error: %v", err))
+		}
+	}
+
+	collectors := []*prometheus.GaugeVec{productTestDuration, productTestSuccess, productTestExitCode, productTestAttempts}
+	pusher := push.New(c.pushgateway, c.pushJobName)
+	for _, collector := range collectors {
+		collector.Reset()
+		pusher.Collector(collector)
+	}
+	if clusterID != "" {
+		pusher.Grouping("cluster_id", clusterID)
+	}
+
+	for _, t := range c.tests {
+		if t.Attempts == 0 {
+			continue
+		}
+		labels := prometheus.Labels{"test": t.Name, "version": c.version}
+		productTestDuration.With(labels).Set(t.Duration.Seconds())
+		success := 0.0
+		if t.Success {
+			success = 1
+		}
+		productTestSuccess.With(labels).Set(success)
+		if t.ExitCode != nil {
+			productTestExitCode.With(labels).Set(float64(*t.ExitCode))
+		}
+		productTestAttempts.With(labels).Set(float64(t.Attempts))
+	}
+
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", c.pushgateway, err)
+	}
+	return nil
+}
+
+// getClusterID returns the id of the cluster from its ClusterVersion
+func (c *runTestsCmd) getClusterID(ctx context.Context) (string, error) {
+	cv, err := c.dynamicClient.Resource(clusterVersionResource).Get(ctx, "version", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	id, _, err := unstructured.NestedString(cv.Object, "spec", "clusterID")
+	return id, err
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPushMetrics(t *testing.T) {
+	var path string
+	families := map[string]*dto.MetricFamily{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			mf := &dto.MetricFamily{}
+			if err := decoder.Decode(mf); err != nil {
+				if err != io.EOF {
+					t.Errorf("failed to decode the metrics: %v", err)
+				}
+				break
+			}
+			families[mf.GetName()] = mf
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cv := &unstructured.Unstructured{}
+	cv.SetAPIVersion("config.openshift.io/v1")
+	cv.SetKind("ClusterVersion")
+	cv.SetName("version")
+	unstructured.SetNestedField(cv.Object, "cluster-1234", "spec", "clusterID")
+
+	zero, one := int32(0), int32(1)
+	cmd := &runTestsCmd{
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), cv),
+		pushgateway:   server.URL,
+		pushJobName:   productTestsJobName,
+		version:       "2.8.0",
+		tests: []*TestContainer{
+			{Name: "passed", Success: true, Attempts: 2, ExitCode: &zero, Duration: 90 * time.Second},
+			{Name: "failed", Attempts: 1, ExitCode: &one, Duration: 30 * time.Second},
+			{Name: "skipped", Skipped: true},
+		},
+	}
+	if err := cmd.pushMetrics(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "/metrics/job/rhmi-product-tests/cluster_id/cluster-1234"; path != expected {
+		t.Errorf("expected the metrics to be pushed to %s but got %s", expected, path)
+	}
+	expected := map[string]map[string]float64{
+		"rhmi_product_test_duration_seconds": {"passed": 90, "failed": 30},
+		"rhmi_product_test_success":          {"passed": 1, "failed": 0},
+		"rhmi_product_test_exit_code":        {"passed": 0, "failed": 1},
+		"rhmi_product_test_attempts":         {"passed": 2, "failed": 1},
+	}
+	for name, values := range expected {
+		mf, ok := families[name]
+		if !ok {
+			t.Errorf("metric %s not pushed", name)
+			continue
+		}
+		if len(mf.GetMetric()) != len(values) {
+			t.Errorf("expected %d %s metrics but got %d", len(values), name, len(mf.GetMetric()))
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["version"] != "2.8.0" {
+				t.Errorf("unexpected labels of %s: %v", name, labels)
+			}
+			if v := m.GetGauge().GetValue(); v != values[labels["test"]] {
+				t.Errorf("expected %s of %s to be %v but got %v", name, labels["test"], values[labels["test"]], v)
+			}
+		}
+	}
+}
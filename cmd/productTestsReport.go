@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+const (
+	productTestsJUnitFile   = "junit-product-tests.xml"
+	productTestsSummaryFile = "summary.json"
+	productTestsHTMLFile    = "summary.html"
+
+	testContainerPassed  = "passed"
+	testContainerFailed  = "failed"
+	testContainerSkipped = "skipped"
+)
+
+// testContainerSummary is the result of a single test container in the summary.json file
+type testContainerSummary struct {
+	Name            string   `json:"name"`
+	Image           string   `json:"image"`
+	Status          string   `json:"status"`
+	AllowFailure    bool     `json:"allowFailure"`
+	Attempts        int      `json:"attempts"`
+	ExitCode        *int32   `json:"exitCode,omitempty"`
+	DurationSeconds float64  `json:"durationSeconds"`
+	Tests           int      `json:"tests"`
+	Failures        int      `json:"failures"`
+	Logs            string   `json:"logs,omitempty"`
+	PodStatus       string   `json:"podStatus,omitempty"`
+	Diagnostics     string   `json:"diagnostics,omitempty"`
+	Results         []string `json:"results,omitempty"`
+}
+
+type productTestsSummary struct {
+	Passed     int                     `json:"passed"`
+	Failed     int                     `json:"failed"`
+	Skipped    int                     `json:"skipped"`
+	Containers []*testContainerSummary `json:"containers"`
+}
+
+var productTestsHTMLTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Product tests summary</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.passed { background-color: #dff0d8; }
+.failed { background-color: #f2dede; }
+.skipped { background-color: #fcf8e3; }
+</style>
+</head>
+<body>
+<h1>Product tests summary</h1>
+<p>Passed: {{.Passed}}, Failed: {{.Failed}}, Skipped: {{.Skipped}}</p>
+<table>
+<tr><th>Test container</th><th>Status</th><th>Duration</th><th>Exit code</th><th>Attempts</th><th>Tests</th><th>Failures</th><th>Outputs</th></tr>
+{{- range .Containers}}
+<tr class="{{.Status}}">
+<td>{{.Name}}</td>
+<td>{{.Status}}{{if .AllowFailure}} (failure allowed){{end}}</td>
+<td>{{printf "%.0fs" .DurationSeconds}}</td>
+<td>{{if .ExitCode}}{{.ExitCode}}{{else}}-{{end}}</td>
+<td>{{.Attempts}}</td>
+<td>{{.Tests}}</td>
+<td>{{.Failures}}</td>
+<td>{{if .Logs}}<a href="{{.Logs}}">logs</a> {{end}}{{if .PodStatus}}<a href="{{.PodStatus}}">pod</a> {{end}}{{if .Diagnostics}}<a href="{{.Diagnostics}}">diagnostics</a> {{end}}{{range .Results}}<a href="{{.}}">{{.}}</a> {{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// writeReports aggregates the results of the test containers into a single JUnit report with
+// one test suite per test container, a summary.json file and a static HTML page
+func (c *runTestsCmd) writeReports() error {
+	suites := &utils.JUnitTestSuites{}
+	summary := &productTestsSummary{}
+	for _, t := range c.tests {
+		s, suite, err := c.summarizeTestContainer(t)
+		if err != nil {
+			return err
+		}
+		switch s.Status {
+		case testContainerPassed:
+			summary.Passed++
+		case testContainerFailed:
+			summary.Failed++
+		default:
+			summary.Skipped++
+		}
+		summary.Containers = append(summary.Containers, s)
+		suites.Suites = append(suites.Suites, *suite)
+	}
+
+	junitFile, err := os.Create(path.Join(c.outputDir, productTestsJUnitFile))
+	if err != nil {
+		return err
+	}
+	defer junitFile.Close()
+	if err = suites.WriteXML(junitFile); err != nil {
+		return err
+	}
+
+	if err = writeJSONFile(path.Join(c.outputDir, productTestsSummaryFile), summary); err != nil {
+		return err
+	}
+
+	htmlFile, err := os.Create(path.Join(c.outputDir, productTestsHTMLFile))
+	if err != nil {
+		return err
+	}
+	defer htmlFile.Close()
+	return productTestsHTMLTemplate.Execute(htmlFile, summary)
+}
+
+// summarizeTestContainer merges all the JUnit files found in the results of the test container
+// into one test suite. A test case is added for the test container itself when it didn't pass
+// and the JUnit results don't explain why
+func (c *runTestsCmd) summarizeTestContainer(t *TestContainer) (*testContainerSummary, *utils.JUnitTestSuite, error) {
+	s := &testContainerSummary{
+		Name:            t.Name,
+		Image:           t.Image,
+		AllowFailure:    t.AllowFailure,
+		Attempts:        t.Attempts,
+		ExitCode:        t.ExitCode,
+		DurationSeconds: t.Duration.Seconds(),
+	}
+	switch {
+	case t.Success:
+		s.Status = testContainerPassed
+	case t.Skipped:
+		s.Status = testContainerSkipped
+	default:
+		s.Status = testContainerFailed
+	}
+	if p := path.Join(t.Name, "logs", "container.log"); utils.FileExists(path.Join(c.outputDir, p)) {
+		s.Logs = p
+	}
+	if p := path.Join(t.Name, "logs", "pod.yaml"); utils.FileExists(path.Join(c.outputDir, p)) {
+		s.PodStatus = p
+	}
+	if p := path.Join(t.Name, diagnosticsDir); dirExists(path.Join(c.outputDir, p)) {
+		s.Diagnostics = p
+	}
+
+	suite := &utils.JUnitTestSuite{Name: t.Name, Time: formatSeconds(s.DurationSeconds)}
+	resultsDir := path.Join(c.outputDir, t.Name, "results")
+	err := filepath.Walk(resultsDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(c.outputDir, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		s.Results = append(s.Results, rel)
+		if filepath.Ext(p) != ".xml" {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		junit, err := unmarshalJUnit(b)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("[%s] Ignore %s, not a valid JUnit file: %v", t.Name, rel, err))
+			return nil
+		}
+		for _, tc := range junit.TestCases {
+			testCase := utils.JUnitTestCase{Classname: tc.Classname, Name: tc.Name, Time: tc.Time}
+			if tc.SkipMessage != nil {
+				testCase.SkipMessage = &utils.JUnitSkipMessage{Message: tc.SkipMessage.Message}
+			}
+			if tc.Failure != nil {
+				testCase.Failure = &utils.JUnitFailure{Message: tc.Failure.Message, Type: tc.Failure.Type, Contents: tc.Failure.Contents}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	containerCase := utils.JUnitTestCase{Classname: t.Name, Name: fmt.Sprintf("%s test container", t.Name), Time: suite.Time}
+	switch {
+	case s.Status == testContainerSkipped:
+		containerCase.SkipMessage = &utils.JUnitSkipMessage{Message: "test container skipped"}
+		suite.TestCases = append(suite.TestCases, containerCase)
+	case s.Status == testContainerFailed && suite.Failures == 0:
+		message := "test container failed"
+		if t.ExitCode != nil {
+			message = fmt.Sprintf("test container failed with exit code %d", *t.ExitCode)
+		}
+		containerCase.Failure = &utils.JUnitFailure{Message: message, Type: "TestContainerFailure"}
+		suite.Failures++
+		suite.TestCases = append(suite.TestCases, containerCase)
+	case len(suite.TestCases) == 0:
+		suite.TestCases = append(suite.TestCases, containerCase)
+	}
+	suite.Tests = len(suite.TestCases)
+	s.Tests = suite.Tests
+	s.Failures = suite.Failures
+	return s, suite, nil
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+const testJUnitResults = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite tests="2" failures="1" time="3.5" name="e2e">
+  <testcase classname="e2e" name="passing test" time="1.5"></testcase>
+  <testcase classname="e2e" name="failing test" time="2">
+    <failure message="expected true" type="Failure">assertion failed</failure>
+  </testcase>
+</testsuite>
+`
+
+func TestWriteReports(t *testing.T) {
+	outputDir := t.TempDir()
+	writeFile := func(file string, content string) {
+		p := path.Join(outputDir, file)
+		if err := os.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("with-junit/results/junit-e2e.xml", testJUnitResults)
+	writeFile("with-junit/results/notes.txt", "not a junit file")
+	writeFile("with-junit/logs/container.log", "logs")
+	writeFile("with-junit/logs/pod.yaml", "pod")
+	writeFile("with-junit/diagnostics/test/events.yaml", "events")
+	writeFile("without-junit/logs/container.log", "logs")
+
+	zero, two := int32(0), int32(2)
+	cmd := &runTestsCmd{
+		outputDir: outputDir,
+		tests: []*TestContainer{
+			{Name: "with-junit", Image: "image", Attempts: 1, ExitCode: &two, Duration: 4 * time.Second},
+			{Name: "without-junit", Image: "image", Success: true, Attempts: 1, ExitCode: &zero, Duration: time.Second},
+			{Name: "broken", Image: "image", Attempts: 2, ExitCode: &two, AllowFailure: true},
+			{Name: "skipped", Image: "image", Skipped: true},
+		},
+	}
+	if err := cmd.writeReports(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(outputDir, productTestsJUnitFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := &utils.JUnitTestSuites{}
+	if err = xml.Unmarshal(b, suites); err != nil {
+		t.Fatalf("invalid JUnit report: %v", err)
+	}
+	expectedSuites := []struct {
+		name     string
+		tests    int
+		failures int
+	}{
+		{name: "with-junit", tests: 2, failures: 1},
+		{name: "without-junit", tests: 1, failures: 0},
+		{name: "broken", tests: 1, failures: 1},
+		{name: "skipped", tests: 1, failures: 0},
+	}
+	if len(suites.Suites) != len(expectedSuites) {
+		t.Fatalf("expected %d test suites but got %d", len(expectedSuites), len(suites.Suites))
+	}
+	for i, e := range expectedSuites {
+		s := suites.Suites[i]
+		if s.Name != e.name || s.Tests != e.tests || s.Failures != e.failures {
+			t.Errorf("expected suite %s with %d tests and %d failures but got %s with %d tests and %d failures",
+				e.name, e.tests, e.failures, s.Name, s.Tests, s.Failures)
+		}
+	}
+	if f := suites.Suites[2].TestCases[0].Failure; f == nil || f.Message != "test container failed with exit code 2" {
+		t.Errorf("unexpected failure for the broken test container: %+v", f)
+	}
+	if suites.Suites[3].TestCases[0].SkipMessage == nil {
+		t.Errorf("expected the skipped test container to be reported as skipped")
+	}
+
+	summary := &productTestsSummary{}
+	if err = readJSONFile(path.Join(outputDir, productTestsSummaryFile), summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Passed != 1 || summary.Failed != 2 || summary.Skipped != 1 {
+		t.Errorf("unexpected summary totals: %+v", summary)
+	}
+	withJUnit := summary.Containers[0]
+	if withJUnit.Status != testContainerFailed || *withJUnit.ExitCode != 2 || withJUnit.DurationSeconds != 4 {
+		t.Errorf("unexpected summary for the with-junit test container: %+v", withJUnit)
+	}
+	if withJUnit.Logs != "with-junit/logs/container.log" || withJUnit.PodStatus != "with-junit/logs/pod.yaml" || withJUnit.Diagnostics != "with-junit/diagnostics" || len(withJUnit.Results) != 2 {
+		t.Errorf("unexpected outputs for the with-junit test container: %+v", withJUnit)
+	}
+	if summary.Containers[2].PodStatus != "" || summary.Containers[2].Logs != "" || summary.Containers[2].Diagnostics != "" {
+		t.Errorf("expected no outputs for the broken test container: %+v", summary.Containers[2])
+	}
+
+	html, err := ioutil.ReadFile(path.Join(outputDir, productTestsHTMLFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`href="with-junit/logs/container.log"`, `href="with-junit/results/junit-e2e.xml"`, "Passed: 1, Failed: 2, Skipped: 1", "(failure allowed)"} {
+		if !strings.Contains(string(html), s) {
+			t.Errorf("expected the HTML summary to contain %s", s)
+		}
+	}
+}
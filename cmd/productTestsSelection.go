@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selectTests returns the tests matching at least one of the include filters, if any, and none of the exclude
+// filters, with the tests they depend on, then keeps only the tests of the shard. A filter is either a label in
+// the format key=value or a regular expression matching the whole test name. The shard is in the format
+// index/total, starting from 1. The tests depending on each other are always in the same shard, so that no test
+// runs without its prerequisites. Excluding a prerequisite of a selected test is an error
+func selectTests(tests []*TestContainer, include []string, exclude []string, shard string) ([]*TestContainer, error) {
+	byName := map[string]*TestContainer{}
+	for _, t := range tests {
+		byName[t.Name] = t
+	}
+
+	selected := map[string]bool{}
+	var selectWithPrerequisites func(t *TestContainer) error
+	selectWithPrerequisites = func(t *TestContainer) error {
+		if selected[t.Name] {
+			return nil
+		}
+		selected[t.Name] = true
+		for _, d := range t.DependsOn {
+			// unknown dependencies are reported when the dependencies are validated
+			prerequisite, ok := byName[d]
+			if !ok || selected[d] {
+				continue
+			}
+			excluded, err := matchesAnyTestFilter(prerequisite, exclude)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				return fmt.Errorf("the test %s depends on the test %s which is excluded", t.Name, d)
+			}
+			if err := selectWithPrerequisites(prerequisite); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range tests {
+		if len(include) > 0 {
+			ok, err := matchesAnyTestFilter(t, include)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		excluded, err := matchesAnyTestFilter(t, exclude)
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+		if err := selectWithPrerequisites(t); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*TestContainer
+	for _, t := range tests {
+		if selected[t.Name] {
+			result = append(result, t)
+		}
+	}
+
+	if shard != "" {
+		index, total, err := parseShard(shard)
+		if err != nil {
+			return nil, err
+		}
+		groups := testGroups(result)
+		var sharded []*TestContainer
+		for i, t := range result {
+			if groups[i]%total == index-1 {
+				sharded = append(sharded, t)
+			}
+		}
+		result = sharded
+	}
+	return result, nil
+}
+
+// testGroups returns the index of the group of each test, where a group is a set of tests connected by their
+// dependencies. The groups are numbered in the order of their first test
+func testGroups(tests []*TestContainer) []int {
+	// union find of the tests by name
+	parent := map[string]string{}
+	var find func(name string) string
+	find = func(name string) string {
+		if p, ok := parent[name]; ok && p != name {
+			root := find(p)
+			parent[name] = root
+			return root
+		}
+		return name
+	}
+	for _, t := range tests {
+		parent[t.Name] = t.Name
+	}
+	for _, t := range tests {
+		for _, d := range t.DependsOn {
+			if _, ok := parent[d]; ok {
+				parent[find(d)] = find(t.Name)
+			}
+		}
+	}
+
+	groups := make([]int, len(tests))
+	indexes := map[string]int{}
+	for i, t := range tests {
+		root := find(t.Name)
+		if _, ok := indexes[root]; !ok {
+			indexes[root] = len(indexes)
+		}
+		groups[i] = indexes[root]
+	}
+	return groups
+}
+
+func matchesAnyTestFilter(t *TestContainer, filters []string) (bool, error) {
+	for _, f := range filters {
+		if kv := strings.SplitN(f, "=", 2); len(kv) == 2 {
+			if value, ok := t.Labels[kv[0]]; ok && value == kv[1] {
+				return true, nil
+			}
+			continue
+		}
+		r, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", f))
+		if err != nil {
+			return false, fmt.Errorf("invalid test filter %s: %w", f, err)
+		}
+		if r.MatchString(t.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func parseShard(shard string) (int, int, error) {
+	parts := strings.Split(shard, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %s, expected the format index/total", shard)
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard index %s: %w", parts[0], err)
+	}
+	total, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard total %s: %w", parts[1], err)
+	}
+	if total < 1 || index < 1 || index > total {
+		return 0, 0, fmt.Errorf("invalid shard %s, the index must be between 1 and the total", shard)
+	}
+	return index, total, nil
+}
+
+// printTestPlan writes the tests that would be run, one per line
+func printTestPlan(w io.Writer, tests []*TestContainer, maxParallel int) {
+	fmt.Fprintf(w, "%d test containers, max parallel: %d\n", len(tests), maxParallel)
+	for _, t := range tests {
+		line := fmt.Sprintf("- %s image=%s timeout=%ds", t.Name, t.Image, t.Timeout)
+		if len(t.Labels) > 0 {
+			var labels []string
+			for k, v := range t.Labels {
+				labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+			}
+			sort.Strings(labels)
+			line += fmt.Sprintf(" labels=%s", strings.Join(labels, ","))
+		}
+		if len(t.DependsOn) > 0 {
+			line += fmt.Sprintf(" dependsOn=%s", strings.Join(t.DependsOn, ","))
+		}
+		if t.Retries > 0 {
+			line += fmt.Sprintf(" retries=%d", t.Retries)
+		}
+		if t.AllowFailure {
+			line += " allowFailure"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func newSelectionTests() []*TestContainer {
+	return []*TestContainer{
+		{Name: "codeready-test", Labels: map[string]string{"suite": "smoke"}},
+		{Name: "3scale-test", Labels: map[string]string{"suite": "full"}},
+		{Name: "3scale-upgrade-test", Labels: map[string]string{"suite": "full"}, DependsOn: []string{"3scale-test"}},
+		{Name: "amq-test", Labels: map[string]string{"suite": "smoke"}},
+		{Name: "ups-test"},
+	}
+}
+
+func testNames(tests []*TestContainer) []string {
+	var names []string
+	for _, t := range tests {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestSelectTests(t *testing.T) {
+	cases := []struct {
+		description string
+		include     []string
+		exclude     []string
+		shard       string
+		expected    []string
+		expectErr   bool
+	}{
+		{
+			description: "all the tests",
+			expected:    []string{"codeready-test", "3scale-test", "3scale-upgrade-test", "amq-test", "ups-test"},
+		},
+		{
+			description: "include by name",
+			include:     []string{"3scale-.*"},
+			expected:    []string{"3scale-test", "3scale-upgrade-test"},
+		},
+		{
+			description: "the name must match entirely",
+			include:     []string{"3scale"},
+			expected:    nil,
+		},
+		{
+			description: "include by label and name",
+			include:     []string{"suite=smoke", "ups-test"},
+			expected:    []string{"codeready-test", "amq-test", "ups-test"},
+		},
+		{
+			description: "exclude",
+			include:     []string{"suite=full", "suite=smoke"},
+			exclude:     []string{".*upgrade.*", "amq-test"},
+			expected:    []string{"codeready-test", "3scale-test"},
+		},
+		{
+			description: "include a test with its prerequisite",
+			include:     []string{"3scale-upgrade-test"},
+			expected:    []string{"3scale-test", "3scale-upgrade-test"},
+		},
+		{
+			description: "exclude a prerequisite of a selected test",
+			include:     []string{"3scale-upgrade-test"},
+			exclude:     []string{"3scale-test"},
+			expectErr:   true,
+		},
+		{
+			description: "first shard",
+			shard:       "1/2",
+			expected:    []string{"codeready-test", "amq-test"},
+		},
+		{
+			description: "second shard with the tests depending on each other",
+			shard:       "2/2",
+			expected:    []string{"3scale-test", "3scale-upgrade-test", "ups-test"},
+		},
+		{
+			description: "second shard of the selected tests",
+			exclude:     []string{"codeready-test"},
+			shard:       "2/2",
+			expected:    []string{"amq-test"},
+		},
+		{
+			description: "invalid regular expression",
+			include:     []string{"(3scale"},
+			expectErr:   true,
+		},
+		{
+			description: "invalid shard",
+			shard:       "3/2",
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			selected, err := selectTests(newSelectionTests(), c.include, c.exclude, c.shard)
+			if c.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if names := testNames(selected); !reflect.DeepEqual(names, c.expected) {
+				t.Fatalf("expected %v but got %v", c.expected, names)
+			}
+		})
+	}
+}
+
+func TestSelectTests_dependencyChain(t *testing.T) {
+	tests := []*TestContainer{
+		{Name: "install"},
+		{Name: "smoke"},
+		{Name: "configure", DependsOn: []string{"install"}},
+		{Name: "other"},
+		{Name: "upgrade", DependsOn: []string{"configure"}},
+		{Name: "uninstall", DependsOn: []string{"upgrade", "smoke"}},
+	}
+	var all []string
+	for _, shard := range []string{"1/3", "2/3", "3/3"} {
+		selected, err := selectTests(tests, nil, nil, shard)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names := map[string]bool{}
+		for _, s := range selected {
+			names[s.Name] = true
+		}
+		for _, s := range selected {
+			for _, d := range s.DependsOn {
+				if !names[d] {
+					t.Errorf("shard %s: the test %s runs without its prerequisite %s", shard, s.Name, d)
+				}
+			}
+		}
+		all = append(all, testNames(selected)...)
+	}
+	expected := []string{"install", "smoke", "configure", "upgrade", "uninstall", "other"}
+	if !reflect.DeepEqual(all, expected) {
+		t.Fatalf("expected the shards to have %v but got %v", expected, all)
+	}
+
+	// the whole chain is selected with the last test
+	selected, err := selectTests(tests, []string{"uninstall"}, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := testNames(selected); !reflect.DeepEqual(names, []string{"install", "smoke", "configure", "upgrade", "uninstall"}) {
+		t.Fatalf("expected the prerequisites to be selected but got %v", names)
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	for _, shard := range []string{"", "1", "0/2", "a/2", "1/b", "1/0", "1/2/3"} {
+		if _, _, err := parseShard(shard); err == nil {
+			t.Errorf("expected an error for the shard %q", shard)
+		}
+	}
+	index, total, err := parseShard("2/3")
+	if err != nil || index != 2 || total != 3 {
+		t.Errorf("expected 2/3 but got %d/%d: %v", index, total, err)
+	}
+}
+
+func TestPrintTestPlan(t *testing.T) {
+	out := &bytes.Buffer{}
+	printTestPlan(out, []*TestContainer{
+		{Name: "3scale-test", Image: "3scale-image", Timeout: 3600, Labels: map[string]string{"suite": "full", "product": "3scale"}, Retries: 1},
+		{Name: "3scale-upgrade-test", Image: "upgrade-image", Timeout: 600, DependsOn: []string{"3scale-test"}, AllowFailure: true},
+	}, 2)
+	expected := `2 test containers, max parallel: 2
+- 3scale-test image=3scale-image timeout=3600s labels=product=3scale,suite=full retries=1
+- 3scale-upgrade-test image=upgrade-image timeout=600s dependsOn=3scale-test allowFailure
+`
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+}
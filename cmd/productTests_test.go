@@ -1,36 +1,56 @@
 package cmd
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/integr8ly/delorean/pkg/utils"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
-type mockOC struct {
-	runFunc         func(arg ...string) error
-	runWithFileFunc func(outputFile string, arg ...string) error
+type mockPodExecutor struct {
+	execFunc func(podName string, containerName string, command []string, stdout io.Writer) error
 }
 
-func (o *mockOC) Run(arg ...string) error {
-	if o.runFunc != nil {
-		return o.runFunc(arg...)
+func (e *mockPodExecutor) Exec(ctx context.Context, namespace string, podName string, containerName string, command []string, stdout io.Writer) error {
+	if e.execFunc != nil {
+		return e.execFunc(podName, containerName, command, stdout)
 	}
 	return errors.New("method not implemented")
 }
 
-func (o *mockOC) RunWithOutputFile(outputFile string, arg ...string) error {
-	if o.runWithFileFunc != nil {
-		return o.runWithFileFunc(outputFile, arg...)
+// writeTar writes the files in the tar format, like tar does in the sidecar container when the results are copied
+func writeTar(w io.Writer, files map[string]string) error {
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
 	}
-	return errors.New("method not implemented")
+	return tw.Close()
 }
 
 func TestRun(t *testing.T) {
@@ -54,27 +74,27 @@ func TestRun(t *testing.T) {
 		tests:     tests,
 		outputDir: outputDir,
 		namespace: namespace,
-		oc: &mockOC{
-			runFunc: func(arg ...string) error {
-				if arg[0] == "rsync" || arg[0] == "logs" {
-					return errors.New("should be logged but ignored")
+		executor: &mockPodExecutor{
+			execFunc: func(podName string, containerName string, command []string, stdout io.Writer) error {
+				if containerName != "sidecar" {
+					return fmt.Errorf("unexpected container %s", containerName)
 				}
-				return nil
-			},
-			runWithFileFunc: func(outputFile string, arg ...string) error {
-				if arg[0] == "rsync" || arg[0] == "logs" {
-					return errors.New("should be logged but ignored")
+				if command[0] == "tar" {
+					return writeTar(stdout, map[string]string{"./junit.xml": testJUnitResults})
 				}
 				return nil
 			},
 		},
+		follow:       []string{"test1"},
+		logsInterval: time.Millisecond,
 	}
 
 	go func() {
 		time.Sleep(200 * time.Millisecond)
 		pod, err := createPod(client, namespace)
 		if err != nil {
-			t.Fatalf("failed to create pod: %v", err)
+			t.Errorf("failed to create pod: %v", err)
+			return
 		}
 
 		time.Sleep(1 * time.Second)
@@ -92,6 +112,21 @@ func TestRun(t *testing.T) {
 	if !tests[0].Success {
 		t.Fatalf("test didn't pass")
 	}
+	// the fake clientset always returns "fake logs" as logs
+	logs, err := ioutil.ReadFile(path.Join(outputDir, "test1", "logs", "container.log"))
+	if err != nil {
+		t.Fatalf("failed to read the streamed logs: %v", err)
+	}
+	if string(logs) != "fake logs" {
+		t.Fatalf("unexpected streamed logs: %s", logs)
+	}
+	results, err := ioutil.ReadFile(path.Join(outputDir, "test1", "results", "junit.xml"))
+	if err != nil {
+		t.Fatalf("failed to read the copied test results: %v", err)
+	}
+	if string(results) != testJUnitResults {
+		t.Fatalf("unexpected test results: %s", results)
+	}
 }
 
 func createPod(client kubernetes.Interface, namespace string) (*v1.Pod, error) {
@@ -122,3 +157,324 @@ func terminateContainer(client kubernetes.Interface, namespace string, pod *v1.P
 	}
 	return client.CoreV1().Pods(namespace).Update(context.TODO(), updated, metav1.UpdateOptions{})
 }
+
+// simulateTestJobs creates a pod for each job created in the fake clientset and terminates its test container
+// with the exit codes returned by exitCodes for each attempt of the test
+func simulateTestJobs(t *testing.T, client *fake.Clientset, namespace string, exitCodes func(name string, attempt int) int32) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		mu.Lock()
+		attempts[job.Name]++
+		attempt := attempts[job.Name]
+		mu.Unlock()
+		go func() {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-%d", job.Name, attempt),
+					Namespace: namespace,
+					Labels:    map[string]string{"job-name": job.Name},
+				},
+				Status: v1.PodStatus{Phase: v1.PodPending},
+			}
+			if _, err := client.CoreV1().Pods(namespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+				t.Errorf("failed to create pod: %v", err)
+				return
+			}
+			pod.Status.Phase = v1.PodRunning
+			pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+				Name:  "test",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: exitCodes(job.Name, attempt)}},
+			}}
+			// keep updating the pod until the job or the pod is deleted, so the watcher gets the modified event
+			for i := 0; i < 100; i++ {
+				time.Sleep(20 * time.Millisecond)
+				if _, err := client.BatchV1().Jobs(namespace).Get(context.TODO(), job.Name, metav1.GetOptions{}); err != nil {
+					_ = client.CoreV1().Pods(namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+					return
+				}
+				if _, err := client.CoreV1().Pods(namespace).Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+					return
+				}
+			}
+		}()
+		return false, nil, nil
+	})
+}
+
+func newTestRunTestsCmdWithSimulatedJobs(t *testing.T, tests []*TestContainer, exitCodes func(name string, attempt int) int32) *runTestsCmd {
+	client := fake.NewSimpleClientset()
+	namespace := "test"
+	simulateTestJobs(t, client, namespace, exitCodes)
+	return &runTestsCmd{
+		clientset: client,
+		tests:     tests,
+		outputDir: t.TempDir(),
+		namespace: namespace,
+		executor: &mockPodExecutor{
+			execFunc: func(podName string, containerName string, command []string, stdout io.Writer) error { return nil },
+		},
+		// the fake clientset doesn't filter the watched pods by label, so run one test at a time
+		maxParallel:  1,
+		retryBackoff: time.Millisecond,
+		logsInterval: time.Millisecond,
+	}
+}
+
+func TestRun_dependenciesAndRetries(t *testing.T) {
+	exitCodes := func(name string, attempt int) int32 {
+		switch {
+		case name == "flaky" && attempt == 1, name == "broken":
+			return 1
+		}
+		return 0
+	}
+
+	tests := []*TestContainer{
+		{Name: "flaky", Image: "flaky-image", Timeout: 5, Retries: 1},
+		{Name: "after-flaky", Image: "after-flaky-image", Timeout: 5, DependsOn: []string{"flaky"}},
+		{Name: "broken", Image: "broken-image", Timeout: 5, AllowFailure: true},
+		{Name: "after-broken", Image: "after-broken-image", Timeout: 5, DependsOn: []string{"broken"}, AllowFailure: true},
+	}
+	cmd := newTestRunTestsCmdWithSimulatedJobs(t, tests, exitCodes)
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !tests[0].Success || tests[0].Attempts != 2 {
+		t.Errorf("expected flaky to pass on the second attempt but got %+v", tests[0])
+	}
+	if _, err := os.Stat(path.Join(cmd.outputDir, "flaky-attempt-1")); err != nil {
+		t.Errorf("expected the outputs of the first attempt to be kept: %v", err)
+	}
+	if !tests[1].Success {
+		t.Errorf("expected after-flaky to pass but got %+v", tests[1])
+	}
+	if tests[2].Success || tests[2].Attempts != 1 {
+		t.Errorf("expected broken to fail once but got %+v", tests[2])
+	}
+	if !tests[3].Skipped || tests[3].Attempts != 0 {
+		t.Errorf("expected after-broken to be skipped but got %+v", tests[3])
+	}
+
+	// the run fails once a test that is not allowed to fail fails
+	tests = []*TestContainer{{Name: "broken", Image: "broken-image", Timeout: 5}}
+	cmd = newTestRunTestsCmdWithSimulatedJobs(t, tests, exitCodes)
+	if err := cmd.run(context.TODO()); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected the run to fail because of the broken test but got %v", err)
+	}
+}
+
+func TestRun_missingImagePullSecret(t *testing.T) {
+	tests := []*TestContainer{
+		{Name: "private", Image: "private-image", Timeout: 5, ImagePullSecret: "DELOREAN_TEST_MISSING_PULL_SECRET"},
+		{Name: "after-private", Image: "after-private-image", Timeout: 5, DependsOn: []string{"private"}},
+		{Name: "public", Image: "public-image", Timeout: 5},
+	}
+	cmd := newTestRunTestsCmdWithSimulatedJobs(t, tests, func(name string, attempt int) int32 { return 0 })
+	// neither the test skipped for the missing secret nor the tests depending on it fail the run
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !tests[0].Skipped || !tests[1].Skipped {
+		t.Errorf("expected private and after-private to be skipped: %+v %+v", tests[0], tests[1])
+	}
+	if !tests[2].Success {
+		t.Errorf("expected public to pass but got %+v", tests[2])
+	}
+}
+
+func TestRun_cancelled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	namespace := "test"
+	// the test container never terminates
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		// the clientset is locked while the reactors run
+		go func() {
+			_, err := client.CoreV1().Pods(namespace).Create(context.TODO(), &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: job.Name + "-pod", Namespace: namespace, Labels: map[string]string{"job-name": job.Name}},
+				Status:     v1.PodStatus{Phase: v1.PodRunning},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				t.Errorf("failed to create pod: %v", err)
+			}
+		}()
+		return false, nil, nil
+	})
+	tests := []*TestContainer{
+		{Name: "hanging", Image: "hanging-image", Timeout: 3600},
+		{Name: "after-hanging", Image: "after-hanging-image", Timeout: 3600, DependsOn: []string{"hanging"}},
+	}
+	outputDir := t.TempDir()
+	cmd := &runTestsCmd{
+		clientset: client,
+		tests:     tests,
+		outputDir: outputDir,
+		namespace: namespace,
+		executor: &mockPodExecutor{
+			execFunc: func(podName string, containerName string, command []string, stdout io.Writer) error { return nil },
+		},
+		logsInterval: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// cancel once the test container is running, the pod is looked up every second
+	time.AfterFunc(1500*time.Millisecond, cancel)
+	err := cmd.run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be cancelled but got %v", err)
+	}
+	if tests[0].Success || !tests[1].Skipped {
+		t.Errorf("expected the hanging test to fail and the next one to be skipped: %+v %+v", tests[0], tests[1])
+	}
+	if _, err = client.BatchV1().Jobs(namespace).Get(context.TODO(), "hanging", metav1.GetOptions{}); !k8serr.IsNotFound(err) {
+		t.Errorf("expected the job of the cancelled test to be deleted: %v", err)
+	}
+	if _, err = client.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{}); !k8serr.IsNotFound(err) {
+		t.Errorf("expected the namespace to be deleted: %v", err)
+	}
+	for _, f := range []string{"hanging/logs/pod.yaml", "hanging/logs/container.log", productTestsSummaryFile} {
+		if !utils.FileExists(path.Join(outputDir, f)) {
+			t.Errorf("expected the partial result %s", f)
+		}
+	}
+}
+
+func TestRun_invalidDependencies(t *testing.T) {
+	cmd := &runTestsCmd{
+		clientset: fake.NewSimpleClientset(),
+		tests:     []*TestContainer{{Name: "test1", DependsOn: []string{"unknown"}}},
+		namespace: "test",
+	}
+	if err := cmd.run(context.TODO()); err == nil {
+		t.Fatal("expected an error for a dependency on an unknown test")
+	}
+}
+
+func TestGetTestContainerJob(t *testing.T) {
+	test := &TestContainer{
+		Name:    "3scale-test",
+		Image:   "3scale-image",
+		Timeout: 3600,
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+		NodeSelector: map[string]string{"node-role.kubernetes.io/worker": ""},
+		Tolerations:  []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "tests", Effect: v1.TaintEffectNoSchedule}},
+		ClusterRole:  "view",
+		Volumes: []TestVolume{
+			{Name: "config", MountPath: "/config", ConfigMap: "test-config"},
+			{Name: "credentials", MountPath: "/credentials", Secret: "test-credentials"},
+		},
+		Sidecars: []v1.Container{{Name: "proxy", Image: "proxy-image"}},
+	}
+	job := getTestContainerJob("test", test)
+	spec := job.Spec.Template.Spec
+
+	if spec.ServiceAccountName != "3scale-test-sa" {
+		t.Errorf("unexpected service account %s", spec.ServiceAccountName)
+	}
+	if !reflect.DeepEqual(spec.NodeSelector, test.NodeSelector) || !reflect.DeepEqual(spec.Tolerations, test.Tolerations) {
+		t.Errorf("unexpected scheduling constraints: %v %v", spec.NodeSelector, spec.Tolerations)
+	}
+	if len(spec.Containers) != 3 || spec.Containers[2].Name != "proxy" {
+		t.Fatalf("expected the test, sidecar and proxy containers but got %v", spec.Containers)
+	}
+	testContainer := spec.Containers[0]
+	if testContainer.Resources.Requests.Memory().String() != "2Gi" {
+		t.Errorf("unexpected resources %v", testContainer.Resources)
+	}
+	if len(spec.Volumes) != 4 || spec.Volumes[2].ConfigMap.Name != "test-config" || spec.Volumes[3].Secret.SecretName != "test-credentials" {
+		t.Errorf("unexpected volumes %v", spec.Volumes)
+	}
+	if len(testContainer.VolumeMounts) != 4 || testContainer.VolumeMounts[3].MountPath != "/credentials" {
+		t.Errorf("unexpected test container volume mounts %v", testContainer.VolumeMounts)
+	}
+	if len(spec.Containers[1].VolumeMounts) != 2 {
+		t.Errorf("expected the volumes to be mounted only in the test container but got %v", spec.Containers[1].VolumeMounts)
+	}
+}
+
+func TestTestServiceAccount(t *testing.T) {
+	cases := []struct {
+		test        *TestContainer
+		expectedSA  string
+		expectedCR  string
+		description string
+	}{
+		{description: "default", test: &TestContainer{Name: "test"}, expectedSA: serviceAccountName, expectedCR: "cluster-admin"},
+		{description: "cluster role", test: &TestContainer{Name: "test", ClusterRole: "view"}, expectedSA: "test-sa", expectedCR: "view"},
+		{description: "service account", test: &TestContainer{Name: "test", ServiceAccount: "existing"}, expectedSA: "existing", expectedCR: ""},
+		{description: "both", test: &TestContainer{Name: "test", ServiceAccount: "tester", ClusterRole: "edit"}, expectedSA: "tester", expectedCR: "edit"},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			sa, cr := testServiceAccount(c.test)
+			if sa != c.expectedSA || cr != c.expectedCR {
+				t.Fatalf("expected %s/%s but got %s/%s", c.expectedSA, c.expectedCR, sa, cr)
+			}
+		})
+	}
+}
+
+func TestValidateTestContainer(t *testing.T) {
+	cases := []struct {
+		description string
+		test        *TestContainer
+		expectErr   bool
+	}{
+		{description: "valid", test: &TestContainer{Volumes: []TestVolume{{Name: "config", MountPath: "/config", ConfigMap: "config"}}, Sidecars: []v1.Container{{Name: "proxy"}}}},
+		{description: "no mount path", test: &TestContainer{Volumes: []TestVolume{{Name: "config", ConfigMap: "config"}}}, expectErr: true},
+		{description: "no source", test: &TestContainer{Volumes: []TestVolume{{Name: "config", MountPath: "/config"}}}, expectErr: true},
+		{description: "two sources", test: &TestContainer{Volumes: []TestVolume{{Name: "config", MountPath: "/config", ConfigMap: "config", Secret: "secret"}}}, expectErr: true},
+		{description: "reserved volume", test: &TestContainer{Volumes: []TestVolume{{Name: "test-run-results", MountPath: "/config", ConfigMap: "config"}}}, expectErr: true},
+		{description: "reserved sidecar", test: &TestContainer{Sidecars: []v1.Container{{Name: "sidecar"}}}, expectErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := validateTestContainer(c.test)
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error: %v, got: %v", c.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateServiceAccounts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// the fake clientset doesn't generate the names
+	generated := 0
+	client.PrependReactor("create", "clusterrolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		crb := action.(k8stesting.CreateAction).GetObject().(*rbacv1.ClusterRoleBinding)
+		generated++
+		crb.Name = fmt.Sprintf("%s%d", crb.GenerateName, generated)
+		return false, nil, nil
+	})
+	cmd := &runTestsCmd{
+		clientset: client,
+		namespace: "test",
+		tests: []*TestContainer{
+			{Name: "admin-1"},
+			{Name: "admin-2"},
+			{Name: "viewer", ClusterRole: "view"},
+			{Name: "custom", ServiceAccount: "custom-sa"},
+		},
+	}
+	if err := cmd.createServiceAccounts(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sas, _ := client.CoreV1().ServiceAccounts("test").List(context.TODO(), metav1.ListOptions{})
+	if len(sas.Items) != 3 {
+		t.Errorf("expected 3 service accounts but got %d", len(sas.Items))
+	}
+	crbs, _ := client.RbacV1().ClusterRoleBindings().List(context.TODO(), metav1.ListOptions{})
+	roles := map[string]string{}
+	for _, crb := range crbs.Items {
+		roles[crb.Subjects[0].Name] = crb.RoleRef.Name
+	}
+	expected := map[string]string{serviceAccountName: "cluster-admin", "viewer-sa": "view"}
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("expected the cluster role bindings %v but got %v", expected, roles)
+	}
+}
@@ -17,15 +17,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/integr8ly/delorean/pkg/services"
 	"github.com/integr8ly/delorean/pkg/utils"
-	routeclientv1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
 	"github.com/prometheus/client_golang/api"
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
-	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 type queryType string
@@ -37,12 +33,27 @@ const (
 	promQueryRangeType  queryType = "query_range"
 	defaultWorkers                = 5
 	defaultQueryTimeout           = 10
+	defaultQueryStep              = 30 * time.Second
+	queryTimeStart                = "start"
+	queryTimeEnd                  = "end"
 )
 
 type queryOpts struct {
 	QueryType queryType `json:"type"`
 	Name      string    `json:"name"`
 	Query     string    `json:"query"`
+	// the values of the result must satisfy the comparison with the threshold, if set
+	Threshold  *float64   `json:"threshold,omitempty"`
+	Comparison comparison `json:"comparison,omitempty"`
+	Severity   severity   `json:"severity,omitempty"`
+	// resolution of query_range queries, like 1m. Defaults to --step
+	Step string `json:"step,omitempty"`
+	// evaluation time of instant queries: start, end or a unix timestamp. Defaults to --query-time
+	Time string `json:"time,omitempty"`
+	// the query is run once for each product, with the product available as {{.Product}} in the name and the query
+	Products []string `json:"products,omitempty"`
+	// the series of a query_range query are annotated as down in Grafana while their values satisfy the condition
+	DownWhen *downCondition `json:"downWhen,omitempty"`
 }
 
 type queryResult struct {
@@ -50,7 +61,7 @@ type queryResult struct {
 	Name   string          `json:"name"`
 	Query  string          `json:"query"`
 	Type   model.ValueType `json:"resultType"`
-	Result json.RawMessage `json:"result`
+	Result json.RawMessage `json:"result"`
 }
 
 func (qr *queryResult) MarshalJSON() ([]byte, error) {
@@ -120,14 +131,103 @@ type queryResults struct {
 }
 
 type queryReportConfig struct {
-	Name    string      `json:"name"`
-	Queries []queryOpts `json:"queries"`
+	// path of a configuration to extend, relative to this configuration. Its queries are run before the ones of
+	// this configuration, and the name, the namespace prefix and the variables of this configuration override its own
+	Base string `json:"base,omitempty"`
+	Name string `json:"name"`
+	// prefix of the product namespaces, which can be overridden with --namespace-prefix. Defaults to redhat-rhoam-
+	NamespacePrefix string `json:"namespacePrefix,omitempty"`
+	// default values of the variables available as {{.Vars.<key>}} in the queries, which can be overridden with --set
+	Vars    map[string]string `json:"vars,omitempty"`
+	Queries []queryOpts       `json:"queries"`
+}
+
+// loadQueryReportConfig reads the configuration from the file, merged with the configurations it extends
+func loadQueryReportConfig(file string) (*queryReportConfig, error) {
+	return loadExtendedQueryReportConfig(file, map[string]bool{})
+}
+
+func loadExtendedQueryReportConfig(file string, loaded map[string]bool) (*queryReportConfig, error) {
+	if loaded[path.Clean(file)] {
+		return nil, fmt.Errorf("the configuration %s extends itself", file)
+	}
+	loaded[path.Clean(file)] = true
+	c := &queryReportConfig{}
+	if err := utils.PopulateObjectFromYAML(file, c); err != nil {
+		return nil, err
+	}
+	if c.Base == "" {
+		return c, nil
+	}
+	base, err := loadExtendedQueryReportConfig(path.Join(path.Dir(file), c.Base), loaded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the base configuration of %s: %w", file, err)
+	}
+	if c.Name != "" {
+		base.Name = c.Name
+	}
+	if c.NamespacePrefix != "" {
+		base.NamespacePrefix = c.NamespacePrefix
+	}
+	for k, v := range c.Vars {
+		if base.Vars == nil {
+			base.Vars = map[string]string{}
+		}
+		base.Vars[k] = v
+	}
+	base.Queries = append(base.Queries, c.Queries...)
+	base.Base = ""
+	return base, nil
+}
+
+// validate checks the options of the queries which are not templated
+func (c *queryReportConfig) validate() error {
+	for _, q := range c.Queries {
+		if q.Step != "" {
+			if _, err := model.ParseDuration(q.Step); err != nil {
+				return fmt.Errorf("query %s: invalid step %s: %w", q.Name, q.Step, err)
+			}
+		}
+		if q.Time != "" && q.Time != queryTimeStart && q.Time != queryTimeEnd {
+			if _, err := strconv.ParseInt(q.Time, 10, 64); err != nil {
+				return fmt.Errorf("query %s: invalid time %s, expected %s, %s or a unix timestamp", q.Name, q.Time, queryTimeStart, queryTimeEnd)
+			}
+		}
+		switch q.Comparison {
+		case comparisonLt, comparisonGt, comparisonEq:
+		case "":
+			if q.Threshold != nil {
+				return fmt.Errorf("query %s: the comparison is required with a threshold, one of %s, %s, %s", q.Name, comparisonLt, comparisonGt, comparisonEq)
+			}
+		default:
+			return fmt.Errorf("query %s: invalid comparison %s, expected one of %s, %s, %s", q.Name, q.Comparison, comparisonLt, comparisonGt, comparisonEq)
+		}
+		switch q.Severity {
+		case "", severityBlocking, severityWarning:
+		default:
+			return fmt.Errorf("query %s: invalid severity %s, expected one of %s, %s", q.Name, q.Severity, severityBlocking, severityWarning)
+		}
+		if q.DownWhen != nil {
+			if q.QueryType != promQueryRangeType {
+				return fmt.Errorf("query %s: downWhen is only supported by %s queries", q.Name, promQueryRangeType)
+			}
+			switch q.DownWhen.Comparison {
+			case comparisonLt, comparisonGt, comparisonEq:
+			default:
+				return fmt.Errorf("query %s: invalid downWhen comparison %s, expected one of %s, %s, %s", q.Name, q.DownWhen.Comparison, comparisonLt, comparisonGt, comparisonEq)
+			}
+		}
+	}
+	return nil
 }
 
 type queryRange struct {
 	start    time.Time
 	end      time.Time
 	duration time.Duration
+	step     time.Duration
+	// evaluation time of the instant queries
+	instant time.Time
 }
 
 type queryReportCmd struct {
@@ -139,6 +239,16 @@ type queryReportCmd struct {
 	version    string
 	bucket     string
 	uploader   s3manageriface.UploaderAPI
+	// namespacePrefix and vars are used to render the templates of the queries
+	namespacePrefix string
+	vars            map[string]string
+	// stops the port forwarding to Prometheus, if any
+	stopPortForward func()
+	// additional formats of the report, and the Grafana instance to push the annotations to
+	formats             []string
+	grafanaURL          string
+	grafanaToken        string
+	grafanaDashboardUID string
 }
 
 type queryReportCmdFlags struct {
@@ -150,8 +260,17 @@ type queryReportCmdFlags struct {
 	start               int64
 	end                 int64
 	duration            time.Duration
+	step                time.Duration
+	queryTime           int64
 	version             string
 	s3bucket            string
+	namespacePrefix     string
+	vars                []string
+	prometheus          prometheusFlags
+	formats             []string
+	grafanaURL          string
+	grafanaToken        string
+	grafanaDashboardUID string
 }
 
 func init() {
@@ -160,9 +279,20 @@ func init() {
 		Use:   "query-report",
 		Short: "Run query against Prometheus on the target RHMI cluster and create reports",
 		Run: func(cmd *cobra.Command, args []string) {
-			kubeConfig, err := requireValue(KubeConfigKey)
-			if err != nil {
-				handleError(err)
+			// the kubeconfig is not needed when the Prometheus URL is given
+			var kubeConfig string
+			if f.prometheus.url == "" {
+				var err error
+				if kubeConfig, err = requireValue(KubeConfigKey); err != nil {
+					handleError(err)
+				}
+			}
+
+			if f.grafanaURL != "" {
+				var err error
+				if f.grafanaToken, err = requireValue(GrafanaTokenKey); err != nil {
+					handleError(err)
+				}
 			}
 
 			var ses *session.Session = nil
@@ -194,16 +324,28 @@ func init() {
 	pipelineCmd.AddCommand(cmd)
 	cmd.Flags().StringVarP(&f.outputDir, "output", "o", "", "Absolute path of the output directory to save reports")
 	cmd.MarkFlagRequired("output")
-	cmd.Flags().StringVarP(&f.namespace, "namespace", "n", prometheusNamespace, "The namespace to find the Prometheus route")
+	cmd.Flags().StringVarP(&f.namespace, "namespace", "n", prometheusNamespace, "The namespace to find the Prometheus route or the port-forward target")
 	cmd.Flags().StringVarP(&f.prometheusRouteName, "route-name", "r", prometheusRouteName, "The Prometheus route name")
+	f.prometheus.addFlags(cmd)
 	cmd.Flags().StringVar(&f.configFile, "config-file", "", "Path to the query configuration file")
 	cmd.MarkFlagRequired("config-file")
 	cmd.Flags().IntVarP(&f.timeout, "timeout", "t", defaultQueryTimeout, "Timeout value for executing Prometheus queries")
 	cmd.Flags().Int64Var(&f.end, "end-time", time.Now().Unix(), "End time for queryRange type of queries. Default to current time")
 	cmd.Flags().Int64Var(&f.start, "start-time", 0, "Start time for queryRange type of queries. Only either start-time or duration should be specified")
 	cmd.Flags().DurationVar(&f.duration, "duration", time.Duration(2*time.Hour), "Duration for queryRange type of queries. Only either start-time or duration should be specified")
+	cmd.Flags().DurationVar(&f.step, "step", defaultQueryStep, "Resolution of query_range type of queries, unless set by the query")
+	cmd.Flags().Int64Var(&f.queryTime, "query-time", 0, "Evaluation time of query type of queries, unless set by the query. Default to the end time")
 	cmd.Flags().StringVarP(&f.version, "version", "v", "", "the RHMI version installed on the cluster")
 
+	cmd.Flags().StringVar(&f.namespacePrefix, "namespace-prefix", "", fmt.Sprintf("Prefix of the product namespaces, available as {{.NamespacePrefix}} in the queries. Defaults to the namespacePrefix of the configuration, or %s", defaultNamespacePrefix))
+	cmd.Flags().StringArrayVar(&f.vars, "set", []string{}, "Set a variable available as {{.Vars.<key>}} in the queries, in the format key=value. Can be repeated")
+
+	cmd.Flags().StringSliceVar(&f.formats, "format", []string{}, fmt.Sprintf("Additional formats of the report: %s", strings.Join(queryResultsFormats(), ", ")))
+	cmd.Flags().StringVar(&f.grafanaURL, "grafana-url", "", "URL of Grafana to push annotations of the report window and of the downtimes to")
+	cmd.Flags().StringVar(&f.grafanaDashboardUID, "grafana-dashboard-uid", defaultGrafanaDashboardUID, "Uid of the Grafana dashboard to annotate. The annotations are global if empty")
+	cmd.Flags().String("grafana-token", "", fmt.Sprintf("The Grafana API token to use. Can be set via the %s env var", strings.ToUpper(GrafanaTokenKey)))
+	viper.BindPFlag(GrafanaTokenKey, cmd.Flags().Lookup("grafana-token"))
+
 	cmd.Flags().StringVarP(&f.s3bucket, "s3-bucket", "b", "", "AWS s3 bucket name")
 
 	cmd.Flags().String("aws-key-id", "", fmt.Sprintf("The AWS key id to use. Can be set via the %s env var", strings.ToUpper(AWSAccessKeyIDEnv)))
@@ -213,30 +355,21 @@ func init() {
 }
 
 func newQueryReportCmd(kubeconfig string, f *queryReportCmdFlags, session *session.Session) (*queryReportCmd, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	promAPI, stopPortForward, err := newQueryReportPromAPI(kubeconfig, f)
 	if err != nil {
 		return nil, err
 	}
-	routeclient, err := routeclientv1.NewForConfig(config)
+	qrConfig, err := loadQueryReportConfig(f.configFile)
 	if err != nil {
 		return nil, err
 	}
-	promRoute, err := routeclient.Routes(f.namespace).Get(context.TODO(), f.prometheusRouteName, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
-	}
-	promUrl := fmt.Sprintf("https://%s", promRoute.Spec.Host)
-	fmt.Println("Prometheus URL:", promUrl)
-	promAPI, err := newPromAPI(promUrl, config.BearerToken)
+
+	queryRange := parseQueryRange(f)
+
+	vars, err := parseQueryVars(f.vars)
 	if err != nil {
 		return nil, err
 	}
-	qrConfig := &queryReportConfig{}
-	if err := utils.PopulateObjectFromYAML(f.configFile, qrConfig); err != nil {
-		return nil, err
-	}
-
-	queryRange := parseQueryRange(f)
 
 	var uploader s3manageriface.UploaderAPI = nil
 	if session != nil {
@@ -252,6 +385,15 @@ func newQueryReportCmd(kubeconfig string, f *queryReportCmdFlags, session *sessi
 		version:    f.version,
 		bucket:     f.s3bucket,
 		uploader:   uploader,
+
+		namespacePrefix: f.namespacePrefix,
+		vars:            vars,
+		stopPortForward: stopPortForward,
+
+		formats:             f.formats,
+		grafanaURL:          f.grafanaURL,
+		grafanaToken:        f.grafanaToken,
+		grafanaDashboardUID: f.grafanaDashboardUID,
 	}, nil
 }
 
@@ -267,28 +409,56 @@ func parseQueryRange(f *queryReportCmdFlags) queryRange {
 		duration = f.duration
 		start = end.Add(-duration)
 	}
+	// instant queries are evaluated at the end of the range by default, so that the report of a past
+	// upgrade window can be generated after the fact
+	instant := end
+	if f.queryTime != 0 {
+		instant = time.Unix(f.queryTime, 0)
+	}
 	queryRange := queryRange{
 		start:    start,
 		end:      end,
 		duration: duration,
+		step:     f.step,
+		instant:  instant,
 	}
 	return queryRange
 }
 
 func (c *queryReportCmd) run(ctx context.Context) error {
-	results, err := c.processQueries(ctx)
+	if c.stopPortForward != nil {
+		defer c.stopPortForward()
+	}
+	if err := c.config.validate(); err != nil {
+		return err
+	}
+	for _, format := range c.formats {
+		if _, ok := queryResultsRenderers[format]; !ok {
+			return fmt.Errorf("unknown format %s, expected one of %s", format, strings.Join(queryResultsFormats(), ", "))
+		}
+	}
+	queries, err := c.renderQueries()
+	if err != nil {
+		return err
+	}
+	results, err := c.processQueries(ctx, queries)
 	if err != nil {
 		return err
 	}
-	fileName := strings.ToLower(c.config.Name)
-	fileName = strings.ReplaceAll(fileName, " ", "-")
-	fileName = fmt.Sprintf("%s-%s.yaml", fileName, time.Now().Format("2006-01-02-03-04-05"))
+	baseName := strings.ToLower(c.config.Name)
+	baseName = strings.ReplaceAll(baseName, " ", "-")
+	baseName = fmt.Sprintf("%s-%s", baseName, time.Now().Format("2006-01-02-03-04-05"))
+	fileName := baseName + ".yaml"
 	outputFile := path.Join(c.outputDir, fileName)
 	r := &queryResults{Name: c.config.Name, Results: results, Version: c.version}
 	if err := utils.WriteObjectToYAML(r, outputFile); err != nil {
 		return err
 	}
 	fmt.Println("Report is generated:", outputFile)
+	// only the YAML report is exported, since it's the one imported by datahub-import
+	if err := c.writeFormats(r, baseName); err != nil {
+		return err
+	}
 
 	if c.uploader != nil {
 		// export the file
@@ -300,12 +470,30 @@ func (c *queryReportCmd) run(ctx context.Context) error {
 		fmt.Println("Exported: " + location)
 	}
 
-	return nil
+	if c.grafanaURL != "" {
+		dashboardID, err := c.grafanaDashboardID(ctx)
+		if err != nil {
+			return err
+		}
+		annotations := r.grafanaAnnotations(queries, dashboardID, c.queryRange.start, c.queryRange.end)
+		if err := c.pushGrafanaAnnotations(ctx, annotations); err != nil {
+			return err
+		}
+	}
+
+	if !c.config.hasThresholds() {
+		return nil
+	}
+	evaluations := evaluateQueries(queries, results)
+	if err := c.writeEvaluations(evaluations, baseName); err != nil {
+		return err
+	}
+	return blockingBreaches(evaluations)
 }
 
-func (c *queryReportCmd) processQueries(ctx context.Context) ([]queryResult, error) {
-	tasks := make([]utils.Task, len(c.config.Queries))
-	for i, q := range c.config.Queries {
+func (c *queryReportCmd) processQueries(ctx context.Context, queries []queryOpts) ([]queryResult, error) {
+	tasks := make([]utils.Task, len(queries))
+	for i, q := range queries {
 		v := q
 		t := func() (utils.TaskResult, error) {
 			r, _, err := c.queryProm(ctx, v)
@@ -333,10 +521,18 @@ func (c *queryReportCmd) queryProm(ctx context.Context, opts queryOpts) (model.V
 	switch opts.QueryType {
 	case promQueryType:
 		query := c.parseQueryRangeQuery(opts.Query)
-		return c.promAPI.Query(ctx, query, time.Now())
+		ts, err := c.queryTime(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.promAPI.Query(ctx, query, ts)
 	case promQueryRangeType:
 		query := c.parseQueryRangeQuery(opts.Query)
-		r := promv1.Range{Start: c.queryRange.start, End: c.queryRange.end, Step: 30 * time.Second}
+		step, err := c.queryStep(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		r := promv1.Range{Start: c.queryRange.start, End: c.queryRange.end, Step: step}
 		return c.promAPI.QueryRange(ctx, query, r)
 	default:
 		return nil, nil, fmt.Errorf("unsupported query type: %s", opts.QueryType)
@@ -349,12 +545,38 @@ func (c *queryReportCmd) parseQueryRangeQuery(query string) string {
 	return q
 }
 
-func newPromAPI(url string, token string) (promv1.API, error) {
-	rt := config.NewAuthorizationCredentialsRoundTripper("Bearer", config.Secret(token), api.DefaultRoundTripper)
-	client, err := api.NewClient(api.Config{Address: url, RoundTripper: rt})
-	if err != nil {
-		return nil, err
+// queryTime returns the evaluation time of an instant query
+func (c *queryReportCmd) queryTime(opts queryOpts) (time.Time, error) {
+	switch opts.Time {
+	case "":
+		if c.queryRange.instant.IsZero() {
+			return c.queryRange.end, nil
+		}
+		return c.queryRange.instant, nil
+	case queryTimeStart:
+		return c.queryRange.start, nil
+	case queryTimeEnd:
+		return c.queryRange.end, nil
+	default:
+		ts, err := strconv.ParseInt(opts.Time, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %s of the query %s, expected %s, %s or a unix timestamp", opts.Time, opts.Name, queryTimeStart, queryTimeEnd)
+		}
+		return time.Unix(ts, 0), nil
+	}
+}
+
+// queryStep returns the resolution of a range query
+func (c *queryReportCmd) queryStep(opts queryOpts) (time.Duration, error) {
+	if opts.Step != "" {
+		step, err := model.ParseDuration(opts.Step)
+		if err != nil {
+			return 0, fmt.Errorf("invalid step %s of the query %s: %w", opts.Step, opts.Name, err)
+		}
+		return time.Duration(step), nil
+	}
+	if c.queryRange.step == 0 {
+		return defaultQueryStep, nil
 	}
-	promAPI := promv1.NewAPI(client)
-	return promAPI, nil
+	return c.queryRange.step, nil
 }
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// uid of the dashboard in templates/grafana/productDowntimes.json
+	defaultGrafanaDashboardUID = "rhmi-product-downtime"
+	// tag of all the annotations pushed by the report, shown by the dashboard
+	grafanaDowntimeTag = "rhmi-downtime"
+)
+
+// downCondition is the condition of the values of an availability query while the service is down, like
+// kube_endpoint_address_available lt 1
+type downCondition struct {
+	Comparison comparison `json:"comparison"`
+	Value      float64    `json:"value"`
+}
+
+func (d *downCondition) isDown(value float64) bool {
+	return d.Comparison.compare(value, d.Value)
+}
+
+// grafanaAnnotation is a region annotation of the Grafana HTTP API
+type grafanaAnnotation struct {
+	DashboardID int      `json:"dashboardId,omitempty"`
+	Time        int64    `json:"time"`
+	TimeEnd     int64    `json:"timeEnd"`
+	IsRegion    bool     `json:"isRegion"`
+	Tags        []string `json:"tags"`
+	Text        string   `json:"text"`
+}
+
+// grafanaAnnotations returns an annotation for the window of the report, like an upgrade, and one for each
+// downtime interval of the range query results of the queries with a down condition. The results must be in
+// the same order as the queries
+func (r *queryResults) grafanaAnnotations(queries []queryOpts, dashboardID int, start time.Time, end time.Time) []grafanaAnnotation {
+	tags := func(tags ...string) []string {
+		tags = append([]string{grafanaDowntimeTag}, tags...)
+		if r.Version != "" {
+			tags = append(tags, r.Version)
+		}
+		return tags
+	}
+	window := fmt.Sprintf("%s window", r.Name)
+	if r.Version != "" {
+		window = fmt.Sprintf("%s window of version %s", r.Name, r.Version)
+	}
+	annotations := []grafanaAnnotation{{
+		DashboardID: dashboardID,
+		Time:        start.UnixNano() / int64(time.Millisecond),
+		TimeEnd:     end.UnixNano() / int64(time.Millisecond),
+		IsRegion:    true,
+		Tags:        tags("upgrade"),
+		Text:        window,
+	}}
+
+	for i, q := range queries {
+		if q.DownWhen == nil {
+			continue
+		}
+		qr := r.Results[i]
+		matrix, ok := qr.v.(model.Matrix)
+		if !ok {
+			continue
+		}
+		for _, s := range matrix {
+			for _, interval := range downIntervals(s.Values, q.DownWhen) {
+				annotations = append(annotations, grafanaAnnotation{
+					DashboardID: dashboardID,
+					Time:        int64(interval[0]),
+					TimeEnd:     int64(interval[1]),
+					IsRegion:    true,
+					Tags:        tags("downtime", strings.Split(qr.Name, "_")[0]),
+					Text:        strings.TrimSpace(fmt.Sprintf("%s down %s", qr.Name, sampleMetric(s.Metric))),
+				})
+			}
+		}
+	}
+	return annotations
+}
+
+// downIntervals returns the start and the end of each sequence of points that satisfy the down condition.
+// The interval ends with the first point that doesn't, or with the last point
+func downIntervals(points []model.SamplePair, condition *downCondition) [][2]model.Time {
+	var intervals [][2]model.Time
+	var down bool
+	var start model.Time
+	for _, p := range points {
+		isDown := condition.isDown(float64(p.Value))
+		if isDown && !down {
+			down = true
+			start = p.Timestamp
+		} else if !isDown && down {
+			down = false
+			intervals = append(intervals, [2]model.Time{start, p.Timestamp})
+		}
+	}
+	if down {
+		intervals = append(intervals, [2]model.Time{start, points[len(points)-1].Timestamp})
+	}
+	return intervals
+}
+
+// grafanaDashboardID looks up the id of the dashboard with the uid, which is needed by the annotations API.
+// The id is 0 if no uid is set, which makes the annotations global
+func (c *queryReportCmd) grafanaDashboardID(ctx context.Context) (int, error) {
+	if c.grafanaDashboardUID == "" {
+		return 0, nil
+	}
+	body, err := c.grafanaRequest(ctx, http.MethodGet, "/api/dashboards/uid/"+url.PathEscape(c.grafanaDashboardUID), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the dashboard %s: %w", c.grafanaDashboardUID, err)
+	}
+	d := struct {
+		Dashboard struct {
+			ID int `json:"id"`
+		} `json:"dashboard"`
+	}{}
+	if err := json.Unmarshal(body, &d); err != nil {
+		return 0, fmt.Errorf("failed to get the dashboard %s: %w", c.grafanaDashboardUID, err)
+	}
+	return d.Dashboard.ID, nil
+}
+
+// pushGrafanaAnnotations creates the annotations with the Grafana HTTP API
+func (c *queryReportCmd) pushGrafanaAnnotations(ctx context.Context, annotations []grafanaAnnotation) error {
+	for _, a := range annotations {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := c.grafanaRequest(ctx, http.MethodPost, "/api/annotations", bytes.NewReader(b)); err != nil {
+			return fmt.Errorf("failed to create the annotation %q in Grafana: %w", a.Text, err)
+		}
+	}
+	fmt.Println(fmt.Sprintf("Pushed %d annotations to Grafana at %s", len(annotations), c.grafanaURL))
+	return nil
+}
+
+// grafanaRequest sends a request with a JSON body, if any, to the Grafana HTTP API and returns the response body
+func (c *queryReportCmd) grafanaRequest(ctx context.Context, method string, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.grafanaURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.grafanaToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.grafanaToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	routeclientv1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/config"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// prometheusFlags are the options to connect to Prometheus, or to any API compatible with Prometheus like Thanos
+type prometheusFlags struct {
+	url                   string
+	portForward           string
+	bearerTokenFile       string
+	basicAuthUsername     string
+	basicAuthPasswordFile string
+	caFile                string
+	certFile              string
+	keyFile               string
+	insecureSkipTLSVerify bool
+}
+
+func (p *prometheusFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.url, "prometheus-url", "", "URL of Prometheus, or of a compatible API like Thanos. The Prometheus route is used if not set")
+	cmd.Flags().StringVar(&p.portForward, "port-forward", "", "Query Prometheus through a port forwarded to a pod or a service in the namespace, in the format <pod|svc>/<name>:<port>. The port is queried with HTTPS if a TLS option is set")
+	cmd.Flags().StringVar(&p.bearerTokenFile, "bearer-token-file", "", "File with the bearer token to authenticate to Prometheus. The token of the kubeconfig is used with the Prometheus route if no other authentication is set")
+	cmd.Flags().StringVar(&p.basicAuthUsername, "basic-auth-username", "", "Username to authenticate to Prometheus with basic auth")
+	cmd.Flags().StringVar(&p.basicAuthPasswordFile, "basic-auth-password-file", "", "File with the password to authenticate to Prometheus with basic auth")
+	cmd.Flags().StringVar(&p.caFile, "ca-file", "", "CA certificate to verify the certificate of Prometheus")
+	cmd.Flags().StringVar(&p.certFile, "cert-file", "", "Client certificate to authenticate to Prometheus with mTLS")
+	cmd.Flags().StringVar(&p.keyFile, "key-file", "", "Client key to authenticate to Prometheus with mTLS")
+	cmd.Flags().BoolVar(&p.insecureSkipTLSVerify, "insecure-skip-tls-verify", false, "Don't verify the certificate of Prometheus")
+}
+
+func (p *prometheusFlags) hasAuth() bool {
+	return p.bearerTokenFile != "" || p.basicAuthUsername != "" || p.certFile != ""
+}
+
+func (p *prometheusFlags) hasTLS() bool {
+	return p.caFile != "" || p.certFile != "" || p.insecureSkipTLSVerify
+}
+
+func (p *prometheusFlags) httpClientConfig() config.HTTPClientConfig {
+	c := config.HTTPClientConfig{
+		BearerTokenFile: p.bearerTokenFile,
+		TLSConfig: config.TLSConfig{
+			CAFile:             p.caFile,
+			CertFile:           p.certFile,
+			KeyFile:            p.keyFile,
+			InsecureSkipVerify: p.insecureSkipTLSVerify,
+		},
+		FollowRedirects: true,
+	}
+	if p.basicAuthUsername != "" {
+		c.BasicAuth = &config.BasicAuth{Username: p.basicAuthUsername, PasswordFile: p.basicAuthPasswordFile}
+	}
+	return c
+}
+
+// newQueryReportPromAPI connects to Prometheus with the URL, through a port forwarded to the cluster, or
+// through the Prometheus route, in this order. It also returns a function to stop the port forwarding if any
+func newQueryReportPromAPI(kubeconfig string, f *queryReportCmdFlags) (promv1.API, func(), error) {
+	p := f.prometheus
+	if p.url != "" && p.portForward != "" {
+		return nil, nil, fmt.Errorf("only one of --prometheus-url and --port-forward can be set")
+	}
+	httpConfig := p.httpClientConfig()
+	if p.url != "" {
+		fmt.Println("Prometheus URL:", p.url)
+		promAPI, err := newPromAPI(p.url, httpConfig)
+		return promAPI, nil, err
+	}
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.portForward != "" {
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		pod, port, err := utils.ResolvePortForwardTarget(context.TODO(), clientset, f.namespace, p.portForward)
+		if err != nil {
+			return nil, nil, err
+		}
+		localPort, stop, err := utils.PortForward(restConfig, clientset, f.namespace, pod, port)
+		if err != nil {
+			return nil, nil, err
+		}
+		scheme := "http"
+		if p.hasTLS() {
+			scheme = "https"
+		}
+		promUrl := fmt.Sprintf("%s://localhost:%d", scheme, localPort)
+		fmt.Println(fmt.Sprintf("Prometheus URL: %s (forwarded to %s/%s:%d)", promUrl, f.namespace, pod, port))
+		promAPI, err := newPromAPI(promUrl, httpConfig)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		return promAPI, stop, nil
+	}
+
+	routeclient, err := routeclientv1.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	promRoute, err := routeclient.Routes(f.namespace).Get(context.TODO(), f.prometheusRouteName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	promUrl := fmt.Sprintf("https://%s", promRoute.Spec.Host)
+	fmt.Println("Prometheus URL:", promUrl)
+	if !p.hasAuth() {
+		httpConfig.BearerToken = config.Secret(restConfig.BearerToken)
+	}
+	promAPI, err := newPromAPI(promUrl, httpConfig)
+	return promAPI, nil, err
+}
+
+func newPromAPI(promURL string, httpConfig config.HTTPClientConfig) (promv1.API, error) {
+	if err := httpConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if err := setEnvironmentProxy(&httpConfig, promURL, http.ProxyFromEnvironment); err != nil {
+		return nil, err
+	}
+	rt, err := config.NewRoundTripperFromConfig(httpConfig, "delorean")
+	if err != nil {
+		return nil, err
+	}
+	client, err := api.NewClient(api.Config{Address: promURL, RoundTripper: rt})
+	if err != nil {
+		return nil, err
+	}
+	promAPI := promv1.NewAPI(client)
+	return promAPI, nil
+}
+
+// setEnvironmentProxy sets the proxy of the environment for the URL, like HTTPS_PROXY unless the host is in NO_PROXY,
+// if the configuration has no proxy. The round tripper of the configuration only uses the proxy of the configuration
+func setEnvironmentProxy(httpConfig *config.HTTPClientConfig, promURL string, proxy func(*http.Request) (*url.URL, error)) error {
+	if httpConfig.ProxyURL.URL != nil {
+		return nil
+	}
+	u, err := url.Parse(promURL)
+	if err != nil {
+		return err
+	}
+	proxyURL, err := proxy(&http.Request{URL: u})
+	if err != nil {
+		return fmt.Errorf("invalid proxy for %s: %w", promURL, err)
+	}
+	httpConfig.ProxyURL = config.URL{URL: proxyURL}
+	return nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+)
+
+// newTestPrometheus returns a stand-in for the query API of Prometheus which checks the authorization header
+func newTestPrometheus(t *testing.T, authorized func(r *http.Request) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1600000000,"42"]}}`))
+	}))
+}
+
+func TestNewQueryReportPromAPI(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := path.Join(dir, "token")
+	passwordFile := path.Join(dir, "password")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(passwordFile, []byte("secret-password"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		description string
+		flags       prometheusFlags
+		authorized  func(r *http.Request) bool
+		expectErr   bool
+	}{
+		{
+			description: "no authentication",
+			authorized:  func(r *http.Request) bool { return r.Header.Get("Authorization") == "" },
+		},
+		{
+			description: "bearer token file",
+			flags:       prometheusFlags{bearerTokenFile: tokenFile},
+			authorized:  func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer secret-token" },
+		},
+		{
+			description: "basic auth",
+			flags:       prometheusFlags{basicAuthUsername: "admin", basicAuthPasswordFile: passwordFile},
+			authorized: func(r *http.Request) bool {
+				username, password, ok := r.BasicAuth()
+				return ok && username == "admin" && password == "secret-password"
+			},
+		},
+		{
+			description: "bearer token and basic auth can't be used together",
+			flags:       prometheusFlags{bearerTokenFile: tokenFile, basicAuthUsername: "admin"},
+			expectErr:   true,
+		},
+		{
+			description: "url and port forwarding can't be used together",
+			flags:       prometheusFlags{portForward: "svc/prometheus:9090"},
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			authorized := c.authorized
+			if authorized == nil {
+				authorized = func(r *http.Request) bool { return true }
+			}
+			server := newTestPrometheus(t, authorized)
+			defer server.Close()
+
+			f := &queryReportCmdFlags{prometheus: c.flags}
+			f.prometheus.url = server.URL
+			promAPI, stop, err := newQueryReportPromAPI("", f)
+			if c.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stop != nil {
+				t.Errorf("expected no port forwarding")
+			}
+			v, _, err := promAPI.Query(context.TODO(), "up", time.Now())
+			if err != nil {
+				t.Fatalf("failed to query the stand-in Prometheus: %v", err)
+			}
+			if s, ok := v.(*model.Scalar); !ok || s.Value != 42 {
+				t.Fatalf("unexpected result: %v", v)
+			}
+		})
+	}
+}
+
+func TestSetEnvironmentProxy(t *testing.T) {
+	// the stand-in Prometheus is only reachable through the proxy, which receives the absolute URLs
+	prometheus := newTestPrometheus(t, func(r *http.Request) bool { return r.Host == "prometheus.example.com" })
+	defer prometheus.Close()
+	proxyURL, _ := url.Parse(prometheus.URL)
+	var proxied []string
+	proxy := func(r *http.Request) (*url.URL, error) {
+		proxied = append(proxied, r.URL.Host)
+		if r.URL.Host == "localhost:9090" {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+
+	httpConfig := config.HTTPClientConfig{}
+	if err := setEnvironmentProxy(&httpConfig, "http://prometheus.example.com", proxy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setEnvironmentProxy(&httpConfig, "http://prometheus.example.com", proxy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(proxied) != 1 {
+		t.Errorf("expected the proxy of the configuration to be kept but got %v", proxied)
+	}
+	promAPI, err := newPromAPI("http://prometheus.example.com", httpConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := promAPI.Query(context.TODO(), "up", time.Now()); err != nil {
+		t.Fatalf("failed to query Prometheus through the proxy: %v", err)
+	}
+
+	noProxy := config.HTTPClientConfig{}
+	if err := setEnvironmentProxy(&noProxy, "http://localhost:9090", proxy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if noProxy.ProxyURL.URL != nil {
+		t.Errorf("expected no proxy but got %s", noProxy.ProxyURL.URL)
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// queryResultsRenderer writes the results in a format other than YAML to a file with the extension
+type queryResultsRenderer struct {
+	extension string
+	render    func(r *queryResults, w io.Writer) error
+}
+
+var queryResultsRenderers = map[string]queryResultsRenderer{
+	"csv":      {extension: ".csv", render: (*queryResults).writeCSV},
+	"markdown": {extension: ".md", render: (*queryResults).writeMarkdown},
+	"jsonl":    {extension: ".jsonl", render: (*queryResults).writeJSONLines},
+}
+
+func queryResultsFormats() []string {
+	var formats []string
+	for f := range queryResultsRenderers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// queryResultRow is a single value of a query result
+type queryResultRow struct {
+	Name      string       `json:"name"`
+	Query     string       `json:"query"`
+	Metric    model.Metric `json:"metric"`
+	Timestamp time.Time    `json:"timestamp"`
+	Value     string       `json:"value"`
+}
+
+// rows flattens the results into one row per value: the scalars and the strings have one value, the vectors
+// one value per sample and the matrices one value per point of each series
+func (r *queryResults) rows() []queryResultRow {
+	var rows []queryResultRow
+	for _, qr := range r.Results {
+		newRow := func(metric model.Metric, ts model.Time, value string) queryResultRow {
+			if metric == nil {
+				metric = model.Metric{}
+			}
+			return queryResultRow{Name: qr.Name, Query: qr.Query, Metric: metric, Timestamp: ts.Time().UTC(), Value: value}
+		}
+		switch v := qr.v.(type) {
+		case *model.Scalar:
+			rows = append(rows, newRow(nil, v.Timestamp, v.Value.String()))
+		case *model.String:
+			rows = append(rows, newRow(nil, v.Timestamp, v.Value))
+		case model.Vector:
+			for _, s := range v {
+				rows = append(rows, newRow(s.Metric, s.Timestamp, s.Value.String()))
+			}
+		case model.Matrix:
+			for _, s := range v {
+				for _, p := range s.Values {
+					rows = append(rows, newRow(s.Metric, p.Timestamp, p.Value.String()))
+				}
+			}
+		}
+	}
+	return rows
+}
+
+func (r *queryResults) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"name", "metric", "timestamp", "value"}); err != nil {
+		return err
+	}
+	for _, row := range r.rows() {
+		if err := writer.Write([]string{row.Name, row.Metric.String(), row.Timestamp.Format(time.RFC3339), row.Value}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *queryResults) writeMarkdown(w io.Writer) error {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	fmt.Fprintf(w, "# %s\n\n", r.Name)
+	if r.Version != "" {
+		fmt.Fprintf(w, "Version: %s\n\n", r.Version)
+	}
+	fmt.Fprintln(w, "| Name | Metric | Timestamp | Value |")
+	fmt.Fprintln(w, "| --- | --- | --- | ---: |")
+	for _, row := range r.rows() {
+		_, err := fmt.Fprintf(w, "| %s | %s | %s | %s |\n", escape.Replace(row.Name), escape.Replace(row.Metric.String()), row.Timestamp.Format(time.RFC3339), escape.Replace(row.Value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSONLines writes one JSON object per value, with the name and the version of the report
+func (r *queryResults) writeJSONLines(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range r.rows() {
+		line := struct {
+			Report  string `json:"report"`
+			Version string `json:"version,omitempty"`
+			queryResultRow
+		}{Report: r.Name, Version: r.Version, queryResultRow: row}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFormats writes the results in each of the formats, next to the YAML report
+func (c *queryReportCmd) writeFormats(r *queryResults, baseName string) error {
+	for _, format := range c.formats {
+		renderer := queryResultsRenderers[format]
+		file := path.Join(c.outputDir, baseName+renderer.extension)
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		err = renderer.render(r, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write the %s report: %w", format, err)
+		}
+		fmt.Println("Report is generated:", file)
+	}
+	return nil
+}
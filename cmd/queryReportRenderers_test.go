@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func newTestRendererResults() *queryResults {
+	return &queryResults{
+		Name:    "Downtime Report",
+		Version: "1.2.0",
+		Results: []queryResult{
+			{
+				Name:  "threescale_downtime",
+				Query: "kube_endpoint_address_available",
+				v: model.Matrix{{
+					Metric: model.Metric{"endpoint": "apicast|production"},
+					Values: []model.SamplePair{
+						{Timestamp: 1600000000000, Value: 1},
+						{Timestamp: 1600000030000, Value: 0},
+						{Timestamp: 1600000060000, Value: 0},
+						{Timestamp: 1600000090000, Value: 1},
+						{Timestamp: 1600000120000, Value: 0},
+					},
+				}},
+			},
+			{
+				Name:  "total_downtime",
+				Query: "sum(downtime)",
+				v:     &model.Scalar{Timestamp: 1600000120000, Value: 60},
+			},
+		},
+	}
+}
+
+func TestQueryResultsRenderers(t *testing.T) {
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "csv",
+			expected: `name,metric,timestamp,value
+threescale_downtime,"{endpoint=""apicast|production""}",2020-09-13T12:26:40Z,1
+threescale_downtime,"{endpoint=""apicast|production""}",2020-09-13T12:27:10Z,0
+threescale_downtime,"{endpoint=""apicast|production""}",2020-09-13T12:27:40Z,0
+threescale_downtime,"{endpoint=""apicast|production""}",2020-09-13T12:28:10Z,1
+threescale_downtime,"{endpoint=""apicast|production""}",2020-09-13T12:28:40Z,0
+total_downtime,{},2020-09-13T12:28:40Z,60
+`,
+		},
+		{
+			format: "markdown",
+			expected: `# Downtime Report
+
+Version: 1.2.0
+
+| Name | Metric | Timestamp | Value |
+| --- | --- | --- | ---: |
+| threescale_downtime | {endpoint="apicast\|production"} | 2020-09-13T12:26:40Z | 1 |
+| threescale_downtime | {endpoint="apicast\|production"} | 2020-09-13T12:27:10Z | 0 |
+| threescale_downtime | {endpoint="apicast\|production"} | 2020-09-13T12:27:40Z | 0 |
+| threescale_downtime | {endpoint="apicast\|production"} | 2020-09-13T12:28:10Z | 1 |
+| threescale_downtime | {endpoint="apicast\|production"} | 2020-09-13T12:28:40Z | 0 |
+| total_downtime | {} | 2020-09-13T12:28:40Z | 60 |
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := queryResultsRenderers[c.format].render(newTestRendererResults(), &b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.String() != c.expected {
+				t.Fatalf("unexpected output:\n%s", b.String())
+			}
+		})
+	}
+
+	t.Run("jsonl", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := queryResultsRenderers["jsonl"].render(newTestRendererResults(), &b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if len(lines) != 6 {
+			t.Fatalf("expected 6 lines but got %d", len(lines))
+		}
+		var last map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[5]), &last); err != nil {
+			t.Fatalf("invalid line %s: %v", lines[5], err)
+		}
+		expected := map[string]interface{}{
+			"report":    "Downtime Report",
+			"version":   "1.2.0",
+			"name":      "total_downtime",
+			"query":     "sum(downtime)",
+			"metric":    map[string]interface{}{},
+			"timestamp": "2020-09-13T12:28:40Z",
+			"value":     "60",
+		}
+		if !reflect.DeepEqual(last, expected) {
+			t.Fatalf("unexpected line %v", last)
+		}
+	})
+}
+
+func TestPushGrafanaAnnotations(t *testing.T) {
+	var lock sync.Mutex
+	var annotations []grafanaAnnotation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/dashboards/uid/product-downtime":
+			w.Write([]byte(`{"dashboard":{"id":42,"uid":"product-downtime"}}`))
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/annotations":
+			a := grafanaAnnotation{}
+			if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+				t.Errorf("invalid annotation: %v", err)
+			}
+			lock.Lock()
+			annotations = append(annotations, a)
+			lock.Unlock()
+			w.Write([]byte(`{"message":"Annotation added"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	start := time.Unix(1600000000, 0)
+	end := time.Unix(1600000120, 0)
+	c := &queryReportCmd{grafanaURL: server.URL + "/", grafanaToken: "secret-token", grafanaDashboardUID: "product-downtime"}
+	dashboardID, err := c.grafanaDashboardID(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dashboardID != 42 {
+		t.Fatalf("expected the dashboard id 42 but got %d", dashboardID)
+	}
+
+	// the values of the downtime seconds are 0 while the service is up, so the query has no down condition
+	r := newTestRendererResults()
+	r.Results = append(r.Results, queryResult{
+		Name:  "threescale_downtime_seconds",
+		Query: "downtime_seconds",
+		v: model.Matrix{{
+			Metric: model.Metric{"endpoint": "zync"},
+			Values: []model.SamplePair{{Timestamp: 1600000000000, Value: 0}, {Timestamp: 1600000060000, Value: 0}},
+		}},
+	})
+	queries := []queryOpts{
+		{QueryType: promQueryRangeType, Name: "threescale_downtime", DownWhen: &downCondition{Comparison: comparisonLt, Value: 1}},
+		{QueryType: promQueryType, Name: "total_downtime"},
+		{QueryType: promQueryRangeType, Name: "threescale_downtime_seconds"},
+	}
+	if err := c.pushGrafanaAnnotations(context.TODO(), r.grafanaAnnotations(queries, dashboardID, start, end)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []grafanaAnnotation{
+		{DashboardID: 42, Time: 1600000000000, TimeEnd: 1600000120000, IsRegion: true, Tags: []string{"rhmi-downtime", "upgrade", "1.2.0"}, Text: "Downtime Report window of version 1.2.0"},
+		{DashboardID: 42, Time: 1600000030000, TimeEnd: 1600000090000, IsRegion: true, Tags: []string{"rhmi-downtime", "downtime", "threescale", "1.2.0"}, Text: `threescale_downtime down {endpoint="apicast|production"}`},
+		{DashboardID: 42, Time: 1600000120000, TimeEnd: 1600000120000, IsRegion: true, Tags: []string{"rhmi-downtime", "downtime", "threescale", "1.2.0"}, Text: `threescale_downtime down {endpoint="apicast|production"}`},
+	}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Fatalf("unexpected annotations %+v", annotations)
+	}
+
+	c.grafanaDashboardUID = "missing"
+	if _, err := c.grafanaDashboardID(context.TODO()); err == nil {
+		t.Fatal("expected an error for a missing dashboard")
+	}
+	c.grafanaDashboardUID = ""
+	if id, err := c.grafanaDashboardID(context.TODO()); err != nil || id != 0 {
+		t.Fatalf("expected global annotations without a dashboard uid but got %d, %v", id, err)
+	}
+	c.grafanaToken = "wrong-token"
+	if err := c.pushGrafanaAnnotations(context.TODO(), expected[:1]); err == nil {
+		t.Fatal("expected an error for an unauthorized request")
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultNamespacePrefix = "redhat-rhoam-"
+
+// queryTemplateData is the data available in the templates of the names and the queries
+type queryTemplateData struct {
+	NamespacePrefix string
+	Version         string
+	Start           time.Time
+	End             time.Time
+	// Range is the range of the report in milliseconds, like $range
+	Range int64
+	// Duration is the range of the report in seconds, like $duration
+	Duration string
+	Product  string
+	Vars     map[string]string
+}
+
+// parseQueryVars parses the variables in the format key=value
+func parseQueryVars(vars []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, v := range vars {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid variable %s, expected the format key=value", v)
+		}
+		parsed[kv[0]] = kv[1]
+	}
+	return parsed, nil
+}
+
+// renderQueries expands the queries over their products and renders the templates of their names and queries.
+// The queries rendered to an empty string are skipped, so that a query can be enabled with a condition
+func (c *queryReportCmd) renderQueries() ([]queryOpts, error) {
+	vars := map[string]string{}
+	for k, v := range c.config.Vars {
+		vars[k] = v
+	}
+	for k, v := range c.vars {
+		vars[k] = v
+	}
+	namespacePrefix := c.namespacePrefix
+	if namespacePrefix == "" {
+		namespacePrefix = c.config.NamespacePrefix
+	}
+	if namespacePrefix == "" {
+		namespacePrefix = defaultNamespacePrefix
+	}
+	data := queryTemplateData{
+		NamespacePrefix: namespacePrefix,
+		Version:         c.version,
+		Start:           c.queryRange.start,
+		End:             c.queryRange.end,
+		Range:           c.queryRange.duration.Milliseconds(),
+		Duration:        fmt.Sprintf("%ds", int64(math.Round(c.queryRange.duration.Seconds()))),
+		Vars:            vars,
+	}
+
+	var queries []queryOpts
+	for _, q := range c.config.Queries {
+		products := q.Products
+		if len(products) == 0 {
+			products = []string{""}
+		}
+		for _, product := range products {
+			data.Product = product
+			rendered := q
+			rendered.Products = nil
+			var err error
+			if rendered.Name, err = renderQueryTemplate(q.Name, data); err != nil {
+				return nil, fmt.Errorf("failed to render the name of the query %s: %w", q.Name, err)
+			}
+			if rendered.Query, err = renderQueryTemplate(q.Query, data); err != nil {
+				return nil, fmt.Errorf("failed to render the query %s: %w", q.Name, err)
+			}
+			if strings.TrimSpace(rendered.Query) == "" {
+				fmt.Println(fmt.Sprintf("[%s] Skipping the query, rendered to an empty query", rendered.Name))
+				continue
+			}
+			queries = append(queries, rendered)
+		}
+	}
+	return queries, nil
+}
+
+// queryTemplateFuncs are the functions available in the templates of the names and the queries
+var queryTemplateFuncs = template.FuncMap{
+	"replace": strings.ReplaceAll,
+}
+
+func renderQueryTemplate(text string, data queryTemplateData) (string, error) {
+	t, err := template.New("query").Funcs(queryTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	out := &bytes.Buffer{}
+	if err = t.Execute(out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
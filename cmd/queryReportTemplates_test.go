@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderQueries(t *testing.T) {
+	end := time.Unix(1600000000, 0)
+	cmd := &queryReportCmd{
+		version:         "1.2.0",
+		namespacePrefix: "redhat-rhmi-",
+		queryRange:      queryRange{start: end.Add(-time.Hour), end: end, duration: time.Hour},
+		vars:            map[string]string{"job": "blackbox"},
+		config: &queryReportConfig{
+			Name: "Test Report",
+			Vars: map[string]string{"job": "kube-state-metrics", "interval": "30s"},
+			Queries: []queryOpts{
+				{
+					QueryType: promQueryType,
+					Name:      "{{.Product}}_downtime_seconds",
+					Query:     "downtime{namespace='{{.NamespacePrefix}}{{.Product}}', job='{{.Vars.job}}'}[{{.Duration}}:{{.Vars.interval}}]",
+					Products:  []string{"3scale", "rhsso"},
+				},
+				{
+					QueryType: promQueryType,
+					Name:      "version",
+					Query:     "rhmi_version{version='{{.Version}}'} @ {{.End.Unix}} / {{.Range}}",
+				},
+				{
+					QueryType: promQueryType,
+					Name:      "amq_downtime_seconds",
+					Query:     "{{if eq .NamespacePrefix \"redhat-rhoam-\"}}amq_downtime{{end}}",
+				},
+				{
+					QueryType: promQueryType,
+					Name:      "legacy",
+					Query:     "$range - avg_over_time(up[$duration:30s])",
+				},
+			},
+		},
+	}
+
+	queries, err := cmd.renderQueries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []queryOpts{
+		{QueryType: promQueryType, Name: "3scale_downtime_seconds", Query: "downtime{namespace='redhat-rhmi-3scale', job='blackbox'}[3600s:30s]"},
+		{QueryType: promQueryType, Name: "rhsso_downtime_seconds", Query: "downtime{namespace='redhat-rhmi-rhsso', job='blackbox'}[3600s:30s]"},
+		{QueryType: promQueryType, Name: "version", Query: "rhmi_version{version='1.2.0'} @ 1600000000 / 3600000"},
+		{QueryType: promQueryType, Name: "legacy", Query: "$range - avg_over_time(up[$duration:30s])"},
+	}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, queries)
+	}
+
+	cmd.config.Queries = []queryOpts{{Name: "missing", Query: "up{job='{{.Vars.missing}}'}"}}
+	if _, err = cmd.renderQueries(); err == nil {
+		t.Fatal("expected an error for a missing variable")
+	}
+}
+
+func TestRenderQueries_configurations(t *testing.T) {
+	// the RHOAM configuration runs the queries of the RHMI one, which has more products, on the RHOAM namespaces
+	cases := []struct {
+		file            string
+		namespacePrefix string
+		expectedQueries int
+		expectedQuery   string
+	}{
+		{
+			file:            "../configurations/downtime-report-config.yaml",
+			namespacePrefix: "redhat-rhmi-",
+			expectedQueries: 44,
+			expectedQuery:   "syndesis_ui_k8s_endpoint_downtime_seconds",
+		},
+		{
+			file:            "../configurations/downtime-report-config-rhoam.yaml",
+			namespacePrefix: "redhat-rhoam-",
+			expectedQueries: 20,
+			expectedQuery:   "rhsso_k8s_endpoint_downtime_seconds",
+		},
+	}
+	for _, c := range cases {
+		config, err := loadQueryReportConfig(c.file)
+		if err != nil {
+			t.Fatalf("failed to load the configuration %s: %v", c.file, err)
+		}
+		if err := config.validate(); err != nil {
+			t.Fatalf("invalid configuration %s: %v", c.file, err)
+		}
+		cmd := &queryReportCmd{config: config, queryRange: queryRange{duration: time.Hour}}
+		queries, err := cmd.renderQueries()
+		if err != nil {
+			t.Fatalf("failed to render the queries of %s: %v", c.file, err)
+		}
+		if len(queries) != c.expectedQueries {
+			t.Errorf("expected %d queries for %s but got %d", c.expectedQueries, c.file, len(queries))
+		}
+		found := false
+		for _, q := range queries {
+			if strings.Contains(q.Name+q.Query, "{{") {
+				t.Errorf("query %s is not rendered: %s", q.Name, q.Query)
+			}
+			if strings.Contains(q.Query, "namespace=") && !strings.Contains(q.Query, "namespace='"+c.namespacePrefix) {
+				t.Errorf("expected the namespace prefix %s in the query %s: %s", c.namespacePrefix, q.Name, q.Query)
+			}
+			found = found || q.Name == c.expectedQuery
+		}
+		if !found {
+			t.Errorf("expected the query %s for %s", c.expectedQuery, c.file)
+		}
+	}
+}
+
+func TestLoadQueryReportConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "query-report-config-")
+	if err != nil {
+		t.Fatalf("failed to create the temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"base.yaml":  "name: Base\nnamespacePrefix: redhat-rhmi-\nvars:\n  installation: rhmi\n  cluster: test\nqueries:\n- name: up\n  query: up\n",
+		"rhoam.yaml": "base: base.yaml\nnamespacePrefix: redhat-rhoam-\nvars:\n  installation: rhoam\nqueries:\n- name: extra\n  query: extra\n",
+		"loop.yaml":  "base: loop.yaml\nqueries: []\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	config, err := loadQueryReportConfig(path.Join(dir, "rhoam.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &queryReportConfig{
+		Name:            "Base",
+		NamespacePrefix: "redhat-rhoam-",
+		Vars:            map[string]string{"installation": "rhoam", "cluster": "test"},
+		Queries:         []queryOpts{{Name: "up", Query: "up"}, {Name: "extra", Query: "extra"}},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("unexpected configuration %+v", config)
+	}
+	if _, err := loadQueryReportConfig(path.Join(dir, "loop.yaml")); err == nil {
+		t.Fatal("expected an error for a configuration extending itself")
+	}
+}
+
+func TestParseQueryVars(t *testing.T) {
+	vars, err := parseQueryVars([]string{"product=3scale", "selector=a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := map[string]string{"product": "3scale", "selector": "a=b"}; !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("expected %v but got %v", expected, vars)
+	}
+	for _, v := range []string{"product", "=3scale"} {
+		if _, err := parseQueryVars([]string{v}); err == nil {
+			t.Errorf("expected an error for %s", v)
+		}
+	}
+}
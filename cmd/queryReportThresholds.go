@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/integr8ly/delorean/pkg/utils"
+	"github.com/prometheus/common/model"
+)
+
+type comparison string
+
+type severity string
+
+const (
+	comparisonLt comparison = "lt"
+	comparisonGt comparison = "gt"
+	comparisonEq comparison = "eq"
+
+	// a breach of a blocking threshold makes the command fail
+	severityBlocking severity = "blocking"
+	severityWarning  severity = "warning"
+)
+
+// queryEvaluation is the result of the comparison of the values returned by a query with its threshold
+type queryEvaluation struct {
+	Name     string
+	Query    string
+	Severity severity
+	// Evaluated is false when the query has no threshold
+	Evaluated bool
+	Passed    bool
+	Message   string
+}
+
+func (c comparison) compare(value float64, threshold float64) bool {
+	switch c {
+	case comparisonLt:
+		return value < threshold
+	case comparisonGt:
+		return value > threshold
+	default:
+		return value == threshold
+	}
+}
+
+func (c *queryReportConfig) hasThresholds() bool {
+	for _, q := range c.Queries {
+		if q.Threshold != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateQueries compares the results of the queries with their thresholds. The results must be in the
+// same order as the queries
+func evaluateQueries(queries []queryOpts, results []queryResult) []queryEvaluation {
+	evaluations := make([]queryEvaluation, len(queries))
+	for i, q := range queries {
+		evaluations[i] = evaluateQuery(q, results[i].v)
+	}
+	return evaluations
+}
+
+// evaluateQuery checks that every value returned by the query satisfies the comparison with the threshold.
+// A query that returns no value can't be verified and fails. The severity defaults to blocking
+func evaluateQuery(q queryOpts, v model.Value) queryEvaluation {
+	e := queryEvaluation{Name: q.Name, Query: q.Query, Severity: q.Severity}
+	if e.Severity == "" {
+		e.Severity = severityBlocking
+	}
+	if q.Threshold == nil {
+		return e
+	}
+	e.Evaluated = true
+	threshold := *q.Threshold
+
+	values, err := resultValues(v)
+	if err != nil {
+		e.Message = err.Error()
+		return e
+	}
+	if len(values) == 0 {
+		e.Message = "the query returned no value"
+		return e
+	}
+	var breaches []string
+	for _, rv := range values {
+		if !q.Comparison.compare(rv.value, threshold) {
+			breaches = append(breaches, fmt.Sprintf("%s%s is not %s %s", rv.metric, formatValue(rv.value), q.Comparison, formatValue(threshold)))
+		}
+	}
+	if len(breaches) > 0 {
+		e.Message = strings.Join(breaches, ", ")
+		return e
+	}
+	e.Passed = true
+	e.Message = fmt.Sprintf("%d values %s %s", len(values), q.Comparison, formatValue(threshold))
+	return e
+}
+
+type resultValue struct {
+	metric string
+	value  float64
+}
+
+// resultValues returns all the values of a scalar, vector or matrix
+func resultValues(v model.Value) ([]resultValue, error) {
+	var values []resultValue
+	switch r := v.(type) {
+	case *model.Scalar:
+		values = append(values, resultValue{value: float64(r.Value)})
+	case model.Vector:
+		for _, s := range r {
+			values = append(values, resultValue{metric: sampleMetric(s.Metric), value: float64(s.Value)})
+		}
+	case model.Matrix:
+		for _, s := range r {
+			for _, p := range s.Values {
+				values = append(values, resultValue{metric: sampleMetric(s.Metric), value: float64(p.Value)})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("results of type %s can't be compared with a threshold", v.Type())
+	}
+	return values, nil
+}
+
+func sampleMetric(m model.Metric) string {
+	if len(m) == 0 {
+		return ""
+	}
+	return m.String() + " "
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeEvaluations writes the evaluations as a JUnit file with one test case per query, and as a
+// human-readable summary which is also printed
+func (c *queryReportCmd) writeEvaluations(evaluations []queryEvaluation, baseName string) error {
+	suite := utils.JUnitTestSuite{Name: c.config.Name, Tests: len(evaluations)}
+	for _, e := range evaluations {
+		tc := utils.JUnitTestCase{Classname: c.config.Name, Name: e.Name, Time: "0"}
+		switch {
+		case !e.Evaluated:
+			tc.SkipMessage = &utils.JUnitSkipMessage{Message: "no threshold"}
+		case !e.Passed:
+			tc.Failure = &utils.JUnitFailure{Message: e.Message, Type: string(e.Severity), Contents: e.Query}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	junitFile := path.Join(c.outputDir, baseName+"-junit.xml")
+	f, err := os.Create(junitFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = (&utils.JUnitTestSuites{Suites: []utils.JUnitTestSuite{suite}}).WriteXML(f); err != nil {
+		return err
+	}
+	fmt.Println("JUnit report is generated:", junitFile)
+
+	summary := &strings.Builder{}
+	writeEvaluationsSummary(summary, c.config.Name, evaluations)
+	fmt.Print(summary.String())
+	summaryFile := path.Join(c.outputDir, baseName+"-summary.txt")
+	if err = ioutil.WriteFile(summaryFile, []byte(summary.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Summary is generated:", summaryFile)
+	return nil
+}
+
+func writeEvaluationsSummary(w io.Writer, name string, evaluations []queryEvaluation) {
+	var passed, failed, blocking, skipped int
+	for _, e := range evaluations {
+		switch {
+		case !e.Evaluated:
+			skipped++
+		case e.Passed:
+			passed++
+		default:
+			failed++
+			if e.Severity == severityBlocking {
+				blocking++
+			}
+		}
+	}
+	fmt.Fprintf(w, "%s: %d passed, %d failed (%d blocking), %d without threshold\n", name, passed, failed, blocking, skipped)
+	for _, e := range evaluations {
+		if !e.Evaluated {
+			continue
+		}
+		status := "PASS"
+		if !e.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s [%s] %s: %s\n", status, e.Severity, e.Name, e.Message)
+	}
+}
+
+// blockingBreaches returns an error if any of the blocking thresholds is breached
+func blockingBreaches(evaluations []queryEvaluation) error {
+	var names []string
+	for _, e := range evaluations {
+		if e.Evaluated && !e.Passed && e.Severity == severityBlocking {
+			names = append(names, e.Name)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("blocking thresholds breached: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
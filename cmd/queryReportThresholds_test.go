@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	"github.com/prometheus/common/model"
+	"k8s.io/utils/pointer"
+)
+
+func TestEvaluateQuery(t *testing.T) {
+	vector := model.Vector{
+		{Metric: model.Metric{"endpoint": "apicast-production"}, Value: 30},
+		{Metric: model.Metric{"endpoint": "apicast-staging"}, Value: 75},
+	}
+	cases := []struct {
+		description string
+		query       queryOpts
+		value       model.Value
+		evaluated   bool
+		passed      bool
+		severity    severity
+		message     string
+	}{
+		{
+			description: "no threshold",
+			query:       queryOpts{Name: "downtime"},
+			value:       vector,
+			severity:    severityBlocking,
+		},
+		{
+			description: "all the values are lower than the threshold",
+			query:       queryOpts{Name: "downtime", Threshold: pointer.Float64Ptr(100), Comparison: comparisonLt, Severity: severityWarning},
+			value:       vector,
+			evaluated:   true,
+			passed:      true,
+			severity:    severityWarning,
+		},
+		{
+			description: "one value breaches the threshold",
+			query:       queryOpts{Name: "downtime", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt},
+			value:       vector,
+			evaluated:   true,
+			severity:    severityBlocking,
+			message:     `{endpoint="apicast-staging"} 75 is not lt 60`,
+		},
+		{
+			description: "scalar equal to the threshold",
+			query:       queryOpts{Name: "up", Threshold: pointer.Float64Ptr(1), Comparison: comparisonEq},
+			value:       &model.Scalar{Value: 1},
+			evaluated:   true,
+			passed:      true,
+			severity:    severityBlocking,
+		},
+		{
+			description: "matrix with a value lower than the threshold",
+			query:       queryOpts{Name: "replicas", Threshold: pointer.Float64Ptr(0), Comparison: comparisonGt},
+			value: model.Matrix{
+				{Metric: model.Metric{"pod": "keycloak"}, Values: []model.SamplePair{{Value: 2}, {Value: 0}}},
+			},
+			evaluated: true,
+			severity:  severityBlocking,
+			message:   `{pod="keycloak"} 0 is not gt 0`,
+		},
+		{
+			description: "no value",
+			query:       queryOpts{Name: "downtime", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt},
+			value:       model.Vector{},
+			evaluated:   true,
+			severity:    severityBlocking,
+			message:     "the query returned no value",
+		},
+		{
+			description: "string results can't be compared",
+			query:       queryOpts{Name: "version", Threshold: pointer.Float64Ptr(1), Comparison: comparisonEq},
+			value:       &model.String{Value: "1"},
+			evaluated:   true,
+			severity:    severityBlocking,
+			message:     "results of type string can't be compared with a threshold",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			e := evaluateQuery(c.query, c.value)
+			if e.Evaluated != c.evaluated || e.Passed != c.passed || e.Severity != c.severity {
+				t.Fatalf("unexpected evaluation: %+v", e)
+			}
+			if c.message != "" && e.Message != c.message {
+				t.Fatalf("expected message %q but got %q", c.message, e.Message)
+			}
+		})
+	}
+}
+
+func TestQueryReportConfigValidate(t *testing.T) {
+	invalid := []queryOpts{
+		{Name: "missing comparison", Threshold: pointer.Float64Ptr(60)},
+		{Name: "invalid comparison", Threshold: pointer.Float64Ptr(60), Comparison: "le"},
+		{Name: "invalid severity", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt, Severity: "critical"},
+		{Name: "down condition of an instant query", QueryType: promQueryType, DownWhen: &downCondition{Comparison: comparisonLt, Value: 1}},
+		{Name: "invalid down condition", QueryType: promQueryRangeType, DownWhen: &downCondition{Comparison: "le", Value: 1}},
+	}
+	for _, q := range invalid {
+		c := &queryReportConfig{Queries: []queryOpts{q}}
+		if err := c.validate(); err == nil {
+			t.Errorf("expected an error for the query %s", q.Name)
+		}
+	}
+	c := &queryReportConfig{Queries: []queryOpts{
+		{Name: "no threshold"},
+		{Name: "threshold", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt, Severity: severityWarning},
+		{Name: "down condition", QueryType: promQueryRangeType, DownWhen: &downCondition{Comparison: comparisonLt, Value: 1}},
+	}}
+	if err := c.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWriteEvaluationsSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	writeEvaluationsSummary(out, "Downtime Report", []queryEvaluation{
+		{Name: "3scale_downtime", Severity: severityBlocking, Evaluated: true, Message: "75 is not lt 60"},
+		{Name: "amq_downtime", Severity: severityWarning, Evaluated: true, Message: "20 is not lt 10"},
+		{Name: "sso_downtime", Severity: severityBlocking, Evaluated: true, Passed: true, Message: "1 values lt 60"},
+		{Name: "ups_downtime", Severity: severityBlocking},
+	})
+	expected := `Downtime Report: 1 passed, 2 failed (1 blocking), 1 without threshold
+FAIL [blocking] 3scale_downtime: 75 is not lt 60
+FAIL [warning] amq_downtime: 20 is not lt 10
+PASS [blocking] sso_downtime: 1 values lt 60
+`
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+}
+
+func TestQueryReportCmd_thresholds(t *testing.T) {
+	values := map[string]model.SampleValue{"apicast_downtime": 75, "amq_downtime": 20, "sso_downtime": 10}
+	promAPI := &mockPromService{
+		queryFunc: func(ctx context.Context, query string, ts time.Time) (model.Value, api.Warnings, error) {
+			return model.Vector{{Metric: model.Metric{}, Value: values[query]}}, nil, nil
+		},
+	}
+	cases := []struct {
+		description string
+		queries     []queryOpts
+		expectErr   string
+	}{
+		{
+			description: "blocking threshold breached",
+			queries: []queryOpts{
+				{QueryType: promQueryType, Name: "apicast", Query: "apicast_downtime", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt},
+				{QueryType: promQueryType, Name: "amq", Query: "amq_downtime", Threshold: pointer.Float64Ptr(10), Comparison: comparisonLt, Severity: severityWarning},
+				{QueryType: promQueryType, Name: "sso", Query: "sso_downtime"},
+			},
+			expectErr: "blocking thresholds breached: apicast",
+		},
+		{
+			description: "only warning thresholds breached",
+			queries: []queryOpts{
+				{QueryType: promQueryType, Name: "amq", Query: "amq_downtime", Threshold: pointer.Float64Ptr(10), Comparison: comparisonLt, Severity: severityWarning},
+				{QueryType: promQueryType, Name: "sso", Query: "sso_downtime", Threshold: pointer.Float64Ptr(60), Comparison: comparisonLt},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			outputDir := t.TempDir()
+			cmd := &queryReportCmd{
+				outputDir: outputDir,
+				promAPI:   promAPI,
+				timeout:   defaultQueryTimeout,
+				config:    &queryReportConfig{Name: "Test Report", Queries: c.queries},
+			}
+			err := cmd.run(context.TODO())
+			if c.expectErr != "" {
+				if err == nil || err.Error() != c.expectErr {
+					t.Fatalf("expected error %q but got %v", c.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			junitFiles, _ := filepath.Glob(outputDir + "/test-report-*-junit.xml")
+			if len(junitFiles) != 1 {
+				t.Fatalf("expected a JUnit file but got %v", junitFiles)
+			}
+			b, _ := ioutil.ReadFile(junitFiles[0])
+			if n := strings.Count(string(b), "<testcase "); n != len(c.queries) {
+				t.Errorf("expected %d test cases but got %d:\n%s", len(c.queries), n, b)
+			}
+			summaryFiles, _ := filepath.Glob(outputDir + "/test-report-*-summary.txt")
+			if len(summaryFiles) != 1 {
+				t.Fatalf("expected a summary file but got %v", summaryFiles)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -161,3 +162,98 @@ func TestQueryResult_UnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryReportCmd_timeAndStep(t *testing.T) {
+	end := time.Unix(1600000000, 0)
+	f := &queryReportCmdFlags{end: end.Unix(), duration: time.Hour, step: time.Minute}
+	queries := []queryOpts{
+		{QueryType: promQueryType, Name: "default_time", Query: "default_time"},
+		{QueryType: promQueryType, Name: "start_time", Query: "start_time", Time: queryTimeStart},
+		{QueryType: promQueryType, Name: "fixed_time", Query: "fixed_time", Time: "1599999000"},
+		{QueryType: promQueryRangeType, Name: "default_step", Query: "default_step"},
+		{QueryType: promQueryRangeType, Name: "query_step", Query: "query_step", Step: "5m"},
+	}
+	// the queries are run in parallel
+	var mu sync.Mutex
+	times := map[string]time.Time{}
+	ranges := map[string]promv1.Range{}
+	cmd := &queryReportCmd{
+		outputDir: t.TempDir(),
+		timeout:   defaultQueryTimeout,
+		config:    &queryReportConfig{Name: "Test Report", Queries: queries},
+		promAPI: &mockPromService{
+			queryFunc: func(ctx context.Context, query string, ts time.Time) (model.Value, api.Warnings, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				times[query] = ts
+				return &model.Scalar{}, nil, nil
+			},
+			queryRangeFunc: func(ctx context.Context, query string, r promv1.Range) (model.Value, api.Warnings, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				ranges[query] = r
+				return model.Matrix{}, nil, nil
+			},
+		},
+	}
+
+	cmd.queryRange = parseQueryRange(f)
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedTimes := map[string]time.Time{
+		"default_time": end,
+		"start_time":   end.Add(-time.Hour),
+		"fixed_time":   time.Unix(1599999000, 0),
+	}
+	for q, expected := range expectedTimes {
+		if !times[q].Equal(expected) {
+			t.Errorf("expected %s to be evaluated at %v but got %v", q, expected, times[q])
+		}
+	}
+	if step := ranges["default_step"].Step; step != time.Minute {
+		t.Errorf("expected the step of the flag but got %v", step)
+	}
+	if step := ranges["query_step"].Step; step != 5*time.Minute {
+		t.Errorf("expected the step of the query but got %v", step)
+	}
+
+	// the evaluation time of the instant queries can be set with a flag
+	f.queryTime = 1599998000
+	cmd.queryRange = parseQueryRange(f)
+	if err := cmd.run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !times["default_time"].Equal(time.Unix(1599998000, 0)) {
+		t.Errorf("expected the time of the flag but got %v", times["default_time"])
+	}
+
+	cmd.config.Queries = []queryOpts{{QueryType: promQueryType, Name: "invalid", Query: "invalid", Time: "yesterday"}}
+	if err := cmd.run(context.TODO()); err == nil {
+		t.Errorf("expected an error for an invalid time")
+	}
+}
+
+func TestQueryReportCmd_timeoutFlags(t *testing.T) {
+	defaultPipelineTimeout := pipelineTimeout
+	defer func() { pipelineTimeout = defaultPipelineTimeout }()
+
+	for _, args := range [][]string{
+		{"--command-timeout", "1m", "--timeout", "20"},
+		{"--command-timeout", "1m", "-t", "20"},
+	} {
+		cmd, _, err := pipelineCmd.Find([]string{"query-report"})
+		if err != nil {
+			t.Fatalf("query-report not found: %v", err)
+		}
+		if err = cmd.ParseFlags(args); err != nil {
+			t.Fatalf("failed to parse %v: %v", args, err)
+		}
+		if pipelineTimeout != time.Minute {
+			t.Errorf("expected the pipeline timeout to be 1m with %v but got %s", args, pipelineTimeout)
+		}
+		if queryTimeout, err := cmd.Flags().GetInt("timeout"); err != nil || queryTimeout != 20 {
+			t.Errorf("expected the query timeout to be 20 with %v but got %d: %v", args, queryTimeout, err)
+		}
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -15,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/integr8ly/delorean/pkg/reportportal"
+	"github.com/integr8ly/delorean/pkg/s3processor"
 	"github.com/integr8ly/delorean/pkg/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -35,6 +36,7 @@ type reportPortalImportCmdFlags struct {
 	bucket              string
 	reportPortalProject string
 	noTagging           bool
+	limits              s3ImportFlags
 }
 
 type reportPortalImportCmd struct {
@@ -44,11 +46,8 @@ type reportPortalImportCmd struct {
 	s3downloader    s3manageriface.DownloaderAPI
 	rpProjectName   string
 	noTagging       bool
-}
-
-type reportProcessResult struct {
-	s3ObjKey   string
-	rpLaunchId int
+	maxObjects      int
+	since           time.Time
 }
 
 type testMetadata struct {
@@ -100,6 +99,7 @@ func init() {
 	viper.BindPFlag(rpTokenKey, cmd.Flags().Lookup("rp-token"))
 
 	cmd.Flags().BoolVar(&f.noTagging, "no-tagging", false, "Do not add new tags to the AWS resources, for testing purposes.")
+	f.limits.addFlags(cmd)
 }
 
 func newRPClient(token string) *reportportal.Client {
@@ -112,6 +112,10 @@ func newRPClient(token string) *reportportal.Client {
 }
 
 func newReportPortalImportCmd(f *reportPortalImportCmdFlags, session *session.Session, rpToken string) (*reportPortalImportCmd, error) {
+	since, err := f.limits.sinceTime(time.Now())
+	if err != nil {
+		return nil, err
+	}
 	rp := newRPClient(rpToken)
 	s3 := s3.New(session)
 	s3Downloader := s3manager.NewDownloader(session)
@@ -122,114 +126,78 @@ func newReportPortalImportCmd(f *reportPortalImportCmdFlags, session *session.Se
 		s3downloader:    s3Downloader,
 		rpProjectName:   f.reportPortalProject,
 		noTagging:       f.noTagging,
+		maxObjects:      f.limits.maxObjects,
+		since:           since,
 	}, nil
 }
 
 func (c *reportPortalImportCmd) run(ctx context.Context) error {
-	fmt.Println(fmt.Sprintf("[All] Listing objects from bucket %s", c.fromBucket))
-	o, err := c.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: &c.fromBucket, Delimiter: aws.String("/")})
+	p := s3processor.NewProcessor(c.s3, c.s3downloader, s3processor.Options{
+		Bucket:       c.fromBucket,
+		Filter:       isZipFile,
+		ProcessedTag: &s3processor.Tag{Key: reportPortalTagKey, Value: reportPortalTagVal},
+		NoTagging:    c.noTagging,
+		Download:     true,
+		Workers:      defaultImportWorkers,
+		MaxObjects:   c.maxObjects,
+		Since:        c.since,
+	})
+	results, err := p.Run(ctx, s3processor.SinkFunc(c.processReportFile))
 	if err != nil {
 		return err
 	}
-	fmt.Println(fmt.Sprintf("[All] Found %d objects to process", len(o.Contents)))
-	tasks := make([]utils.Task, len(o.Contents))
-	for i, obj := range o.Contents {
-		f := obj
-		t := func() (utils.TaskResult, error) {
-			return c.processReportFile(ctx, f)
-		}
-		tasks[i] = t
-	}
-
-	if _, err := utils.ParallelLimit(ctx, tasks, defaultImportWorkers); err != nil {
-		return err
-	}
-	fmt.Println("[All] Process completed")
-	return nil
+	return results.Err()
 }
 
-func (c *reportPortalImportCmd) processReportFile(ctx context.Context, object *s3.Object) (*reportProcessResult, error) {
-	fmt.Println(fmt.Sprintf("[%s] Start processing object", *object.Key))
-	tags, err := c.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
-		Bucket: &c.fromBucket,
-		Key:    object.Key,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if hasTag(tags.TagSet, reportPortalTagKey, reportPortalTagVal) {
-		fmt.Println(fmt.Sprintf("[%s] File in bucket %s has been processed already. Ignored.", *object.Key, c.fromBucket))
-		return &reportProcessResult{}, nil
-	}
-	if !strings.HasSuffix(*object.Key, ".zip") {
-		fmt.Println(fmt.Sprintf("[%s] File in bucket %s is ignored as it is not a zip file", *object.Key, c.fromBucket))
-		return &reportProcessResult{}, nil
-	}
-	fmt.Println(fmt.Sprintf("[%s] Downloading file from s3 bucket %s", *object.Key, c.fromBucket))
-	// download object to a tmp dir
-	downloaded, err := utils.DownloadS3ObjectToTempDir(ctx, c.s3downloader, c.fromBucket, *object.Key)
+func (c *reportPortalImportCmd) processReportFile(ctx context.Context, object *s3processor.Object) error {
+	fmt.Println(fmt.Sprintf("[%s] File Downloaded. Extracting metadata.json file.", object.Key))
+	m, err := readTestMetadata(object.File)
 	if err != nil {
-		return nil, err
-	}
-	defer os.Remove(downloaded)
-
-	fmt.Println(fmt.Sprintf("[%s] File Downloaded. Extracting metadata.json file.", *object.Key))
-	// get the metadata.json file
-	b, err := utils.ReadFileFromZip(downloaded, metadataFileName)
-	if err != nil {
-		return nil, err
-	}
-	m := &testMetadata{}
-	if err := json.Unmarshal(b, m); err != nil {
-		return nil, err
+		return err
 	}
-	fmt.Println(fmt.Sprintf("[%s] Metadata.json file is loaded. Uploading results to ReportPortal: %s", *object.Key, reportportal.BaseURL))
+	fmt.Println(fmt.Sprintf("[%s] Metadata.json file is loaded. Uploading results to ReportPortal: %s", object.Key, reportportal.BaseURL))
 
 	// upload it to ReportPortal
-	importResp, err := c.rpLaunchService.Import(ctx, c.rpProjectName, downloaded, m.Name)
+	importResp, err := c.rpLaunchService.Import(ctx, c.rpProjectName, object.File, m.Name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	fmt.Println(fmt.Sprintf("[%s] File uploaded. Get the Launch Id for Launch UUID %s", *object.Key, importResp.GetLaunchUuid()))
+	fmt.Println(fmt.Sprintf("[%s] File uploaded. Get the Launch Id for Launch UUID %s", object.Key, importResp.GetLaunchUuid()))
 
 	getLaunchIdResp, err := c.rpLaunchService.Get(ctx, c.rpProjectName, importResp.GetLaunchUuid())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	fmt.Println(fmt.Sprintf("[%s] Launch Id: %d", *object.Key, getLaunchIdResp.Id))
+	fmt.Println(fmt.Sprintf("[%s] Launch Id: %d", object.Key, getLaunchIdResp.Id))
 	// update the launch obj to add a bit more info
 	update := &reportportal.RPLaunchUpdateInput{
 		Description: m.JobURL,
 		Tags:        []string{m.Name, m.RHMIVersion},
 	}
 	updateResp, err := c.rpLaunchService.Update(ctx, c.rpProjectName, getLaunchIdResp.Id, update)
+	if err != nil {
+		return err
+	}
+	fmt.Println(fmt.Sprintf("[%s] Launch updated. Id = %d, UUID = %s", object.Key, getLaunchIdResp.Id, updateResp.GetLaunchUuid()))
+	return nil
+}
+
+// isZipFile filters the archives of the test results
+func isZipFile(key string) bool {
+	return strings.HasSuffix(key, ".zip")
+}
+
+// readTestMetadata reads the metadata.json file of the archive of the test results
+func readTestMetadata(zipfile string) (*testMetadata, error) {
+	b, err := utils.ReadFileFromZip(zipfile, metadataFileName)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(fmt.Sprintf("[%s] Launch updated. Id = %d, UUID = %s", *object.Key, getLaunchIdResp.Id, updateResp.GetLaunchUuid()))
-	if !c.noTagging {
-		// update the tags on the obj
-		fmt.Println(fmt.Sprintf("[%s] Adding tag %s=%s to s3 object", *object.Key, reportPortalTagKey, reportPortalTagVal))
-		t := append(tags.TagSet, &s3.Tag{
-			Key:   aws.String(reportPortalTagKey),
-			Value: aws.String(reportPortalTagVal),
-		})
-		if _, err = c.s3.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
-			Bucket:  aws.String(c.fromBucket),
-			Key:     object.Key,
-			Tagging: &s3.Tagging{TagSet: t},
-		}); err != nil {
-			return nil, err
-		}
-		fmt.Println(fmt.Sprintf("[%s] Tags updated", *object.Key))
-	} else {
-		fmt.Println(fmt.Sprintf("[%s] Skip adding tags", *object.Key))
+	m := &testMetadata{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
 	}
-
-	return &reportProcessResult{
-		s3ObjKey:   *object.Key,
-		rpLaunchId: getLaunchIdResp.Id,
-	}, nil
+	return m, nil
 }
 
 func hasTag(tags []*s3.Tag, key string, val string) bool {
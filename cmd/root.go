@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/go-github/v30/github"
 	"github.com/integr8ly/delorean/pkg/quay"
@@ -23,6 +26,10 @@ var releaseVersion string
 var olmType string
 
 var kubeconfigFile string
+var pipelineTimeout time.Duration
+
+// cancelCommand cancels the context of the running command
+var cancelCommand context.CancelFunc = func() {}
 
 const (
 	GithubTokenKey                         = "github_token"
@@ -37,6 +44,7 @@ const (
 	PolarionPasswordKey                    = "polarion_password"
 	AWSAccessKeyIDEnv                      = "delorean_aws_access_key_id"
 	AWSSecretAccessKeyEnv                  = "delorean_aws_secret_access_key"
+	GrafanaTokenKey                        = "grafana_token"
 	AWSDefaultRegion                       = "eu-west-1"
 )
 
@@ -88,10 +96,26 @@ var reportCmd = &cobra.Command{
 	Long:  "Collection of commands to report test results in Polarion and ReportPortal",
 }
 
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "OSD cluster commands",
+	Long:  "Commands to provision, upgrade and delete OSD clusters using the OCM API",
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// The context of the commands is cancelled on SIGINT and SIGTERM so that they can clean up,
+	// a second signal terminates the process straight away
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+	cancelCommand = cancel
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -120,6 +144,15 @@ func init() {
 	}
 	pipelineCmd.PersistentFlags().StringVar(&kubeconfigFile, "kubeconfig", defaultKubeconfigFilePath, fmt.Sprintf("Path to the kubeconfig file. Can be set via the %s env var", strings.ToUpper(KubeConfigKey)))
 	viper.BindPFlag(KubeConfigKey, pipelineCmd.PersistentFlags().Lookup("kubeconfig"))
+	pipelineCmd.PersistentFlags().DurationVar(&pipelineTimeout, "command-timeout", 0, "Max duration of the command, after which it is cancelled and cleans up. No timeout if 0")
+	pipelineCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if pipelineTimeout > 0 {
+			time.AfterFunc(pipelineTimeout, func() {
+				fmt.Printf("Timed out after %s, cancelling the command\n", pipelineTimeout)
+				cancelCommand()
+			})
+		}
+	}
 
 	// flags for the report command
 	reportCmd.Flags().String("polarion-username", "", "Polarion username")
@@ -136,6 +169,7 @@ func init() {
 	rootCmd.AddCommand(pipelineCmd)
 	rootCmd.AddCommand(reportCmd)
 	rootCmd.AddCommand(openshifCICmd)
+	rootCmd.AddCommand(clusterCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// s3ImportFlags limit the objects of the bucket processed by the import commands, so that a large bucket can be
+// imported over several runs
+type s3ImportFlags struct {
+	maxObjects int
+	since      string
+}
+
+func (f *s3ImportFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.maxObjects, "max-objects", 0, "The maximum number of objects to process, from the least recently modified. The others are processed by the next runs. No limit if 0")
+	cmd.Flags().StringVar(&f.since, "since", "", "Only process the objects modified since a duration ago like 72h, or since a date like 2021-06-30 or 2021-06-30T15:04:05Z")
+}
+
+// sinceTime returns the time the objects must be modified after, the zero time if not set
+func (f *s3ImportFlags) sinceTime(now time.Time) (time.Time, error) {
+	if f.since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(f.since); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, f.since); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %s, expected a duration like 72h or a date like 2021-06-30", f.since)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestS3ImportFlags_sinceTime(t *testing.T) {
+	now := time.Date(2021, 7, 10, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"":                     {},
+		"72h":                  time.Date(2021, 7, 7, 12, 0, 0, 0, time.UTC),
+		"2021-06-30":           time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC),
+		"2021-06-30T15:04:05Z": time.Date(2021, 6, 30, 15, 4, 5, 0, time.UTC),
+	}
+	for since, expected := range cases {
+		f := &s3ImportFlags{since: since}
+		actual, err := f.sinceTime(now)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", since, err)
+		}
+		if !actual.Equal(expected) {
+			t.Errorf("expected %s for %q but got %s", expected, since, actual)
+		}
+	}
+	if _, err := (&s3ImportFlags{since: "last week"}).sinceTime(now); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+}
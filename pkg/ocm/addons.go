@@ -0,0 +1,43 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+)
+
+// AddonVersion represents the version of an addon in OCM
+type AddonVersion struct {
+	ID      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Addon represents an addon in OCM
+type Addon struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Enabled bool          `json:"enabled"`
+	Version *AddonVersion `json:"version,omitempty"`
+}
+
+// AddonsManager manages the addons available in OCM
+// See https://api.openshift.com/#/default/get_api_clusters_mgmt_v1_addons__addon_id_
+type AddonsManager interface {
+	Get(ctx context.Context, addonID string) (*Addon, error)
+}
+
+type AddonsService service
+
+// Get returns the addon with the given id
+func (s *AddonsService) Get(ctx context.Context, addonID string) (*Addon, error) {
+	u := fmt.Sprintf("api/clusters_mgmt/v1/addons/%s", addonID)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	addon := &Addon{}
+	_, err = s.client.Do(ctx, req, addon)
+	if err != nil {
+		return nil, err
+	}
+	return addon, nil
+}
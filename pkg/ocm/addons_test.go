@@ -0,0 +1,47 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAddonsService_Get(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/addons/managed-api-service", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"kind":"AddOn","id":"managed-api-service","name":"Red Hat OpenShift API Management","enabled":true,"version":{"id":"1.27.0","enabled":true}}`)
+	})
+
+	addon, err := client.Addons.Get(context.TODO(), "managed-api-service")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &Addon{
+		ID:      "managed-api-service",
+		Name:    "Red Hat OpenShift API Management",
+		Enabled: true,
+		Version: &AddonVersion{ID: "1.27.0", Enabled: true},
+	}
+	if !reflect.DeepEqual(addon, want) {
+		t.Errorf("Addons.Get returned %+v, want %+v", addon, want)
+	}
+}
+
+func TestAddonsService_Get_notFound(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/addons/unknown", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	})
+
+	if _, err := client.Addons.Get(context.TODO(), "unknown"); err == nil {
+		t.Fatal("expected Addons.Get to fail")
+	}
+}
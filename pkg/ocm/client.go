@@ -0,0 +1,169 @@
+package ocm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	BaseURL      = "https://api.openshift.com/"
+	StageBaseURL = "https://api.stage.openshift.com/"
+
+	// SSO endpoint used to exchange the OCM offline token for an access token
+	TokenURL = "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token"
+	ClientID = "cloud-services"
+)
+
+// Client represents the client to access the OCM API
+type Client struct {
+	httpClient    *http.Client
+	common        service
+	BaseURL       *url.URL
+	Addons        *AddonsService
+	Clusters      *ClustersService
+	Subscriptions *SubscriptionsService
+}
+
+// NewRequest builds a new http request to send to the OCM API
+// The relative path should be specified without a preceding slash
+func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Request, error) {
+	if !strings.HasSuffix(c.BaseURL.Path, "/") {
+		return nil, fmt.Errorf("BaseURL must have a trailing slash, but %q does not", c.BaseURL)
+	}
+	if strings.HasPrefix(urlStr, "/") {
+		return nil, fmt.Errorf("relative path must not have a preceding slash: %q", urlStr)
+	}
+	u, err := c.BaseURL.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf io.ReadWriter
+	if body != nil {
+		buf = &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error) {
+	if ctx == nil {
+		return nil, errors.New("context must be non-nil")
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// If we got an error, and the context has been canceled,
+		// the context's error is probably more useful.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = checkResponse(resp)
+	if err != nil {
+		return resp, err
+	}
+
+	if v != nil {
+		decErr := json.NewDecoder(resp.Body).Decode(v)
+		if decErr == io.EOF {
+			decErr = nil // ignore EOF errors caused by empty response body
+		}
+		if decErr != nil {
+			err = decErr
+		}
+	}
+	return resp, err
+}
+
+// ErrorResponse reports an error returned by the OCM API
+type ErrorResponse struct {
+	Response *http.Response
+	Code     string `json:"code"`
+	Reason   string `json:"reason"`
+}
+
+func (r *ErrorResponse) Error() string {
+	msg := fmt.Sprintf("http error: url = %q; status = %d", r.Response.Request.URL, r.Response.StatusCode)
+	if r.Reason != "" {
+		msg = fmt.Sprintf("%s; reason = %s", msg, r.Reason)
+	}
+	return msg
+}
+
+// IsNotFound returns true if the error is an OCM API error with the 404 status code
+func IsNotFound(err error) bool {
+	var errResp *ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+func checkResponse(resp *http.Response) error {
+	if c := resp.StatusCode; 200 <= c && c <= 299 {
+		return nil
+	}
+	errResp := &ErrorResponse{Response: resp}
+	// The body is not always an OCM error object, so ignore decoding errors
+	_ = json.NewDecoder(resp.Body).Decode(errResp)
+	return errResp
+}
+
+type service struct {
+	client *Client
+}
+
+// NewClient returns a new OCM client. Use NewTokenClient to get an http.Client
+// that authenticates the requests using an OCM offline token
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	baseURL, _ := url.Parse(BaseURL)
+	c := &Client{
+		httpClient: httpClient,
+		BaseURL:    baseURL,
+	}
+	c.common.client = c
+	c.Addons = (*AddonsService)(&c.common)
+	c.Clusters = (*ClustersService)(&c.common)
+	c.Subscriptions = (*SubscriptionsService)(&c.common)
+	return c
+}
+
+// NewTokenClient returns an http.Client that exchanges the given OCM offline token
+// for access tokens and adds them to each request
+func NewTokenClient(ctx context.Context, offlineToken string) *http.Client {
+	conf := &oauth2.Config{
+		ClientID: ClientID,
+		Endpoint: oauth2.Endpoint{TokenURL: TokenURL},
+	}
+	return conf.Client(ctx, &oauth2.Token{RefreshToken: offlineToken})
+}
@@ -0,0 +1,157 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	baseURLPath = "/ocm"
+)
+
+// setup sets up a test HTTP server along with a ocm.Client that is
+// configured to talk to that test server. Tests should register handlers on
+// mux which provide mock responses for the API method being tested.
+func setup() (client *Client, mux *http.ServeMux, serverURL string, teardown func()) {
+	// mux is the HTTP request multiplexer used with the test server.
+	mux = http.NewServeMux()
+
+	// We want to ensure that tests catch mistakes where the endpoint URL is
+	// specified as absolute rather than relative.
+	apiHandler := http.NewServeMux()
+	apiHandler.Handle(baseURLPath+"/", http.StripPrefix(baseURLPath, mux))
+	apiHandler.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(os.Stderr, "FAIL: Client.BaseURL path prefix is not preserved in the request URL:")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "\t"+req.URL.String())
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "\tDid you accidentally use an absolute endpoint URL rather than relative?")
+		http.Error(w, "Client.BaseURL path prefix is not preserved in the request URL.", http.StatusInternalServerError)
+	})
+
+	// server is a test HTTP server used to provide mock API responses.
+	server := httptest.NewServer(apiHandler)
+
+	// client is the OCM client being tested and is
+	// configured to use test server.
+	client = NewClient(nil)
+	url, _ := url.Parse(server.URL + baseURLPath + "/")
+	client.BaseURL = url
+
+	return client, mux, server.URL, server.Close
+}
+
+func testMethod(t *testing.T, r *http.Request, want string) {
+	t.Helper()
+	if got := r.Method; got != want {
+		t.Errorf("Request method: %v, want %v", got, want)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	c := NewClient(nil)
+
+	if got, want := c.BaseURL.String(), BaseURL; got != want {
+		t.Errorf("NewClient BaseURL is %v, want %v", got, want)
+	}
+
+	c2 := NewClient(nil)
+	if c.httpClient == c2.httpClient {
+		t.Error("NewClient returned same http.Clients, but they should differ")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	type testInput struct {
+		Name *string `json:"name"`
+	}
+	c := NewClient(nil)
+	n := "test"
+	inURL, outURL := "foo", BaseURL+"foo"
+	inBody, outBody := &testInput{Name: &n}, `{"name":"test"}`+"\n"
+	req, err := c.NewRequest("POST", inURL, inBody)
+	if err != nil {
+		t.Errorf("unexpected error when create new requests: %v", err)
+	}
+	// test that relative URL was expanded
+	if got, want := req.URL.String(), outURL; got != want {
+		t.Errorf("NewRequest(%q) URL is %v, want %v", inURL, got, want)
+	}
+
+	// test that body was JSON encoded
+	body, _ := ioutil.ReadAll(req.Body)
+	if got, want := string(body), outBody; got != want {
+		t.Errorf("NewRequest(%v) Body is %v, want %v", inBody, got, want)
+	}
+
+	if _, err := c.NewRequest("GET", "/foo", nil); err == nil {
+		t.Error("expected NewRequest to fail with an absolute path")
+	}
+}
+
+func TestDo(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	type foo struct {
+		A string
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"A":"a"}`)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	body := new(foo)
+	client.Do(context.Background(), req, body)
+
+	want := &foo{"a"}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("Response body = %v, want %v", body, want)
+	}
+}
+
+func TestDo_httpError(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	resp, err := client.Do(context.Background(), req, nil)
+	if err == nil {
+		t.Fatal("expected HTTP 400 error")
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status code %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters/unknown", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"kind":"Error","code":"CLUSTERS-MGMT-404","reason":"Cluster 'unknown' not found"}`)
+	})
+
+	_, err := client.Clusters.Get(context.TODO(), "unknown")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Cluster 'unknown' not found") {
+		t.Errorf("expected the reason in the error message but got %v", err)
+	}
+}
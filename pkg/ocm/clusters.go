@@ -0,0 +1,293 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	ClusterStateReady        = "ready"
+	ClusterStateError        = "error"
+	ClusterStateUninstalling = "uninstalling"
+
+	AddonStateReady  = "ready"
+	AddonStateFailed = "failed"
+
+	UpgradePolicyScheduleManual = "manual"
+	UpgradePolicyTypeOSD        = "OSD"
+)
+
+// Link represents a reference to another OCM object (region, cloud provider, subscription...)
+type Link struct {
+	Kind string `json:"kind,omitempty"`
+	ID   string `json:"id,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+// ClusterNodes represents the compute nodes of a cluster
+type ClusterNodes struct {
+	Compute            int      `json:"compute,omitempty"`
+	ComputeMachineType *Link    `json:"compute_machine_type,omitempty"`
+	AvailabilityZones  []string `json:"availability_zones,omitempty"`
+}
+
+// ClusterNetwork represents the network configuration of a cluster
+type ClusterNetwork struct {
+	MachineCIDR string `json:"machine_cidr,omitempty"`
+	ServiceCIDR string `json:"service_cidr,omitempty"`
+	PodCIDR     string `json:"pod_cidr,omitempty"`
+	HostPrefix  int    `json:"host_prefix,omitempty"`
+}
+
+// ClusterAPI represents the API endpoint of a cluster
+type ClusterAPI struct {
+	URL       string `json:"url,omitempty"`
+	Listening string `json:"listening,omitempty"`
+}
+
+// ClusterConsole represents the web console of a cluster
+type ClusterConsole struct {
+	URL string `json:"url,omitempty"`
+}
+
+// ClusterCCS represents the Customer Cloud Subscription (BYOC) settings of a cluster
+type ClusterCCS struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ClusterAWS represents the AWS account used by a CCS cluster
+type ClusterAWS struct {
+	AccessKeyID     string   `json:"access_key_id,omitempty"`
+	SecretAccessKey string   `json:"secret_access_key,omitempty"`
+	AccountID       string   `json:"account_id,omitempty"`
+	SubnetIDs       []string `json:"subnet_ids,omitempty"`
+	PrivateLink     bool     `json:"private_link,omitempty"`
+}
+
+// ClusterVersion represents the OpenShift version of a cluster
+type ClusterVersion struct {
+	Kind              string   `json:"kind,omitempty"`
+	ID                string   `json:"id,omitempty"`
+	Href              string   `json:"href,omitempty"`
+	RawID             string   `json:"raw_id,omitempty"`
+	AvailableUpgrades []string `json:"available_upgrades,omitempty"`
+}
+
+// Cluster represents an OSD cluster in OCM
+type Cluster struct {
+	ID                  string          `json:"id,omitempty"`
+	Name                string          `json:"name,omitempty"`
+	DisplayName         string          `json:"display_name,omitempty"`
+	State               string          `json:"state,omitempty"`
+	Managed             bool            `json:"managed"`
+	MultiAZ             bool            `json:"multi_az"`
+	ExpirationTimestamp string          `json:"expiration_timestamp,omitempty"`
+	InfraID             string          `json:"infra_id,omitempty"`
+	OpenshiftVersion    string          `json:"openshift_version,omitempty"`
+	Product             *Link           `json:"product,omitempty"`
+	Region              *Link           `json:"region,omitempty"`
+	CloudProvider       *Link           `json:"cloud_provider,omitempty"`
+	Subscription        *Link           `json:"subscription,omitempty"`
+	Nodes               *ClusterNodes   `json:"nodes,omitempty"`
+	Network             *ClusterNetwork `json:"network,omitempty"`
+	API                 *ClusterAPI     `json:"api,omitempty"`
+	Console             *ClusterConsole `json:"console,omitempty"`
+	CCS                 *ClusterCCS     `json:"ccs,omitempty"`
+	AWS                 *ClusterAWS     `json:"aws,omitempty"`
+	Version             *ClusterVersion `json:"version,omitempty"`
+}
+
+// ClusterList represents a page of clusters
+type ClusterList struct {
+	Page  int        `json:"page"`
+	Size  int        `json:"size"`
+	Total int        `json:"total"`
+	Items []*Cluster `json:"items"`
+}
+
+// ClusterStatus represents the installation status of a cluster
+type ClusterStatus struct {
+	State                 string `json:"state"`
+	Description           string `json:"description,omitempty"`
+	ProvisionErrorMessage string `json:"provision_error_message,omitempty"`
+}
+
+// ClusterAdmin represents the credentials of the kubeadmin user
+type ClusterAdmin struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// ClusterCredentials represents the admin credentials of a cluster
+type ClusterCredentials struct {
+	Kubeconfig string        `json:"kubeconfig"`
+	Admin      *ClusterAdmin `json:"admin,omitempty"`
+}
+
+// ClusterLog represents a log of a cluster, e.g. the installation log
+type ClusterLog struct {
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content"`
+}
+
+// AddonParameter represents a parameter passed to an addon installation
+type AddonParameter struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// AddonParameters represents the list of parameters of an addon installation
+type AddonParameters struct {
+	Items []*AddonParameter `json:"items"`
+}
+
+// AddonInstallation represents an addon installed on a cluster
+type AddonInstallation struct {
+	ID         string           `json:"id,omitempty"`
+	Addon      *Link            `json:"addon"`
+	State      string           `json:"state,omitempty"`
+	Parameters *AddonParameters `json:"parameters,omitempty"`
+}
+
+// UpgradePolicy represents a scheduled upgrade of a cluster
+type UpgradePolicy struct {
+	ID           string `json:"id,omitempty"`
+	ClusterID    string `json:"cluster_id,omitempty"`
+	ScheduleType string `json:"schedule_type"`
+	UpgradeType  string `json:"upgrade_type"`
+	Version      string `json:"version"`
+	NextRun      string `json:"next_run,omitempty"`
+}
+
+// UpgradePolicyState represents the state of an upgrade policy
+type UpgradePolicyState struct {
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+// ClustersManager manages the OSD clusters in OCM
+// See https://api.openshift.com/#/default/get_api_clusters_mgmt_v1_clusters
+type ClustersManager interface {
+	List(ctx context.Context, search string) (*ClusterList, error)
+	Create(ctx context.Context, cluster *Cluster) (*Cluster, error)
+	Get(ctx context.Context, clusterID string) (*Cluster, error)
+	Delete(ctx context.Context, clusterID string) error
+	GetStatus(ctx context.Context, clusterID string) (*ClusterStatus, error)
+	GetCredentials(ctx context.Context, clusterID string) (*ClusterCredentials, error)
+	GetInstallLog(ctx context.Context, clusterID string) (*ClusterLog, error)
+	InstallAddon(ctx context.Context, clusterID string, addon *AddonInstallation) (*AddonInstallation, error)
+	GetAddon(ctx context.Context, clusterID string, addonID string) (*AddonInstallation, error)
+	CreateUpgradePolicy(ctx context.Context, clusterID string, policy *UpgradePolicy) (*UpgradePolicy, error)
+	GetUpgradePolicyState(ctx context.Context, clusterID string, policyID string) (*UpgradePolicyState, error)
+}
+
+type ClustersService service
+
+// List returns the clusters matching the given search query, e.g. "name = 'my-cluster'"
+func (s *ClustersService) List(ctx context.Context, search string) (*ClusterList, error) {
+	u := "api/clusters_mgmt/v1/clusters"
+	if search != "" {
+		u = fmt.Sprintf("%s?search=%s", u, url.QueryEscape(search))
+	}
+	list := &ClusterList{}
+	if err := s.do(ctx, "GET", u, nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Create sends a request to create a new cluster
+func (s *ClustersService) Create(ctx context.Context, cluster *Cluster) (*Cluster, error) {
+	created := &Cluster{}
+	if err := s.do(ctx, "POST", "api/clusters_mgmt/v1/clusters", cluster, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Get returns the cluster with the given id
+func (s *ClustersService) Get(ctx context.Context, clusterID string) (*Cluster, error) {
+	cluster := &Cluster{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s", clusterID), nil, cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// Delete sends a request to delete the cluster with the given id
+func (s *ClustersService) Delete(ctx context.Context, clusterID string) error {
+	return s.do(ctx, "DELETE", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s", clusterID), nil, nil)
+}
+
+// GetStatus returns the installation status of the cluster
+func (s *ClustersService) GetStatus(ctx context.Context, clusterID string) (*ClusterStatus, error) {
+	status := &ClusterStatus{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/status", clusterID), nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetCredentials returns the kubeconfig and the kubeadmin credentials of the cluster
+func (s *ClustersService) GetCredentials(ctx context.Context, clusterID string) (*ClusterCredentials, error) {
+	creds := &ClusterCredentials{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/credentials", clusterID), nil, creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// GetInstallLog returns the installation log of the cluster
+func (s *ClustersService) GetInstallLog(ctx context.Context, clusterID string) (*ClusterLog, error) {
+	log := &ClusterLog{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/logs/install", clusterID), nil, log); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// InstallAddon sends a request to install the addon on the cluster
+func (s *ClustersService) InstallAddon(ctx context.Context, clusterID string, addon *AddonInstallation) (*AddonInstallation, error) {
+	installed := &AddonInstallation{}
+	if err := s.do(ctx, "POST", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/addons", clusterID), addon, installed); err != nil {
+		return nil, err
+	}
+	return installed, nil
+}
+
+// GetAddon returns the installation of the addon on the cluster
+func (s *ClustersService) GetAddon(ctx context.Context, clusterID string, addonID string) (*AddonInstallation, error) {
+	addon := &AddonInstallation{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/addons/%s", clusterID, addonID), nil, addon); err != nil {
+		return nil, err
+	}
+	return addon, nil
+}
+
+// CreateUpgradePolicy schedules an upgrade of the cluster
+func (s *ClustersService) CreateUpgradePolicy(ctx context.Context, clusterID string, policy *UpgradePolicy) (*UpgradePolicy, error) {
+	created := &UpgradePolicy{}
+	if err := s.do(ctx, "POST", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/upgrade_policies", clusterID), policy, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetUpgradePolicyState returns the state of the upgrade policy, e.g. "scheduled", "started" or "completed"
+func (s *ClustersService) GetUpgradePolicyState(ctx context.Context, clusterID string, policyID string) (*UpgradePolicyState, error) {
+	state := &UpgradePolicyState{}
+	if err := s.do(ctx, "GET", fmt.Sprintf("api/clusters_mgmt/v1/clusters/%s/upgrade_policies/%s/state", clusterID, policyID), nil, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *ClustersService) do(ctx context.Context, method string, u string, body interface{}, v interface{}) error {
+	req, err := s.client.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, req, v)
+	return err
+}
@@ -0,0 +1,125 @@
+package ocm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestClustersService_List(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		if got := r.URL.Query().Get("search"); got != "name = 'rhoam-test'" {
+			t.Errorf("unexpected search query: %s", got)
+		}
+		fmt.Fprint(w, `{"kind":"ClusterList","page":1,"size":1,"total":1,"items":[{"id":"abc","name":"rhoam-test","state":"ready"}]}`)
+	})
+
+	list, err := client.Clusters.List(context.TODO(), "name = 'rhoam-test'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ClusterList{Page: 1, Size: 1, Total: 1, Items: []*Cluster{{ID: "abc", Name: "rhoam-test", State: "ready"}}}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("Clusters.List returned %+v, want %+v", list, want)
+	}
+}
+
+func TestClustersService_Create(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["name"] != "rhoam-test" || body["region"].(map[string]interface{})["id"] != "eu-west-1" {
+			t.Errorf("unexpected request body: %v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"abc","name":"rhoam-test","state":"pending","subscription":{"id":"sub"}}`)
+	})
+
+	cluster, err := client.Clusters.Create(context.TODO(), &Cluster{Name: "rhoam-test", Region: &Link{ID: "eu-west-1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &Cluster{ID: "abc", Name: "rhoam-test", State: "pending", Subscription: &Link{ID: "sub"}}
+	if !reflect.DeepEqual(cluster, want) {
+		t.Errorf("Clusters.Create returned %+v, want %+v", cluster, want)
+	}
+}
+
+func TestClustersService_GetCredentials(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters/abc/credentials", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"kubeconfig":"apiVersion: v1","admin":{"user":"kubeadmin","password":"secret"}}`)
+	})
+
+	creds, err := client.Clusters.GetCredentials(context.TODO(), "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ClusterCredentials{Kubeconfig: "apiVersion: v1", Admin: &ClusterAdmin{User: "kubeadmin", Password: "secret"}}
+	if !reflect.DeepEqual(creds, want) {
+		t.Errorf("Clusters.GetCredentials returned %+v, want %+v", creds, want)
+	}
+}
+
+func TestClustersService_InstallAddon(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters/abc/addons", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		addon := &AddonInstallation{}
+		if err := json.NewDecoder(r.Body).Decode(addon); err != nil {
+			t.Fatal(err)
+		}
+		if addon.Addon.ID != "managed-api-service" || len(addon.Parameters.Items) != 1 {
+			t.Errorf("unexpected request body: %+v", addon)
+		}
+		fmt.Fprint(w, `{"id":"managed-api-service","addon":{"id":"managed-api-service"},"state":"installing"}`)
+	})
+
+	addon, err := client.Clusters.InstallAddon(context.TODO(), "abc", &AddonInstallation{
+		Addon:      &Link{ID: "managed-api-service"},
+		Parameters: &AddonParameters{Items: []*AddonParameter{{ID: "cidr-range", Value: "10.1.0.0/26"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addon.State != "installing" {
+		t.Errorf("Clusters.InstallAddon returned %+v", addon)
+	}
+}
+
+func TestClustersService_Delete(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	deleted := false
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters/abc", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := client.Clusters.Delete(context.TODO(), "abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Error("Clusters.Delete did not send the request")
+	}
+}
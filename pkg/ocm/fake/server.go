@@ -0,0 +1,374 @@
+// Package fake provides an in-process stand-in for the OCM API that can be used
+// to test the delorean cluster commands without a real OCM account.
+//
+// Clusters, addon installations, upgrades and deletions progress to their final
+// state after a configurable number of polls, so the waiting logic of the
+// commands can be exercised end-to-end.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/integr8ly/delorean/pkg/ocm"
+)
+
+const (
+	defaultOpenshiftVersion = "4.10.3"
+	clusterStatePending     = "pending"
+	clusterStateInstalling  = "installing"
+	addonStateInstalling    = "installing"
+	upgradeStateScheduled   = "scheduled"
+	upgradeStateStarted     = "started"
+	upgradeStateCompleted   = "completed"
+)
+
+var searchRegexp = regexp.MustCompile(`^\s*name\s+(=|like)\s+'([^']*)'\s*$`)
+
+type clusterState struct {
+	cluster  *ocm.Cluster
+	polls    int
+	deleted  bool
+	addons   map[string]*addonState
+	policies map[string]*upgradeState
+}
+
+type addonState struct {
+	addon *ocm.AddonInstallation
+	polls int
+}
+
+type upgradeState struct {
+	policy *ocm.UpgradePolicy
+	polls  int
+}
+
+// Server is a fake OCM API server keeping the clusters in memory
+type Server struct {
+	*httptest.Server
+
+	// PollsUntilDone is the number of times a cluster, addon, upgrade or deletion
+	// has to be polled before it reaches its final state
+	PollsUntilDone int
+	// Kubeconfig is returned in the credentials of every cluster
+	Kubeconfig string
+
+	mu       sync.Mutex
+	nextID   int
+	clusters map[string]*clusterState
+}
+
+// NewServer starts a new fake OCM server. The server must be closed with Close
+func NewServer() *Server {
+	s := &Server{
+		PollsUntilDone: 2,
+		Kubeconfig:     "apiVersion: v1\nkind: Config\n",
+		clusters:       map[string]*clusterState{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Cluster returns the cluster with the given id, or nil if it doesn't exist
+func (s *Server) Cluster(id string) *ocm.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clusters[id]; ok && !c.deleted {
+		return c.cluster
+	}
+	return nil
+}
+
+// Clusters returns all the clusters that have not been deleted
+func (s *Server) Clusters() []*ocm.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var clusters []*ocm.Cluster
+	for i := 1; i <= s.nextID; i++ {
+		if c, ok := s.clusters[clusterID(i)]; ok && !c.deleted {
+			clusters = append(clusters, c.cluster)
+		}
+	}
+	return clusters
+}
+
+// Addon returns the installation of the addon on the cluster, or nil if it's not installed
+func (s *Server) Addon(clusterID string, addonID string) *ocm.AddonInstallation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clusters[clusterID]; ok {
+		if a, ok := c.addons[addonID]; ok {
+			return a.addon
+		}
+	}
+	return nil
+}
+
+func clusterID(i int) string {
+	return fmt.Sprintf("cluster%d", i)
+}
+
+// serve implements the subset of the OCM API used by delorean
+// See https://api.openshift.com
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.Trim(r.URL.Path, "/")
+	if strings.HasPrefix(path, "api/accounts_mgmt/v1/subscriptions/") {
+		s.getSubscription(w, r, strings.TrimPrefix(path, "api/accounts_mgmt/v1/subscriptions/"))
+		return
+	}
+	if !strings.HasPrefix(path, "api/clusters_mgmt/v1/clusters") {
+		http.NotFound(w, r)
+		return
+	}
+
+	// clusters/{id}/{resource}/{resourceID}/{subresource}
+	parts := strings.Split(strings.TrimPrefix(path, "api/clusters_mgmt/v1/"), "/")
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listClusters(w, r)
+		case http.MethodPost:
+			s.createCluster(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	c, ok := s.clusters[parts[1]]
+	if !ok || c.deleted {
+		writeError(w, http.StatusNotFound, "CLUSTERS-MGMT-404", fmt.Sprintf("Cluster '%s' not found", parts[1]))
+		return
+	}
+	resource := parts[2:]
+
+	switch {
+	case len(resource) == 0 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, c.cluster)
+	case len(resource) == 0 && r.Method == http.MethodDelete:
+		c.cluster.State = ocm.ClusterStateUninstalling
+		c.polls = 0
+		w.WriteHeader(http.StatusNoContent)
+	case len(resource) == 1 && resource[0] == "status":
+		s.advanceCluster(c)
+		writeJSON(w, http.StatusOK, &ocm.ClusterStatus{State: c.cluster.State})
+	case len(resource) == 1 && resource[0] == "credentials":
+		writeJSON(w, http.StatusOK, &ocm.ClusterCredentials{
+			Kubeconfig: s.Kubeconfig,
+			Admin:      &ocm.ClusterAdmin{User: "kubeadmin", Password: "password-" + c.cluster.ID},
+		})
+	case len(resource) == 2 && resource[0] == "logs" && resource[1] == "install":
+		writeJSON(w, http.StatusOK, &ocm.ClusterLog{ID: "install", Content: fmt.Sprintf("installation log of %s\n", c.cluster.Name)})
+	case len(resource) == 1 && resource[0] == "addons" && r.Method == http.MethodPost:
+		s.installAddon(w, r, c)
+	case len(resource) == 2 && resource[0] == "addons" && r.Method == http.MethodGet:
+		a, ok := c.addons[resource[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "CLUSTERS-MGMT-404", fmt.Sprintf("Add-on '%s' not installed", resource[1]))
+			return
+		}
+		if a.polls++; a.polls >= s.PollsUntilDone {
+			a.addon.State = ocm.AddonStateReady
+		}
+		writeJSON(w, http.StatusOK, a.addon)
+	case len(resource) == 1 && resource[0] == "upgrade_policies" && r.Method == http.MethodPost:
+		s.createUpgradePolicy(w, r, c)
+	case len(resource) == 3 && resource[0] == "upgrade_policies" && resource[2] == "state":
+		u, ok := c.policies[resource[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		state := upgradeStateScheduled
+		if u.polls++; u.polls >= s.PollsUntilDone {
+			state = upgradeStateCompleted
+			c.cluster.OpenshiftVersion = u.policy.Version
+			c.cluster.Version = newClusterVersion(u.policy.Version)
+		} else if u.polls > 1 {
+			state = upgradeStateStarted
+		}
+		writeJSON(w, http.StatusOK, &ocm.UpgradePolicyState{Value: state})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// advanceCluster moves the cluster to the next state after enough polls
+func (s *Server) advanceCluster(c *clusterState) {
+	c.polls++
+	switch c.cluster.State {
+	case clusterStatePending:
+		c.cluster.State = clusterStateInstalling
+	case clusterStateInstalling:
+		if c.polls >= s.PollsUntilDone {
+			c.cluster.State = ocm.ClusterStateReady
+		}
+	case ocm.ClusterStateUninstalling:
+		if c.polls >= s.PollsUntilDone {
+			c.deleted = true
+		}
+	}
+}
+
+func (s *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	var name, op string
+	if search := r.URL.Query().Get("search"); search != "" {
+		m := searchRegexp.FindStringSubmatch(search)
+		if m == nil {
+			writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", fmt.Sprintf("unsupported search query: %s", search))
+			return
+		}
+		op, name = m[1], m[2]
+	}
+	list := &ocm.ClusterList{Page: 1, Items: []*ocm.Cluster{}}
+	for i := 1; i <= s.nextID; i++ {
+		c, ok := s.clusters[clusterID(i)]
+		if !ok || c.deleted {
+			continue
+		}
+		if name != "" && !matchName(c.cluster.Name, op, name) {
+			continue
+		}
+		list.Items = append(list.Items, c.cluster)
+	}
+	list.Size = len(list.Items)
+	list.Total = len(list.Items)
+	writeJSON(w, http.StatusOK, list)
+}
+
+func matchName(clusterName string, op string, name string) bool {
+	if op == "=" {
+		return clusterName == name
+	}
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(name), "%", ".*") + "$"
+	matched, _ := regexp.MatchString(pattern, clusterName)
+	return matched
+}
+
+func (s *Server) createCluster(w http.ResponseWriter, r *http.Request) {
+	cluster := &ocm.Cluster{}
+	if err := json.NewDecoder(r.Body).Decode(cluster); err != nil {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", err.Error())
+		return
+	}
+	if cluster.Name == "" {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", "Cluster name is required")
+		return
+	}
+	for _, c := range s.clusters {
+		if !c.deleted && c.cluster.Name == cluster.Name {
+			writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", fmt.Sprintf("Cluster name '%s' already exists", cluster.Name))
+			return
+		}
+	}
+
+	s.nextID++
+	id := clusterID(s.nextID)
+	cluster.ID = id
+	cluster.State = clusterStatePending
+	cluster.InfraID = cluster.Name + "-infra"
+	cluster.Subscription = &ocm.Link{Kind: "SubscriptionLink", ID: "sub-" + id}
+	cluster.CloudProvider = &ocm.Link{ID: "aws"}
+	cluster.API = &ocm.ClusterAPI{URL: fmt.Sprintf("https://api.%s.example.com:6443", cluster.Name), Listening: "external"}
+	cluster.Console = &ocm.ClusterConsole{URL: fmt.Sprintf("https://console-openshift-console.apps.%s.example.com", cluster.Name)}
+	version := defaultOpenshiftVersion
+	if cluster.Version != nil && cluster.Version.ID != "" {
+		version = strings.TrimPrefix(cluster.Version.ID, "openshift-v")
+	}
+	cluster.OpenshiftVersion = version
+	cluster.Version = newClusterVersion(version)
+
+	s.clusters[id] = &clusterState{
+		cluster:  cluster,
+		addons:   map[string]*addonState{},
+		policies: map[string]*upgradeState{},
+	}
+	writeJSON(w, http.StatusCreated, cluster)
+}
+
+func newClusterVersion(version string) *ocm.ClusterVersion {
+	return &ocm.ClusterVersion{
+		Kind:              "Version",
+		ID:                "openshift-v" + version,
+		RawID:             version,
+		AvailableUpgrades: []string{version + "-1"},
+	}
+}
+
+func (s *Server) installAddon(w http.ResponseWriter, r *http.Request, c *clusterState) {
+	addon := &ocm.AddonInstallation{}
+	if err := json.NewDecoder(r.Body).Decode(addon); err != nil || addon.Addon == nil || addon.Addon.ID == "" {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", "Add-on id is required")
+		return
+	}
+	if c.cluster.State != ocm.ClusterStateReady {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", fmt.Sprintf("Cluster '%s' is not ready", c.cluster.ID))
+		return
+	}
+	if _, ok := c.addons[addon.Addon.ID]; ok {
+		writeError(w, http.StatusConflict, "CLUSTERS-MGMT-409", fmt.Sprintf("Add-on '%s' is already installed", addon.Addon.ID))
+		return
+	}
+	addon.ID = addon.Addon.ID
+	addon.State = addonStateInstalling
+	c.addons[addon.ID] = &addonState{addon: addon}
+	writeJSON(w, http.StatusCreated, addon)
+}
+
+func (s *Server) createUpgradePolicy(w http.ResponseWriter, r *http.Request, c *clusterState) {
+	policy := &ocm.UpgradePolicy{}
+	if err := json.NewDecoder(r.Body).Decode(policy); err != nil || policy.Version == "" {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", "Upgrade version is required")
+		return
+	}
+	available := false
+	for _, v := range c.cluster.Version.AvailableUpgrades {
+		available = available || v == policy.Version
+	}
+	if !available {
+		writeError(w, http.StatusBadRequest, "CLUSTERS-MGMT-400", fmt.Sprintf("Version '%s' is not an available upgrade", policy.Version))
+		return
+	}
+	policy.ID = fmt.Sprintf("policy%d", len(c.policies)+1)
+	policy.ClusterID = c.cluster.ID
+	c.policies[policy.ID] = &upgradeState{policy: policy}
+	writeJSON(w, http.StatusCreated, policy)
+}
+
+func (s *Server) getSubscription(w http.ResponseWriter, r *http.Request, id string) {
+	for _, c := range s.clusters {
+		if c.cluster.Subscription.ID != id {
+			continue
+		}
+		metrics := &ocm.SubscriptionMetrics{OpenshiftVersion: c.cluster.OpenshiftVersion}
+		if c.cluster.State == ocm.ClusterStateReady {
+			metrics.HealthState = ocm.SubscriptionHealthStateHealthy
+		}
+		writeJSON(w, http.StatusOK, &ocm.Subscription{
+			ID:        id,
+			ClusterID: c.cluster.ID,
+			Status:    "Active",
+			Metrics:   []*ocm.SubscriptionMetrics{metrics},
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, "ACCT-MGMT-404", fmt.Sprintf("Subscription '%s' not found", id))
+}
+
+func writeError(w http.ResponseWriter, status int, code string, reason string) {
+	writeJSON(w, status, map[string]string{"kind": "Error", "code": code, "reason": reason})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,64 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	SubscriptionHealthStateHealthy = "healthy"
+	SubscriptionUpgradeCompleted   = "complete"
+)
+
+// SubscriptionUpgrade represents the upgrade metrics of a subscription
+type SubscriptionUpgrade struct {
+	Available bool   `json:"available"`
+	State     string `json:"state,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
+
+// SubscriptionMetrics represents the metrics reported by a cluster
+type SubscriptionMetrics struct {
+	HealthState      string               `json:"health_state,omitempty"`
+	OpenshiftVersion string               `json:"openshift_version,omitempty"`
+	Upgrade          *SubscriptionUpgrade `json:"upgrade,omitempty"`
+}
+
+// Subscription represents the subscription of a cluster in OCM
+type Subscription struct {
+	ID        string                 `json:"id"`
+	ClusterID string                 `json:"cluster_id,omitempty"`
+	Status    string                 `json:"status,omitempty"`
+	Metrics   []*SubscriptionMetrics `json:"metrics,omitempty"`
+}
+
+// SubscriptionsManager manages the cluster subscriptions in OCM
+// See https://api.openshift.com/#/default/get_api_accounts_mgmt_v1_subscriptions__id_
+type SubscriptionsManager interface {
+	Get(ctx context.Context, subscriptionID string) (*Subscription, error)
+}
+
+type SubscriptionsService service
+
+// Get returns the subscription with the given id
+func (s *SubscriptionsService) Get(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	u := fmt.Sprintf("api/accounts_mgmt/v1/subscriptions/%s", subscriptionID)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{}
+	_, err = s.client.Do(ctx, req, sub)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// HealthState returns the health state reported in the subscription metrics
+func (s *Subscription) HealthState() string {
+	if len(s.Metrics) == 0 {
+		return ""
+	}
+	return s.Metrics[0].HealthState
+}
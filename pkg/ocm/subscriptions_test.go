@@ -0,0 +1,26 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSubscriptionsService_Get(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/accounts_mgmt/v1/subscriptions/sub", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id":"sub","cluster_id":"abc","status":"Active","metrics":[{"health_state":"healthy","upgrade":{"available":false,"state":"complete"}}]}`)
+	})
+
+	sub, err := client.Subscriptions.Get(context.TODO(), "sub")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub.HealthState() != SubscriptionHealthStateHealthy || sub.Metrics[0].Upgrade.State != SubscriptionUpgradeCompleted {
+		t.Errorf("Subscriptions.Get returned %+v", sub)
+	}
+}
@@ -0,0 +1,57 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	userAgent = "delorean"
+	// limit of the error message read from the response
+	maxErrorMessageLength = 256
+)
+
+// Client sends samples to an endpoint implementing the Prometheus remote write protocol, like Prometheus with the
+// remote write receiver enabled, Thanos Receive or Cortex
+type Client struct {
+	httpClient *http.Client
+	URL        string
+}
+
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{httpClient: httpClient, URL: url}
+}
+
+// Write sends the time series in a single snappy compressed request
+func (c *Client) Write(ctx context.Context, r *WriteRequest) error {
+	body := snappy.Encode(nil, r.Marshal())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorMessageLength))
+		return fmt.Errorf("remote write to %s failed: %s: %s", c.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// unmarshal decodes a request encoded with Marshal
+func unmarshal(b []byte) (*WriteRequest, error) {
+	r := &WriteRequest{}
+	err := consumeMessages(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		ts := TimeSeries{}
+		err := consumeMessages(v, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1:
+				l := Label{}
+				err := consumeMessages(v, func(num protowire.Number, v []byte) error {
+					if num == 1 {
+						l.Name = string(v)
+					} else {
+						l.Value = string(v)
+					}
+					return nil
+				})
+				ts.Labels = append(ts.Labels, l)
+				return err
+			case 2:
+				s := Sample{}
+				for len(v) > 0 {
+					num, typ, n := protowire.ConsumeTag(v)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					v = v[n:]
+					if num == 1 && typ == protowire.Fixed64Type {
+						bits, n := protowire.ConsumeFixed64(v)
+						s.Value = math.Float64frombits(bits)
+						v = v[n:]
+					} else if num == 2 && typ == protowire.VarintType {
+						ts, n := protowire.ConsumeVarint(v)
+						s.Timestamp = int64(ts)
+						v = v[n:]
+					} else {
+						return fmt.Errorf("unexpected field %d of the sample", num)
+					}
+				}
+				ts.Samples = append(ts.Samples, s)
+			}
+			return nil
+		})
+		r.Timeseries = append(r.Timeseries, ts)
+		return err
+	})
+	return r, err
+}
+
+// consumeMessages calls f with each length delimited field of the message
+func consumeMessages(b []byte, f func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			return fmt.Errorf("unexpected type %d of the field %d", typ, num)
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestClient_Write(t *testing.T) {
+	var received *WriteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "unexpected headers", http.StatusBadRequest)
+			return
+		}
+		compressed, _ := ioutil.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if received, err = unmarshal(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := &WriteRequest{Timeseries: []TimeSeries{
+		{
+			Labels:  []Label{{Name: "product", Value: "3scale"}, {Name: "__name__", Value: "rhmi_product_downtime"}},
+			Samples: []Sample{{Value: 2.5, Timestamp: 1600000030000}, {Value: 10, Timestamp: 1600000000000}},
+		},
+		{
+			Labels:  []Label{{Name: "__name__", Value: "rhmi_product_downtime"}, {Name: "product", Value: "rhsso"}},
+			Samples: []Sample{{Value: 0, Timestamp: 1600000000000}},
+		},
+	}}
+	if err := NewClient(server.URL, nil).Write(context.TODO(), r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &WriteRequest{Timeseries: []TimeSeries{
+		{
+			Labels:  []Label{{Name: "__name__", Value: "rhmi_product_downtime"}, {Name: "product", Value: "3scale"}},
+			Samples: []Sample{{Value: 10, Timestamp: 1600000000000}, {Value: 2.5, Timestamp: 1600000030000}},
+		},
+		{
+			Labels:  []Label{{Name: "__name__", Value: "rhmi_product_downtime"}, {Name: "product", Value: "rhsso"}},
+			Samples: []Sample{{Value: 0, Timestamp: 1600000000000}},
+		},
+	}}
+	if !reflect.DeepEqual(received, expected) {
+		t.Fatalf("unexpected request %+v", received)
+	}
+}
+
+func TestClient_WriteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL, nil).Write(context.TODO(), &WriteRequest{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if expected := fmt.Sprintf("remote write to %s failed: 400 Bad Request: out of order sample", server.URL); err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
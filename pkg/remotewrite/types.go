@@ -0,0 +1,70 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WriteRequest, TimeSeries, Label and Sample are the messages of the remote write protocol defined in
+// prompb/types.proto and prompb/remote.proto of Prometheus, encoded with protowire to avoid depending
+// on the Prometheus server module
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value float64
+	// Timestamp in milliseconds
+	Timestamp int64
+}
+
+// Marshal encodes the request in the protobuf format. The labels of each time series are sorted by name and the
+// samples by timestamp, as required by the receivers
+func (r *WriteRequest) Marshal() []byte {
+	var b []byte
+	for _, ts := range r.Timeseries {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts.marshal())
+	}
+	return b
+}
+
+func (ts *TimeSeries) marshal() []byte {
+	labels := append([]Label{}, ts.Labels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	samples := append([]Sample{}, ts.Samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp < samples[j].Timestamp })
+
+	var b []byte
+	for _, l := range labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.Name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.Value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	for _, s := range samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	return b
+}
@@ -0,0 +1,316 @@
+package s3processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+const (
+	defaultWorkers   = 10
+	defaultDelimiter = "/"
+)
+
+// ErrSkip is returned by a sink to leave an object as not processed, so that it's processed again by the next run
+var ErrSkip = errors.New("object skipped")
+
+// Object is an object of the bucket to process
+type Object struct {
+	Key          string
+	LastModified time.Time
+	Tags         []*s3.Tag
+	// File is the object downloaded to a temporary file if the processor downloads the objects. It's removed
+	// once the object is processed
+	File string
+}
+
+// HasTag returns true if the object has the tag with the value
+func (o *Object) HasTag(key string, value string) bool {
+	return hasTag(o.Tags, key, value)
+}
+
+// Sink processes the objects of the bucket, like importing them to ReportPortal or Polarion
+type Sink interface {
+	Process(ctx context.Context, object *Object) error
+}
+
+// SinkFunc is a function used as a Sink
+type SinkFunc func(ctx context.Context, object *Object) error
+
+func (f SinkFunc) Process(ctx context.Context, object *Object) error {
+	return f(ctx, object)
+}
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type Options struct {
+	Bucket string
+	// Prefix of the keys of the objects to list
+	Prefix string
+	// Delimiter groups the keys when listing, defaults to "/" so that the sub directories like archive/ are
+	// not listed
+	Delimiter string
+	// Filter returns false for the keys of the objects to skip, like the ones which are not archives
+	Filter func(key string) bool
+	// RequiredTags are the tags that the objects must have to be processed
+	RequiredTags []Tag
+	// ProcessedTag is added to the objects once they are processed, and the objects with the tag are not
+	// processed again. There is no bookkeeping if it's not set
+	ProcessedTag *Tag
+	// NoTagging doesn't add the processed tag, for testing purposes
+	NoTagging bool
+	// Download the objects to a temporary file before processing them
+	Download bool
+	// Workers is the number of objects processed in parallel, defaults to 10
+	Workers int
+	// MaxObjects is the maximum number of objects processed by a run, the others are left for the next runs.
+	// The objects are processed from the least recently modified, and only the objects processed successfully
+	// count. There is no limit if it's 0
+	MaxObjects int
+	// Since excludes the objects modified before, if set
+	Since time.Time
+}
+
+// Processor lists the objects of a bucket and processes them with a sink, keeping track of the processed objects
+// with a tag
+type Processor struct {
+	s3         s3iface.S3API
+	downloader s3manageriface.DownloaderAPI
+	opts       Options
+}
+
+func NewProcessor(s3 s3iface.S3API, downloader s3manageriface.DownloaderAPI, opts Options) *Processor {
+	if opts.Delimiter == "" {
+		opts.Delimiter = defaultDelimiter
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	return &Processor{s3: s3, downloader: downloader, opts: opts}
+}
+
+type Status string
+
+const (
+	StatusProcessed        Status = "processed"
+	StatusAlreadyProcessed Status = "already processed"
+	StatusSkipped          Status = "skipped"
+	StatusFailed           Status = "failed"
+)
+
+// Result is the outcome of the processing of an object. Err is set if the status is failed
+type Result struct {
+	Key    string
+	Status Status
+	Err    error
+}
+
+type Results []Result
+
+// Count returns the number of objects with the status
+func (r Results) Count(status Status) int {
+	n := 0
+	for _, result := range r {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error with the errors of all the objects which failed, or nil
+func (r Results) Err() error {
+	var failed []string
+	for _, result := range r {
+		if result.Status == StatusFailed {
+			failed = append(failed, fmt.Sprintf("%s: %v", result.Key, result.Err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to process %d objects: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// List returns all the objects of the bucket with the prefix modified since the time if set, going through all
+// the pages
+func (p *Processor) List(ctx context.Context) ([]*s3.Object, error) {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(p.opts.Bucket), Delimiter: aws.String(p.opts.Delimiter)}
+	if p.opts.Prefix != "" {
+		input.Prefix = aws.String(p.opts.Prefix)
+	}
+	var objects []*s3.Object
+	err := p.s3.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			if !p.opts.Since.IsZero() && o.LastModified != nil && o.LastModified.Before(p.opts.Since) {
+				continue
+			}
+			objects = append(objects, o)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// Run processes the objects of the bucket with the sink, from the least recently modified. The objects are
+// processed in parallel and the error of an object doesn't stop the processing of the others. With MaxObjects,
+// the objects are processed in batches of the objects left to process, so that the same objects are processed
+// whatever the order in which the workers complete. The returned error is only set if the bucket can't be listed
+func (p *Processor) Run(ctx context.Context, sink Sink) (Results, error) {
+	fmt.Println(fmt.Sprintf("[All] Listing objects from bucket %s", p.opts.Bucket))
+	objects, err := p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println(fmt.Sprintf("[All] Found %d objects to process", len(objects)))
+	sort.SliceStable(objects, func(i, j int) bool {
+		return aws.TimeValue(objects[i].LastModified).Before(aws.TimeValue(objects[j].LastModified))
+	})
+
+	var results Results
+	for len(objects) > 0 {
+		batch := objects
+		if p.opts.MaxObjects > 0 {
+			left := p.opts.MaxObjects - results.Count(StatusProcessed)
+			if left <= 0 {
+				break
+			}
+			if left < len(batch) {
+				batch = batch[:left]
+			}
+		}
+		batchResults, err := p.runBatch(ctx, sink, batch)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batchResults...)
+		objects = objects[len(batch):]
+	}
+	// the objects left once the limit is reached are not looked at, to not get their tags
+	for _, o := range objects {
+		fmt.Println(fmt.Sprintf("[%s] Skip object as the limit of %d objects is reached", *o.Key, p.opts.MaxObjects))
+		results = append(results, Result{Key: *o.Key, Status: StatusSkipped})
+	}
+	fmt.Println(fmt.Sprintf("[All] Process completed: %d processed, %d already processed, %d skipped, %d failed",
+		results.Count(StatusProcessed), results.Count(StatusAlreadyProcessed), results.Count(StatusSkipped), results.Count(StatusFailed)))
+	return results, nil
+}
+
+// runBatch processes the objects in parallel and returns their results in the same order
+func (p *Processor) runBatch(ctx context.Context, sink Sink, objects []*s3.Object) (Results, error) {
+	tasks := make([]utils.Task, len(objects))
+	for i, obj := range objects {
+		o := obj
+		tasks[i] = func() (utils.TaskResult, error) {
+			result := Result{Key: *o.Key}
+			result.Status, result.Err = p.process(ctx, sink, o)
+			if result.Err != nil {
+				fmt.Println(fmt.Sprintf("[%s] Failed to process the object: %v", *o.Key, result.Err))
+			}
+			return result, nil
+		}
+	}
+	taskResults, err := utils.ParallelLimit(ctx, tasks, p.opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+	results := make(Results, len(taskResults))
+	for i, r := range taskResults {
+		results[i] = r.(Result)
+	}
+	return results, nil
+}
+
+func (p *Processor) process(ctx context.Context, sink Sink, o *s3.Object) (Status, error) {
+	key := *o.Key
+	if p.opts.Filter != nil && !p.opts.Filter(key) {
+		fmt.Println(fmt.Sprintf("[%s] Skipping processing object", key))
+		return StatusSkipped, nil
+	}
+
+	fmt.Println(fmt.Sprintf("[%s] Start processing object", key))
+	tags, err := p.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(p.opts.Bucket),
+		Key:    o.Key,
+	})
+	if err != nil {
+		return StatusFailed, err
+	}
+	if p.opts.ProcessedTag != nil && hasTag(tags.TagSet, p.opts.ProcessedTag.Key, p.opts.ProcessedTag.Value) {
+		fmt.Println(fmt.Sprintf("[%s] File in bucket %s has been processed already. Ignored.", key, p.opts.Bucket))
+		return StatusAlreadyProcessed, nil
+	}
+	for _, t := range p.opts.RequiredTags {
+		if !hasTag(tags.TagSet, t.Key, t.Value) {
+			fmt.Println(fmt.Sprintf("[%s] Skip object as it doesn't have the required tags", key))
+			return StatusSkipped, nil
+		}
+	}
+
+	object := &Object{Key: key, Tags: tags.TagSet}
+	if o.LastModified != nil {
+		object.LastModified = *o.LastModified
+	}
+	if p.opts.Download {
+		fmt.Println(fmt.Sprintf("[%s] Downloading file from s3 bucket %s", key, p.opts.Bucket))
+		object.File, err = utils.DownloadS3ObjectToTempDir(ctx, p.downloader, p.opts.Bucket, key)
+		if err != nil {
+			return StatusFailed, err
+		}
+		defer os.Remove(object.File)
+	}
+
+	if err := sink.Process(ctx, object); err != nil {
+		if errors.Is(err, ErrSkip) {
+			fmt.Println(fmt.Sprintf("[%s] Object skipped", key))
+			return StatusSkipped, nil
+		}
+		return StatusFailed, err
+	}
+
+	if p.opts.ProcessedTag == nil {
+		return StatusProcessed, nil
+	}
+	if p.opts.NoTagging {
+		fmt.Println(fmt.Sprintf("[%s] Skip adding tags", key))
+		return StatusProcessed, nil
+	}
+	fmt.Println(fmt.Sprintf("[%s] Adding tag %s=%s to s3 object", key, p.opts.ProcessedTag.Key, p.opts.ProcessedTag.Value))
+	t := append(tags.TagSet, &s3.Tag{
+		Key:   aws.String(p.opts.ProcessedTag.Key),
+		Value: aws.String(p.opts.ProcessedTag.Value),
+	})
+	if _, err = p.s3.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(p.opts.Bucket),
+		Key:     o.Key,
+		Tagging: &s3.Tagging{TagSet: t},
+	}); err != nil {
+		return StatusFailed, err
+	}
+	return StatusProcessed, nil
+}
+
+func hasTag(tags []*s3.Tag, key string, val string) bool {
+	for _, t := range tags {
+		if *t.Key == key && *t.Value == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package s3processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+// newMockS3 returns a bucket with the objects and their tags, listed in pages of 2 objects
+func newMockS3(keys []string, tags map[string][]*s3.Tag, tagged *sync.Map) *utils.MockS3API {
+	return &utils.MockS3API{
+		ListObjsFunc: func(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+			start := 0
+			if input.ContinuationToken != nil {
+				for i, k := range keys {
+					if k == *input.ContinuationToken {
+						start = i
+					}
+				}
+			}
+			end := start + 2
+			o := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+			if end < len(keys) {
+				o.NextContinuationToken = aws.String(keys[end])
+			} else {
+				end = len(keys)
+			}
+			for _, k := range keys[start:end] {
+				o.Contents = append(o.Contents, &s3.Object{Key: aws.String(k)})
+			}
+			return o, nil
+		},
+		GetObjTaggingFunc: func(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+			return &s3.GetObjectTaggingOutput{TagSet: tags[*input.Key]}, nil
+		},
+		PutObjTaggingFunc: func(input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+			tagged.Store(*input.Key, input.Tagging.TagSet)
+			return &s3.PutObjectTaggingOutput{}, nil
+		},
+	}
+}
+
+func TestProcessor_Run(t *testing.T) {
+	keys := []string{"a.zip", "b.zip", "c.zip", "d.zip", "e.txt", "f.zip"}
+	tags := map[string][]*s3.Tag{
+		"b.zip": {{Key: aws.String("processed"), Value: aws.String("true")}},
+	}
+	var tagged sync.Map
+	downloader := &utils.MockS3Downloader{
+		DownloadFunc: func(w io.WriterAt, input *s3.GetObjectInput) (int64, error) {
+			n, err := w.WriteAt([]byte("content of "+*input.Key), 0)
+			return int64(n), err
+		},
+	}
+	p := NewProcessor(newMockS3(keys, tags, &tagged), downloader, Options{
+		Bucket:       "reports",
+		Filter:       func(key string) bool { return strings.HasSuffix(key, ".zip") },
+		ProcessedTag: &Tag{Key: "processed", Value: "true"},
+		Download:     true,
+		Workers:      2,
+	})
+
+	var lock sync.Mutex
+	var processed []string
+	results, err := p.Run(context.TODO(), SinkFunc(func(ctx context.Context, object *Object) error {
+		content, err := ioutil.ReadFile(object.File)
+		if err != nil {
+			return err
+		}
+		if string(content) != "content of "+object.Key {
+			t.Errorf("unexpected content of %s: %s", object.Key, content)
+		}
+		switch object.Key {
+		case "c.zip":
+			return errors.New("import failed")
+		case "d.zip":
+			return ErrSkip
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		processed = append(processed, object.Key)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]Status{
+		"a.zip": StatusProcessed,
+		"b.zip": StatusAlreadyProcessed,
+		"c.zip": StatusFailed,
+		"d.zip": StatusSkipped,
+		"e.txt": StatusSkipped,
+		"f.zip": StatusProcessed,
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results but got %d", len(expected), len(results))
+	}
+	for _, r := range results {
+		if r.Status != expected[r.Key] {
+			t.Errorf("expected %s to be %s but got %s", r.Key, expected[r.Key], r.Status)
+		}
+	}
+	sort.Strings(processed)
+	if strings.Join(processed, ",") != "a.zip,f.zip" {
+		t.Errorf("unexpected processed objects %v", processed)
+	}
+	var taggedKeys []string
+	tagged.Range(func(key, value interface{}) bool {
+		taggedKeys = append(taggedKeys, key.(string))
+		return true
+	})
+	sort.Strings(taggedKeys)
+	if strings.Join(taggedKeys, ",") != "a.zip,f.zip" {
+		t.Errorf("unexpected tagged objects %v", taggedKeys)
+	}
+	if err := results.Err(); err == nil || err.Error() != "failed to process 1 objects: c.zip: import failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestProcessor_RunRequiredTags(t *testing.T) {
+	keys := []string{"a.zip", "b.zip"}
+	tags := map[string][]*s3.Tag{
+		"a.zip": {{Key: aws.String("rp"), Value: aws.String("true")}, {Key: aws.String("polarion"), Value: aws.String("true")}},
+		"b.zip": {{Key: aws.String("rp"), Value: aws.String("true")}},
+	}
+	var tagged sync.Map
+	p := NewProcessor(newMockS3(keys, tags, &tagged), nil, Options{
+		Bucket:       "reports",
+		RequiredTags: []Tag{{Key: "rp", Value: "true"}, {Key: "polarion", Value: "true"}},
+	})
+	var processed []string
+	results, err := p.Run(context.TODO(), SinkFunc(func(ctx context.Context, object *Object) error {
+		if object.File != "" {
+			t.Errorf("unexpected download of %s", object.Key)
+		}
+		processed = append(processed, object.Key)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(processed, ",") != "a.zip" {
+		t.Errorf("unexpected processed objects %v", processed)
+	}
+	if results.Count(StatusProcessed) != 1 || results.Count(StatusSkipped) != 1 || results.Err() != nil {
+		t.Errorf("unexpected results %v", results)
+	}
+	tagged.Range(func(key, value interface{}) bool {
+		t.Errorf("unexpected tagging of %s", key)
+		return true
+	})
+}
+
+func TestProcessor_List(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+	p := NewProcessor(newMockS3(keys, nil, &sync.Map{}), nil, Options{Bucket: "reports"})
+	objects, err := p.List(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var listed []string
+	for _, o := range objects {
+		listed = append(listed, *o.Key)
+	}
+	if strings.Join(listed, ",") != "a,b,c,d,e" {
+		t.Fatalf("expected all the pages to be listed but got %v", listed)
+	}
+}
+
+func TestProcessor_RunLimits(t *testing.T) {
+	now := time.Now()
+	modified := map[string]time.Time{
+		"a.zip": now.Add(-10 * 24 * time.Hour),
+		"b.zip": now.Add(-2 * 24 * time.Hour),
+		"c.zip": now.Add(-24 * time.Hour),
+		"d.zip": now.Add(-time.Hour),
+		"e.zip": now.Add(-time.Minute),
+		"f.zip": now,
+	}
+	tags := map[string][]*s3.Tag{
+		"b.zip": {{Key: aws.String("processed"), Value: aws.String("true")}},
+	}
+	// listed in another order than the modification times
+	s3api := newMockS3([]string{"f.zip", "e.zip", "d.zip", "c.zip", "b.zip", "a.zip"}, tags, &sync.Map{})
+	list := s3api.ListObjsFunc
+	s3api.ListObjsFunc = func(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+		o, err := list(input)
+		for _, obj := range o.Contents {
+			obj.LastModified = aws.Time(modified[*obj.Key])
+		}
+		return o, err
+	}
+	var lock sync.Mutex
+	var tagged []string
+	getTagging := s3api.GetObjTaggingFunc
+	s3api.GetObjTaggingFunc = func(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+		lock.Lock()
+		tagged = append(tagged, *input.Key)
+		lock.Unlock()
+		return getTagging(input)
+	}
+	p := NewProcessor(s3api, nil, Options{
+		Bucket:       "reports",
+		ProcessedTag: &Tag{Key: "processed", Value: "true"},
+		Since:        now.Add(-3 * 24 * time.Hour),
+		MaxObjects:   2,
+		Workers:      4,
+	})
+
+	for run := 0; run < 2; run++ {
+		var processed []string
+		tagged = nil
+		results, err := p.Run(context.TODO(), SinkFunc(func(ctx context.Context, object *Object) error {
+			if object.Key == "c.zip" {
+				return errors.New("invalid archive")
+			}
+			lock.Lock()
+			processed = append(processed, object.Key)
+			lock.Unlock()
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// a.zip is too old, b.zip is already processed and c.zip fails, which don't count in the limit
+		sort.Strings(processed)
+		if strings.Join(processed, ",") != "d.zip,e.zip" {
+			t.Fatalf("unexpected processed objects %v", processed)
+		}
+		var keys []string
+		for _, r := range results {
+			keys = append(keys, fmt.Sprintf("%s=%s", r.Key, r.Status))
+		}
+		if strings.Join(keys, ",") != "b.zip=already processed,c.zip=failed,d.zip=processed,e.zip=processed,f.zip=skipped" {
+			t.Fatalf("unexpected results %v", keys)
+		}
+		// f.zip is not looked at once the limit is reached
+		sort.Strings(tagged)
+		if strings.Join(tagged, ",") != "b.zip,c.zip,d.zip,e.zip" {
+			t.Fatalf("unexpected tagging requests %v", tagged)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+)
+
+// serveGit implements the git smart-HTTP protocol for the bare repositories
+// See https://git-scm.com/docs/http-protocol
+func (s *Server) serveGit(w http.ResponseWriter, r *http.Request) {
+	var repo, service string
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/info/refs") && r.Method == http.MethodGet:
+		repo = strings.TrimSuffix(r.URL.Path, "/info/refs")
+		service = r.URL.Query().Get("service")
+	case strings.HasSuffix(r.URL.Path, "/"+uploadPackService) && r.Method == http.MethodPost:
+		repo = strings.TrimSuffix(r.URL.Path, "/"+uploadPackService)
+		service = uploadPackService
+	case strings.HasSuffix(r.URL.Path, "/"+receivePackService) && r.Method == http.MethodPost:
+		repo = strings.TrimSuffix(r.URL.Path, "/"+receivePackService)
+		service = receivePackService
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	ep, err := transport.NewEndpoint(s.repoDir(strings.TrimPrefix(repo, "/")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Serialize all git operations to keep the repositories consistent
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv := server.NewServer(server.NewFilesystemLoader(osfs.New("/")))
+	var sess transport.Session
+	switch service {
+	case uploadPackService:
+		sess, err = srv.NewUploadPackSession(ep, nil)
+	case receivePackService:
+		sess, err = srv.NewReceivePackSession(ep, nil)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported service %q", service), http.StatusForbidden)
+		return
+	}
+	if err == transport.ErrRepositoryNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sess.Close()
+
+	if r.Method == http.MethodGet {
+		s.advertiseReferences(w, sess, service)
+		return
+	}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-result", service))
+	switch sess := sess.(type) {
+	case transport.UploadPackSession:
+		req := packp.NewUploadPackRequest()
+		if err := req.Decode(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := sess.UploadPack(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Close()
+		resp.Encode(w)
+	case transport.ReceivePackSession:
+		req := packp.NewReferenceUpdateRequest()
+		if err := req.Decode(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, err := sess.ReceivePack(r.Context(), req)
+		if status != nil {
+			status.Encode(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) advertiseReferences(w http.ResponseWriter, sess transport.Session, service string) {
+	var ar *packp.AdvRefs
+	var err error
+	switch sess := sess.(type) {
+	case transport.UploadPackSession:
+		ar, err = sess.AdvertisedReferences()
+	case transport.ReceivePackSession:
+		ar, err = sess.AdvertisedReferences()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ar.Prefix = [][]byte{[]byte(fmt.Sprintf("# service=%s", service)), pktline.Flush}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := ar.Encode(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,221 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v30/github"
+)
+
+type gitHubState struct {
+	// pullRequests by repository full name (ex "integr8ly/integreatly-operator")
+	pullRequests map[string][]*github.PullRequest
+}
+
+func newGitHubState() *gitHubState {
+	return &gitHubState{pullRequests: map[string][]*github.PullRequest{}}
+}
+
+// PullRequests returns the pull requests created in the repository with the given name
+func (s *Server) PullRequests(name string) []*github.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*github.PullRequest{}, s.github.pullRequests[name]...)
+}
+
+// serveGitHub implements the subset of the GitHub API used by delorean
+// See https://docs.github.com/en/rest
+func (s *Server) serveGitHub(w http.ResponseWriter, r *http.Request) {
+	// /repos/{owner}/{repo}/...
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "repos" {
+		http.NotFound(w, r)
+		return
+	}
+	repo := parts[1] + "/" + parts[2]
+	resource := parts[3:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case resource[0] == "pulls" && len(resource) == 1 && r.Method == http.MethodGet:
+		s.listPullRequests(w, r, repo)
+	case resource[0] == "pulls" && len(resource) == 1 && r.Method == http.MethodPost:
+		s.createPullRequest(w, r, repo)
+	case resource[0] == "pulls" && len(resource) == 2 && r.Method == http.MethodGet:
+		if pr := s.findPullRequest(repo, resource[1]); pr != nil {
+			writeJSON(w, http.StatusOK, pr)
+			return
+		}
+		http.NotFound(w, r)
+	case resource[0] == "pulls" && len(resource) == 3 && resource[2] == "merge" && r.Method == http.MethodPut:
+		pr := s.findPullRequest(repo, resource[1])
+		if pr == nil {
+			http.NotFound(w, r)
+			return
+		}
+		pr.Merged = github.Bool(true)
+		pr.State = github.String("closed")
+		writeJSON(w, http.StatusOK, &github.PullRequestMergeResult{Merged: github.Bool(true), Message: github.String("Pull Request successfully merged")})
+	case resource[0] == "git" && len(resource) > 2 && resource[1] == "refs" && r.Method == http.MethodGet:
+		s.getRefs(w, r, repo, strings.Join(resource[2:], "/"))
+	case resource[0] == "git" && len(resource) == 2 && resource[1] == "refs" && r.Method == http.MethodPost:
+		s.createRef(w, r, repo)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) listPullRequests(w http.ResponseWriter, r *http.Request, repo string) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := r.URL.Query().Get("head")
+	base := r.URL.Query().Get("base")
+
+	result := []*github.PullRequest{}
+	for _, pr := range s.github.pullRequests[repo] {
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		if head != "" && head != pr.GetHead().GetLabel() {
+			continue
+		}
+		if base != "" && base != pr.GetBase().GetRef() {
+			continue
+		}
+		result = append(result, pr)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createPullRequest(w http.ResponseWriter, r *http.Request, repo string) {
+	req := &github.NewPullRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The head can be in the form of "owner:branch"
+	head := req.GetHead()
+	headRef := head
+	if i := strings.Index(head, ":"); i >= 0 {
+		headRef = head[i+1:]
+	} else {
+		head = strings.Split(repo, "/")[0] + ":" + head
+	}
+
+	number := len(s.github.pullRequests[repo]) + 1
+	pr := &github.PullRequest{
+		Number:  github.Int(number),
+		State:   github.String("open"),
+		Title:   req.Title,
+		Body:    req.Body,
+		Merged:  github.Bool(false),
+		HTMLURL: github.String(fmt.Sprintf("%s/%s/pull/%d", s.URL, repo, number)),
+		Head:    &github.PullRequestBranch{Label: github.String(head), Ref: github.String(headRef)},
+		Base:    &github.PullRequestBranch{Ref: req.Base},
+	}
+	s.github.pullRequests[repo] = append(s.github.pullRequests[repo], pr)
+	writeJSON(w, http.StatusCreated, pr)
+}
+
+func (s *Server) findPullRequest(repo string, number string) *github.PullRequest {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil
+	}
+	for _, pr := range s.github.pullRequests[repo] {
+		if pr.GetNumber() == n {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (s *Server) getRefs(w http.ResponseWriter, r *http.Request, repo string, ref string) {
+	gitRepo, err := git.PlainOpen(s.repoDir(repo))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	refs, err := gitRepo.References()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	prefix := "refs/" + ref
+	result := []*github.Reference{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(ref.Name().String(), prefix) {
+			result = append(result, toGitHubReference(ref))
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case len(result) == 0:
+		http.NotFound(w, r)
+	case len(result) == 1 && result[0].GetRef() == prefix:
+		writeJSON(w, http.StatusOK, result[0])
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) createRef(w http.ResponseWriter, r *http.Request, repo string) {
+	req := &struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	gitRepo, err := git.PlainOpen(s.repoDir(repo))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	name := plumbing.ReferenceName(req.Ref)
+	if _, err := gitRepo.Storer.Reference(name); err != plumbing.ErrReferenceNotFound {
+		http.Error(w, "Reference already exists", http.StatusUnprocessableEntity)
+		return
+	}
+	hash := plumbing.NewHash(req.SHA)
+	if _, err := gitRepo.Storer.EncodedObject(plumbing.AnyObject, hash); err != nil {
+		http.Error(w, "Object does not exist", http.StatusUnprocessableEntity)
+		return
+	}
+	ref := plumbing.NewHashReference(name, hash)
+	if err := gitRepo.Storer.SetReference(ref); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toGitHubReference(ref))
+}
+
+func toGitHubReference(ref *plumbing.Reference) *github.Reference {
+	return &github.Reference{
+		Ref:    github.String(ref.Name().String()),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(ref.Hash().String())},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,309 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type gitLabState struct {
+	projects       []*gitlab.Project
+	projectsByPath map[string]*gitlab.Project
+	mergeRequests  []*gitlab.MergeRequest
+	// pipelines, approvals and notes by merge request ID
+	pipelines map[int][]*gitlab.PipelineInfo
+	approvals map[int]*gitlab.MergeRequestApprovals
+	notes     map[int][]*gitlab.Note
+}
+
+func newGitLabState() *gitLabState {
+	return &gitLabState{
+		projectsByPath: map[string]*gitlab.Project{},
+		pipelines:      map[int][]*gitlab.PipelineInfo{},
+		approvals:      map[int]*gitlab.MergeRequestApprovals{},
+		notes:          map[int][]*gitlab.Note{},
+	}
+}
+
+func (s *gitLabState) addProject(name string) {
+	if _, ok := s.projectsByPath[name]; ok {
+		return
+	}
+	p := &gitlab.Project{
+		ID:                len(s.projects) + 1,
+		Name:              name[strings.LastIndex(name, "/")+1:],
+		PathWithNamespace: name,
+		DefaultBranch:     "main",
+	}
+	s.projects = append(s.projects, p)
+	s.projectsByPath[name] = p
+}
+
+func (s *gitLabState) findProject(id string) *gitlab.Project {
+	if n, err := strconv.Atoi(id); err == nil {
+		if n > 0 && n <= len(s.projects) {
+			return s.projects[n-1]
+		}
+		return nil
+	}
+	return s.projectsByPath[id]
+}
+
+func (s *gitLabState) findMergeRequest(projectID int, iid string) *gitlab.MergeRequest {
+	n, err := strconv.Atoi(iid)
+	if err != nil {
+		return nil
+	}
+	for _, mr := range s.mergeRequests {
+		if mr.ProjectID == projectID && mr.IID == n {
+			return mr
+		}
+	}
+	return nil
+}
+
+// mergeRequestResponse fixes the encoding of the labels that gitlab.Labels
+// marshals as a comma separated string while the API returns an array
+type mergeRequestResponse struct {
+	*gitlab.MergeRequest
+	Labels []string `json:"labels"`
+}
+
+func toMergeRequestResponse(mr *gitlab.MergeRequest) *mergeRequestResponse {
+	return &mergeRequestResponse{MergeRequest: mr, Labels: append([]string{}, mr.Labels...)}
+}
+
+// MergeRequests returns the merge requests targeting the project with the given name
+func (s *Server) MergeRequests(name string) []*gitlab.MergeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []*gitlab.MergeRequest{}
+	if p, ok := s.gitlab.projectsByPath[name]; ok {
+		for _, mr := range s.gitlab.mergeRequests {
+			if mr.ProjectID == p.ID {
+				result = append(result, mr)
+			}
+		}
+	}
+	return result
+}
+
+// MergeRequestNotes returns the comments of the merge request
+func (s *Server) MergeRequestNotes(name string, iid int) []*gitlab.Note {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mr := s.mergeRequest(name, iid); mr != nil {
+		return append([]*gitlab.Note{}, s.gitlab.notes[mr.ID]...)
+	}
+	return nil
+}
+
+// SetMergeRequestState changes the state of the merge request (ex "merged", "closed")
+func (s *Server) SetMergeRequestState(name string, iid int, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mr := s.mergeRequest(name, iid)
+	if mr == nil {
+		return fmt.Errorf("merge request %s!%d not found", name, iid)
+	}
+	mr.State = state
+	return nil
+}
+
+// AddMergeRequestPipeline adds a pipeline with the given status to the merge request. The last
+// added pipeline is the latest one
+func (s *Server) AddMergeRequestPipeline(name string, iid int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mr := s.mergeRequest(name, iid)
+	if mr == nil {
+		return fmt.Errorf("merge request %s!%d not found", name, iid)
+	}
+	id := len(s.gitlab.pipelines[mr.ID]) + 1
+	p := &gitlab.PipelineInfo{
+		ID:     id,
+		Status: status,
+		Ref:    mr.SourceBranch,
+		WebURL: fmt.Sprintf("%s/%s/-/pipelines/%d", s.URL, name, id),
+	}
+	s.gitlab.pipelines[mr.ID] = append([]*gitlab.PipelineInfo{p}, s.gitlab.pipelines[mr.ID]...)
+	return nil
+}
+
+func (s *Server) mergeRequest(name string, iid int) *gitlab.MergeRequest {
+	p, ok := s.gitlab.projectsByPath[name]
+	if !ok {
+		return nil
+	}
+	return s.gitlab.findMergeRequest(p.ID, strconv.Itoa(iid))
+}
+
+// serveGitLab implements the subset of the GitLab API used by delorean
+// See https://docs.gitlab.com/ee/api/
+func (s *Server) serveGitLab(w http.ResponseWriter, r *http.Request) {
+	// /projects/{id}/merge_requests/{iid}/... with the id url encoded
+	var parts []string
+	for _, p := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		u, err := url.PathUnescape(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts = append(parts, u)
+	}
+	if len(parts) < 2 || parts[0] != "projects" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlab.findProject(parts[1])
+	if project == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Project Not Found"})
+		return
+	}
+	resource := parts[2:]
+
+	if len(resource) == 0 && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, project)
+		return
+	}
+	if len(resource) == 0 || resource[0] != "merge_requests" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(resource) == 1 && r.Method == http.MethodGet:
+		s.listMergeRequests(w, r, project)
+		return
+	case len(resource) == 1 && r.Method == http.MethodPost:
+		s.createMergeRequest(w, r, project)
+		return
+	}
+
+	mr := s.gitlab.findMergeRequest(project.ID, resource[1])
+	if mr == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Not found"})
+		return
+	}
+
+	switch {
+	case len(resource) == 2 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, toMergeRequestResponse(mr))
+	case len(resource) == 2 && r.Method == http.MethodPut:
+		opt := &gitlab.UpdateMergeRequestOptions{}
+		if err := json.NewDecoder(r.Body).Decode(opt); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if opt.Title != nil {
+			mr.Title = *opt.Title
+		}
+		if opt.Description != nil {
+			mr.Description = *opt.Description
+		}
+		writeJSON(w, http.StatusOK, toMergeRequestResponse(mr))
+	case len(resource) == 3 && resource[2] == "pipelines" && r.Method == http.MethodGet:
+		pipelines := s.gitlab.pipelines[mr.ID]
+		if pipelines == nil {
+			pipelines = []*gitlab.PipelineInfo{}
+		}
+		writeJSON(w, http.StatusOK, pipelines)
+	case len(resource) == 3 && resource[2] == "approvals" && r.Method == http.MethodGet:
+		approvals, ok := s.gitlab.approvals[mr.ID]
+		if !ok {
+			approvals = &gitlab.MergeRequestApprovals{ID: mr.ID, ProjectID: mr.ProjectID, State: mr.State, ApprovalsRequired: 1, ApprovalsLeft: 1}
+		}
+		writeJSON(w, http.StatusOK, approvals)
+	case len(resource) == 3 && resource[2] == "notes" && r.Method == http.MethodPost:
+		opt := &gitlab.CreateMergeRequestNoteOptions{}
+		if err := json.NewDecoder(r.Body).Decode(opt); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		note := &gitlab.Note{ID: len(s.gitlab.notes[mr.ID]) + 1, Body: *opt.Body}
+		s.gitlab.notes[mr.ID] = append(s.gitlab.notes[mr.ID], note)
+		writeJSON(w, http.StatusCreated, note)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) listMergeRequests(w http.ResponseWriter, r *http.Request, project *gitlab.Project) {
+	q := r.URL.Query()
+	result := []*mergeRequestResponse{}
+	for _, mr := range s.gitlab.mergeRequests {
+		if mr.ProjectID != project.ID {
+			continue
+		}
+		if state := q.Get("state"); state != "" && state != "all" && state != mr.State {
+			continue
+		}
+		if b := q.Get("source_branch"); b != "" && b != mr.SourceBranch {
+			continue
+		}
+		if b := q.Get("target_branch"); b != "" && b != mr.TargetBranch {
+			continue
+		}
+		result = append(result, toMergeRequestResponse(mr))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createMergeRequest(w http.ResponseWriter, r *http.Request, source *gitlab.Project) {
+	opt := &gitlab.CreateMergeRequestOptions{}
+	if err := json.NewDecoder(r.Body).Decode(opt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opt.SourceBranch == nil || opt.TargetBranch == nil || opt.Title == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "title, source_branch and target_branch are required"})
+		return
+	}
+
+	target := source
+	if opt.TargetProjectID != nil {
+		target = s.gitlab.findProject(strconv.Itoa(*opt.TargetProjectID))
+		if target == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Target Project Not Found"})
+			return
+		}
+	}
+
+	iid := 1
+	for _, mr := range s.gitlab.mergeRequests {
+		if mr.ProjectID == target.ID {
+			if mr.SourceBranch == *opt.SourceBranch && mr.SourceProjectID == source.ID && mr.State == "opened" {
+				writeJSON(w, http.StatusConflict, map[string][]string{"message": {"Another open merge request already exists for this source branch"}})
+				return
+			}
+			iid++
+		}
+	}
+
+	mr := &gitlab.MergeRequest{
+		ID:              len(s.gitlab.mergeRequests) + 1,
+		IID:             iid,
+		ProjectID:       target.ID,
+		SourceProjectID: source.ID,
+		TargetProjectID: target.ID,
+		SourceBranch:    *opt.SourceBranch,
+		TargetBranch:    *opt.TargetBranch,
+		Title:           *opt.Title,
+		State:           "opened",
+		WebURL:          fmt.Sprintf("%s/%s/-/merge_requests/%d", s.URL, target.PathWithNamespace, iid),
+	}
+	if opt.Description != nil {
+		mr.Description = *opt.Description
+	}
+	s.gitlab.mergeRequests = append(s.gitlab.mergeRequests, mr)
+	writeJSON(w, http.StatusCreated, toMergeRequestResponse(mr))
+}
@@ -0,0 +1,171 @@
+// Package fake provides an in-process stand-in for GitHub and GitLab that can be used
+// to test the delorean commands end-to-end without network access.
+//
+// The Server serves:
+//   - git smart-HTTP for bare repositories stored in a temporary directory
+//     (ex. <URL>/integr8ly/integreatly-operator.git)
+//   - the subset of the GitHub REST API used by delorean under <URL>/api/v3/
+//   - the subset of the GitLab REST API used by delorean under <URL>/api/v4/
+package fake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/integr8ly/delorean/pkg/utils"
+)
+
+const (
+	gitHubAPIPath = "/api/v3/"
+	gitLabAPIPath = "/api/v4/"
+
+	commitAuthorName  = "Fake"
+	commitAuthorEmail = "fake@example.com"
+)
+
+// Server is a fake GitHub and GitLab server backed by bare git repositories on disk
+type Server struct {
+	*httptest.Server
+
+	// Dir is the directory containing the bare repositories
+	Dir string
+
+	mu     sync.Mutex
+	github *gitHubState
+	gitlab *gitLabState
+}
+
+// NewServer starts a new fake server. The server must be closed with Close
+func NewServer() (*Server, error) {
+	dir, err := ioutil.TempDir(os.TempDir(), "fake-scm-")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Dir:    dir,
+		github: newGitHubState(),
+		gitlab: newGitLabState(),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(gitHubAPIPath, http.StripPrefix(strings.TrimSuffix(gitHubAPIPath, "/"), http.HandlerFunc(s.serveGitHub)))
+	mux.Handle(gitLabAPIPath, http.StripPrefix(strings.TrimSuffix(gitLabAPIPath, "/"), http.HandlerFunc(s.serveGitLab)))
+	mux.HandleFunc("/", s.serveGit)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Close shuts down the server and removes all repositories
+func (s *Server) Close() {
+	s.Server.Close()
+	os.RemoveAll(s.Dir)
+}
+
+// GitHubAPIURL returns the base URL for the GitHub API, to be used with github.NewEnterpriseClient
+func (s *Server) GitHubAPIURL() string {
+	return s.URL + gitHubAPIPath
+}
+
+// GitLabAPIURL returns the base URL for the GitLab API, to be used with gitlab.WithBaseURL
+func (s *Server) GitLabAPIURL() string {
+	return s.URL + gitLabAPIPath
+}
+
+// RepoURL returns the git URL of the repository with the given name (ex "integr8ly/integreatly-operator")
+func (s *Server) RepoURL(name string) string {
+	return fmt.Sprintf("%s/%s.git", s.URL, name)
+}
+
+func (s *Server) repoDir(name string) string {
+	return path.Join(s.Dir, strings.TrimSuffix(name, ".git")+".git")
+}
+
+// CreateRepo creates a bare repository with the given name (ex "integr8ly/integreatly-operator") and
+// commits the content of the source directory to the given branch
+func (s *Server) CreateRepo(name string, branch string, sourceDir string) error {
+	bare, err := git.PlainInit(s.repoDir(name), true)
+	if err != nil {
+		return err
+	}
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if err := bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef)); err != nil {
+		return err
+	}
+
+	workDir, err := ioutil.TempDir(os.TempDir(), "fake-scm-work-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	if err := utils.CopyDirectory(sourceDir, workDir); err != nil {
+		return err
+	}
+
+	// Use the bare repository storage with a temporary worktree so that the
+	// initial commit is written directly to the bare repository
+	repo, err := git.Open(bare.Storer, osfs.New(workDir))
+	if err != nil {
+		return err
+	}
+	tree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := tree.AddGlob("."); err != nil {
+		return err
+	}
+	if _, err := tree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: commitAuthorName, Email: commitAuthorEmail, When: time.Now()},
+	}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gitlab.addProject(name)
+	return nil
+}
+
+// CreateTag creates a lightweight tag pointing to the head of the given branch
+func (s *Server) CreateTag(name string, branch string, tag string) error {
+	repo, err := git.PlainOpen(s.repoDir(name))
+	if err != nil {
+		return err
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return err
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName(tag), ref.Hash()))
+}
+
+// Repo opens the bare repository with the given name, to be used to verify the pushed changes
+func (s *Server) Repo(name string) (*git.Repository, error) {
+	return git.PlainOpen(s.repoDir(name))
+}
+
+// ForkRepo creates the repository forkName as a copy of the repository name
+func (s *Server) ForkRepo(name string, forkName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(path.Dir(s.repoDir(forkName)), 0755); err != nil {
+		return err
+	}
+	if err := utils.CopyDirectory(s.repoDir(name), s.repoDir(forkName)); err != nil {
+		return err
+	}
+	s.gitlab.addProject(forkName)
+	return nil
+}
@@ -0,0 +1,199 @@
+package fake
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v30/github"
+	"github.com/xanzy/go-gitlab"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	if err := s.CreateRepo("integr8ly/test", "main", "testdata/repo"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestServer_CloneAndPush(t *testing.T) {
+	s := newTestServer(t)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "fake-scm-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainClone(dir, false, &git.CloneOptions{
+		URL:           s.RepoURL("integr8ly/test"),
+		ReferenceName: plumbing.NewBranchReferenceName("main"),
+	})
+	if err != nil {
+		t.Fatalf("failed to clone the repo: %v", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "README.md")); err != nil {
+		t.Fatalf("README.md not found in the cloned repo: %v", err)
+	}
+
+	tree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch := plumbing.NewBranchReferenceName("feature")
+	if err := tree.Checkout(&git.CheckoutOptions{Branch: branch, Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Add("new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := tree.Commit("add new.txt", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&git.PushOptions{RefSpecs: []config.RefSpec{config.RefSpec(branch + ":" + branch)}}); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+
+	bare, err := s.Repo("integr8ly/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := bare.Reference(branch, true)
+	if err != nil {
+		t.Fatalf("pushed branch not found: %v", err)
+	}
+	if ref.Hash() != commit {
+		t.Fatalf("expected %s to point to %s but found %s", branch, commit, ref.Hash())
+	}
+}
+
+func TestServer_GitHub(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.TODO()
+
+	client, err := github.NewEnterpriseClient(s.GitHubAPIURL(), s.GitHubAPIURL(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, _, err := client.PullRequests.Create(ctx, "integr8ly", "test", &github.NewPullRequest{
+		Title: github.String("test"),
+		Head:  github.String("integr8ly:feature"),
+		Base:  github.String("main"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create the PR: %v", err)
+	}
+
+	prs, _, err := client.PullRequests.List(ctx, "integr8ly", "test", &github.PullRequestListOptions{Head: "integr8ly:feature", Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 || prs[0].GetNumber() != pr.GetNumber() {
+		t.Fatalf("expected to find the PR %d but found %v", pr.GetNumber(), prs)
+	}
+
+	if _, _, err := client.PullRequests.Merge(ctx, "integr8ly", "test", pr.GetNumber(), "", nil); err != nil {
+		t.Fatalf("failed to merge the PR: %v", err)
+	}
+	if got := s.PullRequests("integr8ly/test")[0]; !got.GetMerged() {
+		t.Fatal("expected the PR to be merged")
+	}
+
+	refs, _, err := client.Git.GetRefs(ctx, "integr8ly", "test", "heads/main")
+	if err != nil {
+		t.Fatalf("failed to get the refs: %v", err)
+	}
+	if _, _, err := client.Git.CreateRef(ctx, "integr8ly", "test", &github.Reference{
+		Ref:    github.String("refs/tags/v1.0.0"),
+		Object: refs[0].Object,
+	}); err != nil {
+		t.Fatalf("failed to create the tag: %v", err)
+	}
+	tags, _, err := client.Git.GetRefs(ctx, "integr8ly", "test", "tags/v1.0.0")
+	if err != nil || len(tags) != 1 || tags[0].GetObject().GetSHA() != refs[0].GetObject().GetSHA() {
+		t.Fatalf("expected tag v1.0.0 to point to %s but found %v (%v)", refs[0].GetObject().GetSHA(), tags, err)
+	}
+}
+
+func TestServer_GitLab(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.ForkRepo("integr8ly/test", "fork/test"); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(s.GitLabAPIURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, _, err := client.Projects.GetProject("integr8ly/test", &gitlab.GetProjectOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the project: %v", err)
+	}
+
+	mr, _, err := client.MergeRequests.CreateMergeRequest("fork/test", &gitlab.CreateMergeRequestOptions{
+		Title:           gitlab.String("test"),
+		SourceBranch:    gitlab.String("feature"),
+		TargetBranch:    gitlab.String("main"),
+		TargetProjectID: gitlab.Int(target.ID),
+	})
+	if err != nil {
+		t.Fatalf("failed to create the MR: %v", err)
+	}
+	if mr.ProjectID != target.ID {
+		t.Fatalf("expected the MR to target the project %d but found %d", target.ID, mr.ProjectID)
+	}
+
+	mrs, _, err := client.MergeRequests.ListProjectMergeRequests(target.ID, &gitlab.ListProjectMergeRequestsOptions{
+		State:        gitlab.String("opened"),
+		SourceBranch: gitlab.String("feature"),
+	})
+	if err != nil || len(mrs) != 1 {
+		t.Fatalf("expected 1 open MR but found %v (%v)", mrs, err)
+	}
+
+	if _, _, err := client.MergeRequests.UpdateMergeRequest(target.ID, mr.IID, &gitlab.UpdateMergeRequestOptions{Description: gitlab.String("updated")}); err != nil {
+		t.Fatalf("failed to update the MR: %v", err)
+	}
+	if _, _, err := client.Notes.CreateMergeRequestNote(target.ID, mr.IID, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String("comment")}); err != nil {
+		t.Fatalf("failed to comment the MR: %v", err)
+	}
+	if notes := s.MergeRequestNotes("integr8ly/test", mr.IID); len(notes) != 1 || notes[0].Body != "comment" {
+		t.Fatalf("expected the comment on the MR but found %v", notes)
+	}
+
+	if err := s.AddMergeRequestPipeline("integr8ly/test", mr.IID, "success"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMergeRequestState("integr8ly/test", mr.IID, "merged"); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := client.MergeRequests.GetMergeRequest(target.ID, mr.IID, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != "merged" || got.Description != "updated" {
+		t.Fatalf("unexpected MR %+v", got)
+	}
+	pipelines, _, err := client.MergeRequests.ListMergeRequestPipelines(target.ID, mr.IID)
+	if err != nil || len(pipelines) != 1 || pipelines[0].Status != "success" {
+		t.Fatalf("expected 1 successful pipeline but found %v (%v)", pipelines, err)
+	}
+}
@@ -61,6 +61,22 @@ func (m *MockS3API) ListObjectsV2WithContext(_ aws.Context, input *s3.ListObject
 	return m.ListObjsFunc(input)
 }
 
+// ListObjectsV2PagesWithContext calls ListObjsFunc for each page, until the output is not truncated
+func (m *MockS3API) ListObjectsV2PagesWithContext(_ aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	i := *input
+	for {
+		o, err := m.ListObjsFunc(&i)
+		if err != nil {
+			return err
+		}
+		last := o.IsTruncated == nil || !*o.IsTruncated
+		if !fn(o, last) || last {
+			return nil
+		}
+		i.ContinuationToken = o.NextContinuationToken
+	}
+}
+
 func (m *MockS3API) GetObjectTaggingWithContext(_ aws.Context, input *s3.GetObjectTaggingInput, _ ...request.Option) (*s3.GetObjectTaggingOutput, error) {
 	return m.GetObjTaggingFunc(input)
 }
@@ -2,6 +2,10 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+
 	"golang.org/x/sync/errgroup"
 )
 
@@ -78,3 +82,139 @@ func Min(a, b int) int {
 	}
 	return b
 }
+
+type DAGTaskStatus string
+
+const (
+	DAGTaskSucceeded DAGTaskStatus = "succeeded"
+	DAGTaskFailed    DAGTaskStatus = "failed"
+	DAGTaskSkipped   DAGTaskStatus = "skipped"
+)
+
+// DAGTask is a named task that only starts once all the tasks it depends on have succeeded
+type DAGTask struct {
+	Name      string
+	DependsOn []string
+	Run       func(ctx context.Context) error
+}
+
+type DAGTaskResult struct {
+	Status DAGTaskStatus
+	Err    error
+}
+
+// Validate that the task names are unique, that all dependencies exist and that there are no cycles
+func ValidateDAG(tasks []DAGTask) error {
+	deps := map[string][]string{}
+	for _, t := range tasks {
+		if _, ok := deps[t.Name]; ok {
+			return fmt.Errorf("duplicate task %s", t.Name)
+		}
+		deps[t.Name] = t.DependsOn
+	}
+	for _, t := range tasks {
+		for _, d := range t.DependsOn {
+			if _, ok := deps[d]; !ok {
+				return fmt.Errorf("task %s depends on unknown task %s", t.Name, d)
+			}
+		}
+	}
+
+	// depth first search, a task found again while it's being visited means there's a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, d := range deps[name] {
+			if err := visit(d, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, t := range tasks {
+		if err := visit(t.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run the given tasks in the order of their dependencies with at most limit tasks running at the same time
+// (no limit if limit <= 0). Unlike ParallelLimit, a failing task doesn't stop the other tasks: only the tasks
+// depending on it are skipped, with an error wrapping the error of the dependency. It will wait for all tasks to be completed and return the result of each task by name,
+// or an error if the tasks are not a valid DAG
+func RunDAG(ctx context.Context, tasks []DAGTask, limit int) (map[string]*DAGTaskResult, error) {
+	if err := ValidateDAG(tasks); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = len(tasks)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]*DAGTaskResult, len(tasks))
+	done := make(map[string]chan struct{}, len(tasks))
+	for _, t := range tasks {
+		done[t.Name] = make(chan struct{})
+	}
+	// slots limits the number of tasks running at the same time
+	slots := make(chan struct{}, limit)
+
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t DAGTask) {
+			defer wg.Done()
+			defer close(done[t.Name])
+			result := &DAGTaskResult{}
+			defer func() {
+				mu.Lock()
+				results[t.Name] = result
+				mu.Unlock()
+			}()
+
+			for _, d := range t.DependsOn {
+				<-done[d]
+				mu.Lock()
+				r := results[d]
+				mu.Unlock()
+				if r.Status != DAGTaskSucceeded {
+					result.Status = DAGTaskSkipped
+					// wrap the error of the dependency so that the root cause can be found with errors.Is
+					result.Err = fmt.Errorf("dependency %s %s: %w", d, r.Status, r.Err)
+					return
+				}
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				result.Status = DAGTaskSkipped
+				result.Err = ctx.Err()
+				return
+			}
+			defer func() { <-slots }()
+
+			if err := t.Run(ctx); err != nil {
+				result.Status = DAGTaskFailed
+				result.Err = err
+				return
+			}
+			result.Status = DAGTaskSucceeded
+		}(t)
+	}
+	wg.Wait()
+	return results, nil
+}
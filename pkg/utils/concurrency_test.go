@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestParallelLimit(t *testing.T) {
@@ -77,3 +79,131 @@ func TestParallelLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestRunDAG(t *testing.T) {
+	succeed := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("test error") }
+
+	cases := []struct {
+		description string
+		limit       int
+		tasks       []DAGTask
+		expectError bool
+		expected    map[string]DAGTaskStatus
+	}{
+		{
+			description: "should skip the tasks depending on a failed task",
+			limit:       2,
+			tasks: []DAGTask{
+				{Name: "a", Run: succeed},
+				{Name: "b", DependsOn: []string{"a"}, Run: fail},
+				{Name: "c", DependsOn: []string{"b"}, Run: succeed},
+				{Name: "d", DependsOn: []string{"a"}, Run: succeed},
+				{Name: "e", Run: fail},
+			},
+			expected: map[string]DAGTaskStatus{
+				"a": DAGTaskSucceeded,
+				"b": DAGTaskFailed,
+				"c": DAGTaskSkipped,
+				"d": DAGTaskSucceeded,
+				"e": DAGTaskFailed,
+			},
+		},
+		{
+			description: "should fail for unknown dependencies",
+			tasks:       []DAGTask{{Name: "a", DependsOn: []string{"b"}, Run: succeed}},
+			expectError: true,
+		},
+		{
+			description: "should fail for duplicate tasks",
+			tasks:       []DAGTask{{Name: "a", Run: succeed}, {Name: "a", Run: succeed}},
+			expectError: true,
+		},
+		{
+			description: "should fail for dependency cycles",
+			tasks: []DAGTask{
+				{Name: "a", DependsOn: []string{"c"}, Run: succeed},
+				{Name: "b", DependsOn: []string{"a"}, Run: succeed},
+				{Name: "c", DependsOn: []string{"b"}, Run: succeed},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			results, err := RunDAG(context.Background(), c.tasks, c.limit)
+			if err != nil && !c.expectError {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c.expectError && err == nil {
+				t.Fatal("expect error but got nil")
+			}
+			for name, status := range c.expected {
+				if results[name].Status != status {
+					t.Errorf("expected task %s to be %s but it is %s", name, status, results[name].Status)
+				}
+			}
+		})
+	}
+}
+
+func TestRunDAG_order(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	running, maxRunning := 0, 0
+	task := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}
+	}
+	tasks := []DAGTask{
+		{Name: "c", DependsOn: []string{"a", "b"}, Run: task("c")},
+		{Name: "a", Run: task("a")},
+		{Name: "b", Run: task("b")},
+		{Name: "d", Run: task("d")},
+	}
+	if _, err := RunDAG(context.Background(), tasks, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 tasks running at the same time but got %d", maxRunning)
+	}
+	for i, name := range order {
+		if name == "c" {
+			for _, dep := range order[i:] {
+				if dep == "a" || dep == "b" {
+					t.Errorf("expected c to run after a and b but got %v", order)
+				}
+			}
+		}
+	}
+}
+
+func TestRunDAG_skippedCause(t *testing.T) {
+	cause := errors.New("root cause")
+	tasks := []DAGTask{
+		{Name: "a", Run: func(ctx context.Context) error { return cause }},
+		{Name: "b", DependsOn: []string{"a"}, Run: func(ctx context.Context) error { return nil }},
+		{Name: "c", DependsOn: []string{"b"}, Run: func(ctx context.Context) error { return nil }},
+	}
+	results, err := RunDAG(context.Background(), tasks, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"b", "c"} {
+		if results[name].Status != DAGTaskSkipped || !errors.Is(results[name].Err, cause) {
+			t.Errorf("expected task %s to be skipped because of the root cause but got %+v", name, results[name])
+		}
+	}
+}
@@ -36,3 +36,12 @@ func AddOrUpdateEnvVarWithSource(envVars []corev1.EnvVar, envName string, envVal
 	}
 	return append(envVars, v)
 }
+
+func RemoveEnvVar(envVars []corev1.EnvVar, envName string) []corev1.EnvVar {
+	for i, env := range envVars {
+		if env.Name == envName {
+			return append(envVars[:i], envVars[i+1:]...)
+		}
+	}
+	return envVars
+}
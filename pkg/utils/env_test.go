@@ -52,3 +52,32 @@ func TestAddOrUpdateEnvVar(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveEnvVar(t *testing.T) {
+	tests := []struct {
+		name    string
+		envVars []corev1.EnvVar
+		envName string
+		want    []corev1.EnvVar
+	}{
+		{
+			name:    "test remove env",
+			envVars: []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "TestName", Value: "TestVal"}, {Name: "B", Value: "2"}},
+			envName: "TestName",
+			want:    []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+		},
+		{
+			name:    "test remove missing env",
+			envVars: []corev1.EnvVar{{Name: "A", Value: "1"}},
+			envName: "TestName",
+			want:    []corev1.EnvVar{{Name: "A", Value: "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemoveEnvVar(tt.envVars, tt.envName); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RemoveEnvVar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
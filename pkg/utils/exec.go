@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// PodExecutor runs commands in the containers of the pods
+type PodExecutor interface {
+	// Exec runs the command in the container and writes its standard output to stdout
+	Exec(ctx context.Context, namespace string, podName string, containerName string, command []string, stdout io.Writer) error
+}
+
+// SPDYPodExecutor runs the commands through the exec API of the pods, the same way as `oc exec`
+type SPDYPodExecutor struct {
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+func NewSPDYPodExecutor(config *rest.Config, client kubernetes.Interface) *SPDYPodExecutor {
+	return &SPDYPodExecutor{config: config, client: client}
+}
+
+func (e *SPDYPodExecutor) Exec(ctx context.Context, namespace string, podName string, containerName string, command []string, stdout io.Writer) error {
+	req := e.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	stderr := &bytes.Buffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr})
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run %v in the container %s of the pod %s: %w: %s", command, containerName, podName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// CopyFromPod copies the content of the srcDir folder of the container into the destDir folder.
+// Like `oc cp`, the files are archived with tar in the container so tar must be available in the image
+func CopyFromPod(ctx context.Context, executor PodExecutor, namespace string, podName string, containerName string, srcDir string, destDir string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(executor.Exec(ctx, namespace, podName, containerName, []string{"tar", "cf", "-", "-C", srcDir, "."}, writer))
+	}()
+	err := Untar(reader, destDir)
+	// drain the stream so that the executor isn't blocked if the extraction failed
+	io.Copy(ioutil.Discard, reader)
+	return err
+}
+
+// Untar extracts the tar stream into the destDir folder. Entries outside of destDir are rejected
+func Untar(r io.Reader, destDir string) error {
+	destDir = filepath.Clean(destDir)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, header.Name)
+		if target != destDir && !strings.HasPrefix(target, destDir+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in the archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			// links and special files are ignored
+			continue
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+)
+
+type mockPodExecutor struct {
+	execFunc func(command []string, stdout io.Writer) error
+}
+
+func (e *mockPodExecutor) Exec(ctx context.Context, namespace string, podName string, containerName string, command []string, stdout io.Writer) error {
+	return e.execFunc(command, stdout)
+}
+
+func writeTestTar(w io.Writer, entries []*tar.Header, content string) error {
+	tw := tar.NewWriter(w)
+	for _, h := range entries {
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(content))
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if h.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(content)); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func TestCopyFromPod(t *testing.T) {
+	cases := []struct {
+		description string
+		entries     []*tar.Header
+		execErr     error
+		expectErr   bool
+		expectFiles []string
+	}{
+		{
+			description: "copy files and folders",
+			entries: []*tar.Header{
+				{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "./junit.xml", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "./reports/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "./reports/report.html", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "./link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+			},
+			expectFiles: []string{"junit.xml", "reports/report.html"},
+		},
+		{
+			description: "reject files outside of the destination",
+			entries:     []*tar.Header{{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644}},
+			expectErr:   true,
+		},
+		{
+			description: "fail if the command fails",
+			execErr:     errors.New("tar: not found"),
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			dest := path.Join(t.TempDir(), "results")
+			executor := &mockPodExecutor{execFunc: func(command []string, stdout io.Writer) error {
+				expected := []string{"tar", "cf", "-", "-C", "/test-run-results", "."}
+				if !reflect.DeepEqual(command, expected) {
+					t.Errorf("expected command %v but got %v", expected, command)
+				}
+				if c.execErr != nil {
+					return c.execErr
+				}
+				return writeTestTar(stdout, c.entries, "content")
+			}}
+
+			err := CopyFromPod(context.TODO(), executor, "test", "test-pod", "sidecar", "/test-run-results", dest)
+			if c.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, f := range c.expectFiles {
+				b, err := ioutil.ReadFile(path.Join(dest, f))
+				if err != nil {
+					t.Fatalf("expected file %s: %v", f, err)
+				}
+				if string(b) != "content" {
+					t.Errorf("unexpected content of %s: %s", f, b)
+				}
+			}
+			if FileExists(path.Join(dest, "link")) {
+				t.Errorf("expected the links to be ignored")
+			}
+		})
+	}
+}
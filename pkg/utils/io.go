@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/yaml"
@@ -152,3 +155,44 @@ func FileAsBytes(path string) ([]byte, error) {
 	}
 	return bytes, nil
 }
+
+// PrefixWriter writes each line to the underlying writer with the given prefix
+type PrefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+	mu     sync.Mutex
+}
+
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: prefix}
+}
+
+// Write buffers the given bytes and writes all the complete lines
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if _, err := io.WriteString(p.w, p.prefix+string(p.buf[:i+1])); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes the last line if it doesn't end with a new line
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(p.w, p.prefix+string(p.buf)+"\n")
+	p.buf = nil
+	return err
+}
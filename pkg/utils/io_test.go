@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -135,3 +136,20 @@ func TestWriteK8sObjectToYAML(t *testing.T) {
 		t.Errorf("expected output is not valid. Expected:\n%s\n Actual:\n%s\n", expected, content)
 	}
 }
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewPrefixWriter(out, "[test] ")
+	for _, chunk := range []string{"first line\nsec", "ond line\n", "\nlast"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "[test] first line\n[test] second line\n[test] \n[test] last\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+}
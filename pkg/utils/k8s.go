@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -12,6 +13,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 )
@@ -141,11 +143,11 @@ func CreateDockerSecret(client kubernetes.Interface, secretName string, namespac
 	return nil
 }
 
-func WaitForContainerToComplete(client kubernetes.Interface, namespace string, podSelector string, containerName string, timeout time.Duration, logPrefix string) (*v1.ContainerStateTerminated, error) {
+func WaitForContainerToComplete(ctx context.Context, client kubernetes.Interface, namespace string, podSelector string, containerName string, timeout time.Duration, logPrefix string) (*v1.ContainerStateTerminated, error) {
 	var err error
 	var watcher watch.Interface
 	api := client.CoreV1().Pods(namespace)
-	if watcher, err = api.Watch(context.TODO(), metav1.ListOptions{LabelSelector: podSelector}); err != nil {
+	if watcher, err = api.Watch(ctx, metav1.ListOptions{LabelSelector: podSelector}); err != nil {
 		return nil, err
 	}
 	for {
@@ -184,6 +186,10 @@ func WaitForContainerToComplete(client kubernetes.Interface, namespace string, p
 			fmt.Println(fmt.Sprintf("[%s] Timed out when running pod with selector: %s", logPrefix, podSelector))
 			watcher.Stop()
 			return nil, errors.New("timeout")
+		case <-ctx.Done():
+			fmt.Println(fmt.Sprintf("[%s] Cancelled when running pod with selector: %s", logPrefix, podSelector))
+			watcher.Stop()
+			return nil, ctx.Err()
 		}
 	}
 }
@@ -205,3 +211,23 @@ func GetPods(client kubernetes.Interface, namespace string, podSelector string)
 func GetPod(client kubernetes.Interface, namespace string, name string) (*v1.Pod, error) {
 	return client.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 }
+
+// StreamContainerLogs follows the logs of the given container and writes them to out until the container terminates.
+// The logs can't be streamed before the container is started so opening the stream is retried every interval
+func StreamContainerLogs(ctx context.Context, client kubernetes.Interface, namespace string, podName string, containerName string, interval time.Duration, out io.Writer) error {
+	var stream io.ReadCloser
+	req := client.CoreV1().Pods(namespace).GetLogs(podName, &v1.PodLogOptions{Container: containerName, Follow: true})
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		var err error
+		if stream, err = req.Stream(ctx); err != nil {
+			return false, nil
+		}
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("failed to stream the logs of the container %s in the pod %s: %w", containerName, podName, err)
+	}
+	defer stream.Close()
+	_, err = io.Copy(out, stream)
+	return err
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"context"
 	"reflect"
 	"testing"
@@ -244,7 +245,7 @@ func TestWaitForContainerToComplete(t *testing.T) {
 		t.Run(c.description, func(t *testing.T) {
 			results := make(chan *v1.ContainerStateTerminated, 1)
 			go func() {
-				r, err := WaitForContainerToComplete(client, namespace, selector, "test", timeout, "test")
+				r, err := WaitForContainerToComplete(context.TODO(), client, namespace, selector, "test", timeout, "test")
 				if err != nil {
 					t.Logf("unexpected error: %v", err)
 					return
@@ -270,3 +271,15 @@ func TestWaitForContainerToComplete(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamContainerLogs(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	out := &bytes.Buffer{}
+	if err := StreamContainerLogs(context.TODO(), client, "test", "test-pod", "test", time.Millisecond, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the fake clientset always returns "fake logs" as logs
+	if out.String() != "fake logs" {
+		t.Errorf("unexpected logs: %s", out.String())
+	}
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// ParsePortForwardTarget parses a target in the format <pod|svc>/<name>:<port>, like `oc port-forward`
+func ParsePortForwardTarget(target string) (string, string, int, error) {
+	parts := strings.SplitN(target, "/", 2)
+	if len(parts) != 2 {
+		return "", "", 0, fmt.Errorf("invalid target %s, expected the format <pod|svc>/<name>:<port>", target)
+	}
+	kind := parts[0]
+	switch kind {
+	case "pod", "pods":
+		kind = "pod"
+	case "svc", "service", "services":
+		kind = "svc"
+	default:
+		return "", "", 0, fmt.Errorf("invalid target %s, only pods and services are supported", target)
+	}
+	nameAndPort := strings.SplitN(parts[1], ":", 2)
+	if len(nameAndPort) != 2 || nameAndPort[0] == "" {
+		return "", "", 0, fmt.Errorf("invalid target %s, expected the format <pod|svc>/<name>:<port>", target)
+	}
+	port, err := strconv.Atoi(nameAndPort[1])
+	if err != nil || port <= 0 {
+		return "", "", 0, fmt.Errorf("invalid port of the target %s", target)
+	}
+	return kind, nameAndPort[0], port, nil
+}
+
+// ResolvePortForwardTarget returns the pod and the port of the pod to forward to. For a service, it's
+// a running pod selected by the service and the target port of the service port
+func ResolvePortForwardTarget(ctx context.Context, client kubernetes.Interface, namespace string, target string) (string, int, error) {
+	kind, name, port, err := ParsePortForwardTarget(target)
+	if err != nil {
+		return "", 0, err
+	}
+	if kind == "pod" {
+		return name, port, nil
+	}
+
+	svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	var targetPort *intstr.IntOrString
+	for _, p := range svc.Spec.Ports {
+		if int(p.Port) == port {
+			targetPort = &p.TargetPort
+			break
+		}
+	}
+	if targetPort == nil {
+		return "", 0, fmt.Errorf("service %s has no port %d", name, port)
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String()})
+	if err != nil {
+		return "", 0, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		if targetPort.Type == intstr.Int {
+			if targetPort.IntVal == 0 {
+				// the target port defaults to the port of the service
+				return pod.GetName(), port, nil
+			}
+			return pod.GetName(), int(targetPort.IntVal), nil
+		}
+		for _, c := range pod.Spec.Containers {
+			for _, p := range c.Ports {
+				if p.Name == targetPort.StrVal {
+					return pod.GetName(), int(p.ContainerPort), nil
+				}
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("no running pod found for the port %d of the service %s", port, name)
+}
+
+// PortForward forwards a random local port to the port of the pod, like `oc port-forward`. It returns the
+// local port and a function to stop the forwarding
+func PortForward(config *rest.Config, client kubernetes.Interface, namespace string, podName string, port int) (int, func(), error) {
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return 0, nil, err
+	}
+	url := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	fw, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", port)}, stopChan, readyChan, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return 0, nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fw.ForwardPorts()
+	}()
+	select {
+	case <-readyChan:
+	case err = <-done:
+		return 0, nil, fmt.Errorf("failed to forward the port %d of the pod %s: %w", port, podName, err)
+	}
+	ports, err := fw.GetPorts()
+	if err != nil {
+		close(stopChan)
+		return 0, nil, err
+	}
+	return int(ports[0].Local), func() { close(stopChan) }, nil
+}
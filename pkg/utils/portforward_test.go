@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParsePortForwardTarget(t *testing.T) {
+	kind, name, port, err := ParsePortForwardTarget("service/prometheus:9090")
+	if err != nil || kind != "svc" || name != "prometheus" || port != 9090 {
+		t.Errorf("unexpected target %s/%s:%d: %v", kind, name, port, err)
+	}
+	for _, target := range []string{"prometheus:9090", "deployment/prometheus:9090", "pod/prometheus", "pod/:9090", "pod/prometheus:http"} {
+		if _, _, _, err := ParsePortForwardTarget(target); err == nil {
+			t.Errorf("expected an error for the target %s", target)
+		}
+	}
+}
+
+func TestResolvePortForwardTarget(t *testing.T) {
+	newPod := func(name string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "monitoring", Labels: map[string]string{"app": "prometheus"}},
+			Spec: v1.PodSpec{Containers: []v1.Container{{
+				Name:  "prometheus",
+				Ports: []v1.ContainerPort{{Name: "web", ContainerPort: 9090}},
+			}}},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "prometheus", Namespace: "monitoring"},
+			Spec: v1.ServiceSpec{
+				Selector: map[string]string{"app": "prometheus"},
+				Ports: []v1.ServicePort{
+					{Name: "web", Port: 80, TargetPort: intstr.FromString("web")},
+					{Name: "proxy", Port: 443, TargetPort: intstr.FromInt(8443)},
+					{Name: "direct", Port: 9091},
+				},
+			},
+		},
+		newPod("prometheus-0", v1.PodPending),
+		newPod("prometheus-1", v1.PodRunning),
+	)
+
+	cases := []struct {
+		target    string
+		pod       string
+		port      int
+		expectErr bool
+	}{
+		{target: "pod/prometheus-0:9090", pod: "prometheus-0", port: 9090},
+		{target: "svc/prometheus:80", pod: "prometheus-1", port: 9090},
+		{target: "svc/prometheus:443", pod: "prometheus-1", port: 8443},
+		{target: "svc/prometheus:9091", pod: "prometheus-1", port: 9091},
+		{target: "svc/prometheus:8080", expectErr: true},
+		{target: "svc/thanos:9090", expectErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.target, func(t *testing.T) {
+			pod, port, err := ResolvePortForwardTarget(context.TODO(), client, "monitoring", c.target)
+			if c.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pod != c.pod || port != c.port {
+				t.Fatalf("expected %s:%d but got %s:%d", c.pod, c.port, pod, port)
+			}
+		})
+	}
+}